@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUpdateEmail(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("owner")
+	api.register("other")
+
+	var tests = []struct {
+		name    string
+		alias   string
+		payload map[string]string
+		status  int
+	}{
+		{"other users' emails can't be changed", "other",
+			map[string]string{"CurrentPassword": testPassword, "Email": "new@example.com"}, http.StatusForbidden},
+		{"the current password is required", "owner",
+			map[string]string{"CurrentPassword": "wrong-password", "Email": "new@example.com"}, http.StatusBadRequest},
+		{"emails must be valid", "owner",
+			map[string]string{"CurrentPassword": testPassword, "Email": "invalid"}, http.StatusBadRequest},
+		{"emails can't be taken", "owner",
+			map[string]string{"CurrentPassword": testPassword, "Email": "other@example.com"}, http.StatusBadRequest},
+		{"emails are changed", "owner",
+			map[string]string{"CurrentPassword": testPassword, "Email": "new@example.com"}, http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api.expect(api.request(http.MethodPut, "/users/"+test.alias+"/email", userId, test.payload), test.status, nil)
+		})
+	}
+
+	var details struct{ Email string }
+	api.expect(api.request(http.MethodGet, "/users/owner", userId, nil), http.StatusOK, &details)
+	if details.Email != "new@example.com" {
+		t.Errorf("the email is %q, rather than the new one", details.Email)
+	}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("owner")
+	api.register("other")
+	const newPassword = "another-password"
+
+	var tests = []struct {
+		name    string
+		alias   string
+		payload map[string]string
+		status  int
+	}{
+		{"other users' passwords can't be changed", "other",
+			map[string]string{"CurrentPassword": testPassword, "NewPassword": newPassword}, http.StatusForbidden},
+		{"the current password is required", "owner",
+			map[string]string{"CurrentPassword": "wrong-password", "NewPassword": newPassword}, http.StatusBadRequest},
+		{"passwords are changed", "owner",
+			map[string]string{"CurrentPassword": testPassword, "NewPassword": newPassword}, http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api.expect(api.request(http.MethodPut, "/users/"+test.alias+"/password", userId, test.payload), test.status, nil)
+		})
+	}
+
+	// sessions are only granted with the new password
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "owner", "Password": testPassword,
+	}), http.StatusBadRequest, nil)
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "owner", "Password": newPassword,
+	}), http.StatusCreated, nil)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId, otherId = api.register("owner"), api.register("other")
+	var artworkId = api.upload(userId, "owner", nil)
+
+	api.expect(api.request(http.MethodDelete, "/users/other", userId, nil), http.StatusForbidden, nil)
+	api.expect(api.request(http.MethodDelete, "/users/owner", userId, nil), http.StatusNoContent, nil)
+
+	// deleted accounts can't authenticate, while their artworks disappear at once
+	api.expect(api.request(http.MethodGet, "/users/other", userId, nil), http.StatusUnauthorized, nil)
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "owner", "Password": testPassword,
+	}), http.StatusBadRequest, nil)
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", otherId, nil), http.StatusNotFound, nil)
+
+	// accounts are only purged once their grace period expires
+	for _, test := range []struct {
+		name     string
+		before   time.Time
+		expected int64
+	}{
+		{"accounts within their grace period are kept", time.Now().Add(-time.Hour), 0},
+		{"accounts past their grace period are purged", time.Now().Add(time.Second), 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			purged, err := api.services.artworks.PurgeDeletedAccounts(ntime.New(test.before))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != test.expected {
+				t.Errorf("purged %d accounts, rather than %d", purged, test.expected)
+			}
+		})
+	}
+
+	var artworks int
+	var row = api.connection.QueryRow(`SELECT count(*) FROM artworks WHERE id = ?`, artworkId)
+	if err := row.Scan(&artworks); err != nil {
+		t.Fatal(err)
+	} else if artworks != 0 {
+		t.Error("the purged account's artwork was kept")
+	}
+}
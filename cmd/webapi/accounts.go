@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ardanlabs/conf"
+	"github.com/silktrader/kvasari/pkg/moderation"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/storage/sqlite"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+)
+
+// accountCommands maps the subcommands allowing scripts to administer accounts to the moderation actions they take.
+var accountCommands = map[string]moderation.Action{
+	"suspend":   moderation.SuspendUser,
+	"reinstate": moderation.ReinstateUser,
+}
+
+// commandName returns the program's first argument, when it's not a flag.
+func commandName() string {
+	if len(os.Args) < 2 {
+		return ""
+	}
+	return os.Args[1]
+}
+
+/*
+runAccountCommand suspends or reinstates an account, recording the action in the moderation audit trail, as in:
+
+	webapi suspend [flags] <alias>
+
+Flags and configuration sources are the same as the web server's, so that the right database is addressed.
+*/
+func runAccountCommand(action moderation.Action, args []string) error {
+	cfg, err := loadConfiguration(args)
+	if err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			return nil
+		}
+		return err
+	}
+	if len(cfg.Args) != 1 {
+		return errors.New("usage: webapi suspend|reinstate [flags] <alias>")
+	}
+
+	// logs are kept apart from the command's output
+	var logger = logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	storage, err := sqlite.New(logger, filepath.Join(cfg.DB.Path, cfg.DB.Filename))
+	if err != nil {
+		return fmt.Errorf("error while initialising storage: %w", err)
+	}
+	defer storage.Close()
+
+	var note = "taken from the command line"
+	if _, err = moderation.NewStore(storage.Connection).TakeAction("", moderation.ActionData{
+		Action:  action,
+		Subject: cfg.Args.Num(0),
+		Note:    &note,
+	}, ntime.Now()); errors.Is(err, moderation.ErrNotFound) {
+		return fmt.Errorf("account %q not found, or already in the requested state", cfg.Args.Num(0))
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s\n", action, cfg.Args.Num(0)) //nolint:forbidigo
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFormerAliasRedirects(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId, viewerId = api.register("former"), api.register("viewer")
+	api.expect(api.request(http.MethodPut, "/users/former/alias", userId, map[string]string{"Alias": "current"}),
+		http.StatusNoContent, nil)
+
+	// redirects keep the rest of the path and the query, and mustn't be cached
+	var recorder = api.request(http.MethodGet, "/users/former/profile?page=1", viewerId, nil)
+	var body struct{ Alias, CanonicalAlias string }
+	api.expect(recorder, http.StatusTemporaryRedirect, &body)
+	if location := recorder.Header().Get("Location"); location != "/users/current/profile?page=1" {
+		t.Errorf("redirected to %q", location)
+	}
+	if cache := recorder.Header().Get("Cache-Control"); cache != "no-store" {
+		t.Errorf("the redirect's Cache-Control is %q, rather than no-store", cache)
+	}
+	if body.Alias != "former" || body.CanonicalAlias != "current" {
+		t.Errorf("the redirect's body is %+v", body)
+	}
+
+	// released aliases are reserved during the cooldown
+	api.expect(api.request(http.MethodPost, "/users", "", map[string]string{
+		"Name": "Claimant", "Alias": "former", "Email": "claimant@example.com", "Password": testPassword,
+	}), http.StatusBadRequest, nil)
+
+	// once the cooldown expires, the alias is no longer redirected and can be claimed
+	if _, err := api.connection.Exec(`UPDATE alias_history SET released = '2000-01-01T00:00:00Z'`); err != nil {
+		t.Fatal(err)
+	}
+	api.expect(api.request(http.MethodGet, "/users/former/profile", viewerId, nil), http.StatusNotFound, nil)
+	api.expect(api.request(http.MethodPost, "/users", "", map[string]string{
+		"Name": "Claimant", "Alias": "former", "Email": "claimant@example.com", "Password": testPassword,
+	}), http.StatusCreated, nil)
+	var details struct{ Name string }
+	api.expect(api.request(http.MethodGet, "/users/former", viewerId, nil), http.StatusOK, &details)
+	if details.Name != "Claimant" {
+		t.Errorf("the alias wasn't claimed: %+v", details)
+	}
+}
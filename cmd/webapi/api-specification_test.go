@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/openapi"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// specPath locates the API's specification, relative to the package's directory, whence tests are run.
+const specPath = "../../doc/api.yaml"
+
+// loadSpec loads the API's specification, failing the test when it's missing or malformed.
+func loadSpec(t *testing.T) openapi.Spec {
+	t.Helper()
+	spec, err := openapi.Load(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// routeName describes an engine's route as a specification's operation would be, once its path is converted.
+func routeName(route rest.Route) string {
+	return route.Method + " " + route.Path
+}
+
+// matchRoutes splits the specification's operations in those the engine implements and those it doesn't, and lists
+// the engine's routes lacking a documented operation.
+func matchRoutes(
+	spec openapi.Spec, routes []rest.Route,
+) (implemented, unimplemented []openapi.Operation, undocumented []string) {
+	var registered = make(map[string]bool, len(routes))
+	for _, route := range routes {
+		registered[routeName(route)] = true
+	}
+
+	var documented = make(map[string]bool)
+	for _, operation := range spec.Operations() {
+		var route = operation.Method + " " + openapi.RoutePattern(operation.Path)
+		documented[route] = true
+		if registered[route] {
+			implemented = append(implemented, operation)
+		} else {
+			unimplemented = append(unimplemented, operation)
+		}
+	}
+	for _, route := range routes {
+		if !documented[routeName(route)] {
+			undocumented = append(undocumented, routeName(route))
+		}
+	}
+	return implemented, unimplemented, undocumented
+}
+
+func TestRoutesMatchSpecification(t *testing.T) {
+	var api = newTestAPI(t)
+	implemented, unimplemented, undocumented := matchRoutes(loadSpec(t), api.engine.Routes())
+	for _, operation := range unimplemented {
+		t.Errorf("%s is unimplemented", operation)
+	}
+	for _, route := range undocumented {
+		t.Errorf("%s is undocumented", route)
+	}
+	if len(implemented) == 0 {
+		t.Error("no operation is implemented")
+	}
+}
+
+/*
+TestOperationsMatchSpecification drives each implemented operation against a scratch database, seeded with fixtures
+through the API itself, and checks its response: the status must be documented, internal errors are failures, and
+JSON bodies must match the documented schemas.
+
+Requests are made up from the specification's examples, or its schemas, so that many are expected to fail validation
+or miss their targets; they only need to do so as documented.
+*/
+func TestOperationsMatchSpecification(t *testing.T) {
+	var check = specCheck{testAPI: newTestAPI(t), spec: loadSpec(t)}
+	implemented, _, _ := matchRoutes(check.spec, check.engine.Routes())
+	check.seed()
+	for _, operation := range orderOperations(implemented) {
+		t.Run(operation.String(), func(t *testing.T) {
+			check.drive(t, operation)
+		})
+	}
+}
+
+// specCheck holds the fixtures which the specification's operations are driven with.
+type specCheck struct {
+	*testAPI
+	spec openapi.Spec
+
+	// userId identifies the admin driving operations, peerId the user they target
+	userId, peerId string
+
+	// parameters maps path parameters to the fixtures they address
+	parameters map[string]string
+
+	// upload holds the image whose size was declared by the upload session
+	upload []byte
+}
+
+// fixture sends a JSON request meant to seed data, failing unless it succeeds, and decodes its response.
+func (check *specCheck) fixture(method, path, userId string, payload, result any) {
+	check.t.Helper()
+	var recorder = check.request(method, path, userId, payload)
+	if recorder.Code >= 300 {
+		check.t.Fatalf("%s %s responded %d: %s", method, path, recorder.Code, recorder.Body.String())
+	}
+	if result != nil {
+		if err := json.NewDecoder(recorder.Body).Decode(result); err != nil {
+			check.t.Fatal(err)
+		}
+	}
+}
+
+/*
+seed registers the accounts operations are driven with, then adds the content their parameters address: an artwork,
+tagged and commented with a mention, which notifies its author, a collection and an upload session. The first account
+is promoted to admin, so that moderation operations are authorised.
+*/
+func (check *specCheck) seed() {
+	check.t.Helper()
+	check.userId, check.peerId = check.register("checkadmin"), check.register("checkpeer")
+	if _, err := check.connection.Exec(`UPDATE users SET role = 'admin' WHERE id = ?`, check.userId); err != nil {
+		check.t.Fatal(err)
+	}
+	check.parameters = map[string]string{"alias": "checkadmin", "target": "checkpeer", "requester": "checkpeer"}
+
+	var artworkId = check.testAPI.upload(check.userId, "checkadmin", nil)
+	var comment, collection, upload struct{ Id string }
+	var artworkPath = "/artworks/" + artworkId
+	check.fixture(http.MethodPut, artworkPath+"/tags", check.userId, map[string]any{"Tags": []string{"check"}}, nil)
+	check.fixture(http.MethodPost, artworkPath+"/comments", check.peerId, map[string]string{
+		"Comment": "Well done, @checkadmin!",
+	}, &comment)
+	check.fixture(http.MethodPost, "/users/checkadmin/collections", check.userId, map[string]string{
+		"Name": "Checked",
+	}, &collection)
+	check.upload = check.image()
+	check.fixture(http.MethodPost, "/uploads", check.userId, map[string]int{"Size": len(check.upload)}, &upload)
+
+	var notifications []struct{ Id string }
+	check.fixture(http.MethodGet, "/users/checkadmin/notifications", check.userId, nil, &notifications)
+	if len(notifications) == 0 {
+		check.t.Fatal("the mention wasn't notified")
+	}
+
+	for name, value := range map[string]string{
+		"artworkId":      artworkId,
+		"commentId":      comment.Id,
+		"collectionId":   collection.Id,
+		"uploadId":       upload.Id,
+		"notificationId": notifications[0].Id,
+		"image":          "0",
+		"tag":            "check",
+	} {
+		check.parameters[name] = value
+	}
+}
+
+// orderOperations sorts operations so that reads come first, then writes, and finally deletions, nested resources
+// before their parents, so that fixtures survive as long as possible.
+func orderOperations(operations []openapi.Operation) []openapi.Operation {
+	var rank = func(operation openapi.Operation) int {
+		switch operation.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return 0
+		case http.MethodDelete:
+			return 2
+		default:
+			return 1
+		}
+	}
+	var ordered = append([]openapi.Operation(nil), operations...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if rank(ordered[i]) != rank(ordered[j]) {
+			return rank(ordered[i]) < rank(ordered[j])
+		}
+		if rank(ordered[i]) == 2 {
+			return strings.Count(ordered[i].Path, "/") > strings.Count(ordered[j].Path, "/")
+		}
+		return false
+	})
+	return ordered
+}
+
+// drive sends a request made up for an operation and checks its response against the specification.
+func (check *specCheck) drive(t *testing.T, operation openapi.Operation) {
+	// aliases may have been changed by previous operations
+	var alias string
+	if err := check.connection.QueryRow(`SELECT alias FROM users WHERE id = ?`, check.userId).Scan(&alias); err != nil {
+		t.Fatalf("can't read the fixture's alias: %v", err)
+	}
+	check.parameters["alias"] = alias
+
+	var path, header, query = operation.Path, make(http.Header), make(url.Values)
+	for _, parameter := range operation.Parameters {
+		var name, _ = parameter["name"].(string)
+		switch parameter["in"] {
+		case "path":
+			value, found := check.parameters[name]
+			if !found {
+				t.Skipf("lacks a fixture for {%s}", name)
+			}
+			path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(value))
+		case "query", "header":
+			if required, _ := parameter["required"].(bool); !required {
+				continue
+			}
+			var value, found = check.spec.Example(parameter)
+			if !found {
+				value = check.spec.Sample(check.spec.Schema(parameter))
+			}
+			if parameter["in"] == "query" {
+				query.Set(name, fmt.Sprint(value))
+			} else {
+				header.Set(name, fmt.Sprint(value))
+			}
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if mediaType, media, found := operation.RequestBody(); found {
+		body, mediaType = check.body(mediaType, media)
+		header.Set("Content-Type", mediaType)
+	}
+
+	var request = httptest.NewRequest(operation.Method, path, body)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	var recorder = check.serve(request, check.userId)
+	if violations := check.validate(operation, recorder); len(violations) > 0 {
+		t.Errorf("responded %d: %s", recorder.Code, strings.Join(violations, "; "))
+	}
+}
+
+// body makes up a request body of the given media type, returning it along with its content type.
+func (check *specCheck) body(mediaType string, media openapi.Node) (io.Reader, string) {
+	switch mediaType {
+	case "application/json":
+		var value, found = check.spec.Example(media)
+		if !found {
+			value = check.spec.Sample(check.spec.Schema(media))
+		}
+		var buffer bytes.Buffer
+		_ = json.NewEncoder(&buffer).Encode(check.substitute(value))
+		return &buffer, mediaType
+	case "multipart/form-data":
+		return check.multipart(check.spec.Schema(media))
+	default:
+		// raw bodies are only accepted by uploads' chunks, which take the image declared with the session
+		return bytes.NewReader(check.upload), mediaType
+	}
+}
+
+// substitute replaces the properties of made up JSON objects referring to other users, artworks or to the password,
+// with the fixtures' ones, so that operations have a chance to succeed.
+func (check *specCheck) substitute(value any) any {
+	object, ok := value.(openapi.Node)
+	if !ok {
+		return value
+	}
+	for key := range object {
+		switch strings.ToLower(key) {
+		case "targetalias":
+			object[key] = check.parameters["target"]
+		case "currentpassword":
+			object[key] = testPassword
+		case "artworks":
+			object[key] = []string{check.parameters["artworkId"]}
+		}
+	}
+	return object
+}
+
+// multipart encodes a form's fields from a schema, where binary ones are given fresh images and aliases the fixture's
+// one; fields are written before files, since forms are streamed.
+func (check *specCheck) multipart(schema openapi.Node) (io.Reader, string) {
+	var properties, _ = schema["properties"].(openapi.Node)
+	var names = make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	var binary = func(name string) bool {
+		property, _ := properties[name].(openapi.Node)
+		return check.spec.Resolve(property)["format"] == "binary"
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if binary(names[i]) != binary(names[j]) {
+			return !binary(names[i])
+		}
+		return names[i] < names[j]
+	})
+
+	var buffer bytes.Buffer
+	var writer = multipart.NewWriter(&buffer)
+	for _, name := range names {
+		if name == "alias" {
+			_ = writer.WriteField(name, check.parameters["alias"])
+			continue
+		}
+		if !binary(name) {
+			property, _ := properties[name].(openapi.Node)
+			_ = writer.WriteField(name, fmt.Sprint(check.spec.Sample(property)))
+			continue
+		}
+		var header = make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="check.png"`, name))
+		header.Set("Content-Type", "image/png")
+		if part, err := writer.CreatePart(header); err == nil {
+			_, _ = part.Write(check.image())
+		}
+	}
+	_ = writer.Close()
+	return &buffer, writer.FormDataContentType()
+}
+
+// validate lists the ways a response deviates from its operation's specification.
+func (check *specCheck) validate(operation openapi.Operation, recorder *httptest.ResponseRecorder) []string {
+	if recorder.Code == http.StatusInternalServerError {
+		return []string{"internal server error " + strings.TrimSpace(recorder.Body.String())}
+	}
+	if _, found := operation.Response(recorder.Code); !found {
+		return []string{"undocumented status"}
+	}
+	schema, found := operation.ResponseSchema(recorder.Code)
+	if !found || recorder.Body.Len() == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+		return []string{"a JSON body was expected, rather than " + recorder.Header().Get("Content-Type")}
+	}
+
+	var value any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &value); err != nil {
+		return []string{"malformed JSON body: " + err.Error()}
+	}
+	var violations = make([]string, 0)
+	for _, err := range check.spec.Validate(schema, value) {
+		violations = append(violations, err.Error())
+	}
+	return violations
+}
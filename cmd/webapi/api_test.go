@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"github.com/silktrader/kvasari/pkg/storage/images"
+	"github.com/silktrader/kvasari/pkg/storage/sqlite"
+	"github.com/sirupsen/logrus"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// testPassword is the password of the accounts registered by tests.
+const testPassword = "test-api-password"
+
+// testAPI serves the API's handlers, registered as the web server does, over an in-memory database and a scratch
+// directory, where images are stored.
+type testAPI struct {
+	t          testing.TB
+	cfg        WebAPIConfiguration
+	engine     rest.Engine
+	handler    http.Handler
+	connection *sql.DB
+	services   services
+	images     int
+}
+
+// newTestAPI builds the API with the default configuration, which may be adjusted before handlers are registered.
+func newTestAPI(t testing.TB, configure ...func(cfg *WebAPIConfiguration)) *testAPI {
+	t.Helper()
+	var directory = t.TempDir()
+	cfg, err := loadConfiguration([]string{"--config-path", filepath.Join(directory, "missing.yml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DB.Path = directory
+	cfg.Images.Path = filepath.Join(directory, "images")
+	cfg.Exports.Path = filepath.Join(directory, "exports")
+	// every account is registered from the same client IP
+	cfg.RateLimit.RegistrationRequests = 0
+	for _, change := range configure {
+		change(&cfg)
+	}
+
+	var logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	storage, err := sqlite.NewInMemory(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(storage.Close)
+	imageStorage, err := images.New(logger, cfg.Images.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := rest.New(rest.Config{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, err := registerHandlers(e, cfg, logger, storage, imageStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAPI{t: t, cfg: cfg, engine: e, handler: e.Handler(), connection: storage.Connection, services: services}
+}
+
+// with returns a copy of the API reporting failures to a subtest, since they can't be reported to the parent test.
+// Images encoded by the copy may repeat the parent's ones.
+func (api *testAPI) with(t testing.TB) *testAPI {
+	var copied = *api
+	copied.t = t
+	return &copied
+}
+
+// serve sends a request straight to the handler, authenticated as the given user, when any.
+func (api *testAPI) serve(request *http.Request, userId string) *httptest.ResponseRecorder {
+	if userId != "" {
+		request.Header.Set("Authorization", "Bearer "+userId)
+	}
+	var recorder = httptest.NewRecorder()
+	api.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// request sends a request with a JSON encoded payload, when any.
+func (api *testAPI) request(method, path, userId string, payload any) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		var buffer bytes.Buffer
+		if err := json.NewEncoder(&buffer).Encode(payload); err != nil {
+			api.t.Fatal(err)
+		}
+		body = &buffer
+	}
+	var request = httptest.NewRequest(method, path, body)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	return api.serve(request, userId)
+}
+
+// expect fails the test unless a response has the given status, decoding its JSON body into result, when given.
+func (api *testAPI) expect(recorder *httptest.ResponseRecorder, status int, result any) {
+	api.t.Helper()
+	if recorder.Code != status {
+		api.t.Fatalf("responded %d rather than %d: %s", recorder.Code, status, recorder.Body.String())
+	}
+	if result != nil {
+		if err := json.NewDecoder(recorder.Body).Decode(result); err != nil {
+			api.t.Fatal(err)
+		}
+	}
+}
+
+// register creates an account whose alias is also used to make up its name and email, returning its ID; emails
+// replace the aliases' non-ASCII letters.
+func (api *testAPI) register(alias string) string {
+	api.t.Helper()
+	var local = strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return 'x'
+		}
+		return r
+	}, alias)
+	var user struct{ Id string }
+	api.expect(api.request(http.MethodPost, "/users", "", map[string]string{
+		"Name": "User " + alias, "Alias": alias, "Email": local + "@example.com", "Password": testPassword,
+	}), http.StatusCreated, &user)
+	return user.Id
+}
+
+// image encodes a small PNG image, different from the previous ones, since duplicate uploads may be refused.
+func (api *testAPI) image() []byte {
+	api.images++
+	var canvas = image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			canvas.Set(x, y, color.RGBA{R: uint8(api.images), G: uint8(api.images >> 8), B: uint8(x * y), A: 255})
+		}
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, canvas); err != nil {
+		api.t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// upload adds an artwork made of a fresh image, authored by the given user, with the given form fields, returning
+// its ID.
+func (api *testAPI) upload(userId, alias string, fields map[string]string) string {
+	api.t.Helper()
+	var form = map[string]string{"alias": alias}
+	for name, value := range fields {
+		form[name] = value
+	}
+	var artwork struct{ Id string }
+	api.expect(api.postImage("/artworks", userId, form), http.StatusCreated, &artwork)
+	return artwork.Id
+}
+
+// postImage sends a multipart form made of the given fields and a fresh image.
+func (api *testAPI) postImage(path, userId string, fields map[string]string) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	var writer = multipart.NewWriter(&buffer)
+	for name, value := range fields {
+		_ = writer.WriteField(name, value)
+	}
+	part, err := writer.CreateFormFile("image", fmt.Sprintf("test-%d.png", api.images))
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	_, _ = part.Write(api.image())
+	_ = writer.Close()
+
+	var request = httptest.NewRequest(http.MethodPost, path, &buffer)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return api.serve(request, userId)
+}
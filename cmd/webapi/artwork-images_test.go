@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// imageIds lists an artwork's image IDs in order, along with its cover and images count, as seen by the requester.
+func (api *testAPI) imageIds(artworkId, userId string) (ids []string, cover string, count int) {
+	api.t.Helper()
+	var images []struct{ Id string }
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/images", userId, nil), http.StatusOK, &images)
+	for _, image := range images {
+		ids = append(ids, image.Id)
+	}
+	var artwork struct {
+		Cover  string
+		Images int
+	}
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", userId, nil), http.StatusOK, &artwork)
+	return ids, artwork.Cover, artwork.Images
+}
+
+// addImage appends a fresh image to the artwork, returning its ID.
+func (api *testAPI) addImage(artworkId, userId string) string {
+	api.t.Helper()
+	var image struct{ Id string }
+	api.expect(api.postImage("/artworks/"+artworkId+"/images", userId, nil), http.StatusCreated, &image)
+	return image.Id
+}
+
+func TestArtworkImages(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, strangerId = api.register("author"), api.register("stranger")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	// images are appended in order, while the first one stays the cover
+	var second, third = api.addImage(artworkId, authorId), api.addImage(artworkId, authorId)
+	var thirdImage = api.images
+	if ids, cover, count := api.imageIds(artworkId, strangerId); !reflect.DeepEqual(ids,
+		[]string{artworkId, second, third}) || cover != artworkId || count != 3 {
+		t.Errorf("listed images %v, cover %s and count %d after additions", ids, cover, count)
+	}
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/images/2", strangerId, nil), http.StatusOK, nil)
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/images/"+second, strangerId, nil),
+		http.StatusOK, nil)
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/images/3", strangerId, nil), http.StatusNotFound,
+		nil)
+
+	// images belong to a single artwork, hence encoding the last one again is refused
+	api.images = thirdImage - 1
+	api.expect(api.postImage("/artworks/"+artworkId+"/images", authorId, nil), http.StatusBadRequest, nil)
+
+	// only authors can change their artworks' images
+	api.expect(api.postImage("/artworks/"+artworkId+"/images", strangerId, nil), http.StatusNotFound, nil)
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/images", strangerId, map[string][]string{
+		"Images": {third, second, artworkId},
+	}), http.StatusNotFound, nil)
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+second, strangerId, nil),
+		http.StatusNotFound, nil)
+
+	// reordering lists every image once, and the first one becomes the cover
+	for _, order := range [][]string{{third, second}, {third, second, second}, {third, second, "missing"}} {
+		api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/images", authorId, map[string][]string{
+			"Images": order,
+		}), http.StatusBadRequest, nil)
+	}
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/images", authorId, map[string][]string{
+		"Images": {third, artworkId, second},
+	}), http.StatusNoContent, nil)
+	if ids, cover, _ := api.imageIds(artworkId, strangerId); !reflect.DeepEqual(ids,
+		[]string{third, artworkId, second}) || cover != third {
+		t.Errorf("listed images %v and cover %s after reordering", ids, cover)
+	}
+
+	// removals close gaps and move the cover along, though artworks keep the images they were uploaded with
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+third, authorId, nil),
+		http.StatusNoContent, nil)
+	if ids, cover, count := api.imageIds(artworkId, strangerId); !reflect.DeepEqual(ids,
+		[]string{artworkId, second}) || cover != artworkId || count != 2 {
+		t.Errorf("listed images %v, cover %s and count %d after a removal", ids, cover, count)
+	}
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+artworkId, authorId, nil),
+		http.StatusBadRequest, nil)
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+third, authorId, nil),
+		http.StatusNotFound, nil)
+
+	// removed images are free to be uploaded again, as other artworks' first images
+	api.images = thirdImage - 1
+	if reuploaded := api.upload(authorId, "author", nil); reuploaded != third {
+		t.Errorf("uploaded the removed image as %s, rather than %s", reuploaded, third)
+	}
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+second, authorId, nil),
+		http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/images/"+artworkId, authorId, nil),
+		http.StatusBadRequest, nil)
+	if ids, _, _ := api.imageIds(artworkId, strangerId); !reflect.DeepEqual(ids, []string{artworkId}) {
+		t.Errorf("listed images %v after removing all but the first", ids)
+	}
+}
+
+func TestArtworkImagesLimit(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.RateLimit.UploadRequests = 0
+	})
+	var authorId = api.register("author")
+	var artworkId = api.upload(authorId, "author", nil)
+	for images := 1; images < 20; images++ {
+		api.addImage(artworkId, authorId)
+	}
+	api.expect(api.postImage("/artworks/"+artworkId+"/images", authorId, nil), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ardanlabs/conf"
+	"os"
+	"text/tabwriter"
+)
+
+/*
+runConfigCheck prints the effective configuration, merged from its sources, and validates it, as in:
+
+	webapi check-config [flags]
+
+Each value is listed along with its flag and source: the default, the configuration file, an environment variable or a
+flag. Secrets, tagged with `mask`, are redacted. Problems, including the configuration file's ignored keys, are
+reported after the values, and fail the command.
+*/
+func runConfigCheck(args []string) error {
+	cfg, sources, err := loadConfigurationSources(args)
+	if err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			return nil
+		}
+		return err
+	}
+	if len(cfg.Args) != 0 {
+		return errors.New("usage: webapi check-config [flags]")
+	}
+
+	var writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, value := range sources.values {
+		if value.Value == "" {
+			value.Value = `""`
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", value.Flag, value.Value, value.Source)
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+
+	var problems = make(configurationErrors, 0)
+	for _, key := range sources.ignored {
+		problems = append(problems,
+			fmt.Errorf("the config file's %q key is ignored, matching no setting the file may change", key))
+	}
+	var invalid configurationErrors
+	if err = cfg.Validate(); errors.As(err, &invalid) {
+		problems = append(problems, invalid...)
+	} else if err != nil {
+		problems = append(problems, err)
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("%-6s %s\n", "FAIL", problem) //nolint:forbidigo
+	}
+	fmt.Printf("%d settings checked, %d problems found\n", len(sources.values), len(problems)) //nolint:forbidigo
+	if len(problems) > 0 {
+		return errors.New("the configuration is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"github.com/silktrader/kvasari/pkg/artworks"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// collection is a collection's metadata, along with the ids of the artworks the requester may see, in order.
+type collection struct {
+	Id         string
+	Name       string
+	Visibility string
+	Entries    int
+	Artworks   []struct{ Id string }
+}
+
+// createCollection has the user create a collection, returning its ID.
+func (api *testAPI) createCollection(alias, userId, name, visibility string) string {
+	api.t.Helper()
+	var created collection
+	api.expect(api.request(http.MethodPost, "/users/"+alias+"/collections", userId, map[string]string{
+		"Name": name, "Visibility": visibility,
+	}), http.StatusCreated, &created)
+	return created.Id
+}
+
+// collect adds the artwork to the user's collection, expecting the given status.
+func (api *testAPI) collect(alias, userId, collectionId, artworkId string, status int) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodPut, "/users/"+alias+"/collections/"+collectionId+"/artworks/"+artworkId,
+		userId, nil), status, nil)
+}
+
+// collection fetches a collection as seen by the requester, returning the ids of its artworks, or nil when the
+// collection can't be found.
+func (api *testAPI) collection(alias, requesterId, collectionId string) []string {
+	api.t.Helper()
+	var recorder = api.request(http.MethodGet, "/users/"+alias+"/collections/"+collectionId, requesterId, nil)
+	if recorder.Code == http.StatusNotFound {
+		return nil
+	}
+	var fetched collection
+	api.expect(recorder, http.StatusOK, &fetched)
+	var ids = make([]string, len(fetched.Artworks))
+	for index, artwork := range fetched.Artworks {
+		ids[index] = artwork.Id
+	}
+	if fetched.Entries != len(ids) {
+		api.t.Errorf("counted %d entries, while listing %v", fetched.Entries, ids)
+	}
+	return ids
+}
+
+// collectionNames lists the names of a user's collections which the requester may see.
+func (api *testAPI) collectionNames(alias, requesterId string) []string {
+	api.t.Helper()
+	var collections []collection
+	api.expect(api.request(http.MethodGet, "/users/"+alias+"/collections", requesterId, nil), http.StatusOK,
+		&collections)
+	var names = make([]string, 0, len(collections))
+	for _, listed := range collections {
+		names = append(names, listed.Name)
+	}
+	return names
+}
+
+func TestCollections(t *testing.T) {
+	var api = newTestAPI(t)
+	var ownerId, authorId, viewerId = api.register("owner"), api.register("author"), api.register("viewer")
+	var own = api.upload(ownerId, "owner", nil)
+	var first, second = api.upload(authorId, "author", nil), api.upload(authorId, "author", nil)
+	var hidden = api.upload(authorId, "author", map[string]string{"visibility": "private"})
+	var public = api.createCollection("owner", ownerId, "Favourites", "public")
+
+	// owners add the artworks they can see, once each, in order
+	api.collect("owner", ownerId, public, first, http.StatusNoContent)
+	api.collect("owner", ownerId, public, second, http.StatusNoContent)
+	api.collect("owner", ownerId, public, own, http.StatusNoContent)
+	api.collect("owner", ownerId, public, first, http.StatusNoContent)
+	api.collect("owner", ownerId, public, hidden, http.StatusNotFound)
+	if ids := api.collection("owner", viewerId, public); !reflect.DeepEqual(ids, []string{first, second, own}) {
+		t.Errorf("listed the entries %v", ids)
+	}
+
+	// sorting moves the listed artworks ahead of the others
+	api.expect(api.request(http.MethodPut, "/users/owner/collections/"+public+"/artworks", ownerId, map[string][]string{
+		"Artworks": {own, second},
+	}), http.StatusNoContent, nil)
+	for _, order := range [][]string{{own, own}, {hidden}} {
+		api.expect(api.request(http.MethodPut, "/users/owner/collections/"+public+"/artworks", ownerId,
+			map[string][]string{"Artworks": order}), http.StatusBadRequest, nil)
+	}
+	if ids := api.collection("owner", viewerId, public); !reflect.DeepEqual(ids, []string{own, second, first}) {
+		t.Errorf("listed the entries %v after sorting", ids)
+	}
+	api.expect(api.request(http.MethodDelete, "/users/owner/collections/"+public+"/artworks/"+second, ownerId, nil),
+		http.StatusNoContent, nil)
+	if ids := api.collection("owner", viewerId, public); !reflect.DeepEqual(ids, []string{own, first}) {
+		t.Errorf("listed the entries %v after a removal", ids)
+	}
+
+	// collections are managed by their owners alone
+	api.expect(api.request(http.MethodPost, "/users/owner/collections", viewerId, map[string]string{
+		"Name": "Intruding",
+	}), http.StatusForbidden, nil)
+	api.expect(api.request(http.MethodPut, "/users/owner/collections/"+public, viewerId, map[string]string{
+		"Name": "Renamed",
+	}), http.StatusForbidden, nil)
+	api.collect("owner", viewerId, public, second, http.StatusForbidden)
+	api.expect(api.request(http.MethodDelete, "/users/owner/collections/"+public, viewerId, nil),
+		http.StatusForbidden, nil)
+	api.expect(api.request(http.MethodGet, "/users/owner/collections", "", nil), http.StatusUnauthorized, nil)
+
+	// private collections are hidden from others
+	var private = api.createCollection("owner", ownerId, "Drafts", "private")
+	api.collect("owner", ownerId, private, first, http.StatusNoContent)
+	if names := api.collectionNames("owner", viewerId); !reflect.DeepEqual(names, []string{"Favourites"}) {
+		t.Errorf("listed the collections %v to others", names)
+	}
+	if ids := api.collection("owner", viewerId, private); ids != nil {
+		t.Errorf("listed the private collection's entries %v to others", ids)
+	}
+	if ids := api.collection("owner", ownerId, private); !reflect.DeepEqual(ids, []string{first}) {
+		t.Errorf("listed the private collection's entries %v to its owner", ids)
+	}
+
+	// entries follow their artworks' visibility, and are dropped once their artworks are purged
+	api.expect(api.request(http.MethodPut, "/artworks/"+own+"/visibility", ownerId, map[string]string{
+		"Visibility": "private",
+	}), http.StatusNoContent, nil)
+	if ids := api.collection("owner", viewerId, public); !reflect.DeepEqual(ids, []string{first}) {
+		t.Errorf("listed the entries %v, including a private artwork", ids)
+	}
+	api.expect(api.request(http.MethodDelete, "/artworks/"+first, authorId, nil), http.StatusNoContent, nil)
+	if ids := api.collection("owner", ownerId, private); len(ids) != 0 {
+		t.Errorf("listed the entries %v, including a deleted artwork", ids)
+	}
+	artworks.NewStore(api.connection, api.services.users, api.services.artworks.ImageStore)
+	var entries int
+	if err := api.connection.QueryRow(`SELECT count(*) FROM collection_entries WHERE artwork = ?`, first).Scan(
+		&entries); err != nil || entries != 0 {
+		t.Errorf("kept %d entries of a purged artwork, with error %v", entries, err)
+	}
+
+	api.expect(api.request(http.MethodDelete, "/users/owner/collections/"+private, ownerId, nil),
+		http.StatusNoContent, nil)
+	if names := api.collectionNames("owner", ownerId); !reflect.DeepEqual(names, []string{"Favourites"}) {
+		t.Errorf("listed the collections %v after a deletion", names)
+	}
+}
+
+func TestCollectionsRespectBans(t *testing.T) {
+	var api = newTestAPI(t)
+	var ownerId, viewerId = api.register("owner"), api.register("viewer")
+	var artworkId = api.upload(ownerId, "owner", nil)
+	var public = api.createCollection("owner", ownerId, "Favourites", "public")
+	api.collect("owner", ownerId, public, artworkId, http.StatusNoContent)
+
+	api.expect(api.request(http.MethodPost, "/users/owner/bans", ownerId, map[string]string{
+		"TargetAlias": "viewer",
+	}), http.StatusCreated, nil)
+	if names := api.collectionNames("owner", viewerId); len(names) != 0 {
+		t.Errorf("listed the collections %v to a banned user", names)
+	}
+	if ids := api.collection("owner", viewerId, public); ids != nil {
+		t.Errorf("listed the entries %v to a banned user", ids)
+	}
+
+	// banned users can't collect their banners' artworks either
+	var collected = api.createCollection("viewer", viewerId, "Collected", "public")
+	api.collect("viewer", viewerId, collected, artworkId, http.StatusNotFound)
+}
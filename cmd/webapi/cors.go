@@ -1,18 +1,24 @@
 package main
 
 import (
-	"github.com/gorilla/handlers"
+	"github.com/silktrader/kvasari/pkg/rest"
 	"net/http"
 )
 
-// applyCORSHandler applies a CORS policy to the router.
-func applyCORSHandler(h http.Handler) http.Handler {
-	return handlers.CORS(
-		handlers.AllowedHeaders([]string{
-			"Content-Type", "Authorization",
-		}),
-		handlers.AllowCredentials(),
-		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "DELETE", "PUT"}),
-		handlers.AllowedOrigins([]string{"*"}),
-	)(h)
+// applyCORSHandler applies the configured CORS policy to the router, failing when the policy is invalid, as when the
+// `*` origin is allowed along with credentials.
+func applyCORSHandler(h http.Handler, e rest.Engine, cfg WebAPIConfiguration) (http.Handler, error) {
+	return e.CORS(corsPolicy(cfg), h)
+}
+
+// corsPolicy converts the CORS section of the configuration to a policy.
+func corsPolicy(cfg WebAPIConfiguration) rest.CORSPolicy {
+	return rest.CORSPolicy{
+		AllowedOrigins:   cfg.Web.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.Web.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.Web.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.Web.CORS.ExposedHeaders,
+		MaxAge:           cfg.Web.CORS.MaxAge,
+		AllowCredentials: cfg.Web.CORS.AllowCredentials,
+	}
 }
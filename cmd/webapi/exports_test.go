@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// export describes the state of a data export, as polled by clients.
+type export struct {
+	Id     string
+	Status string
+}
+
+// awaitExport polls the user's latest export until it's no longer pending.
+func (api *testAPI) awaitExport(alias, userId string) export {
+	api.t.Helper()
+	var polled export
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		api.expect(api.request(http.MethodGet, "/users/"+alias+"/export", userId, nil), http.StatusOK, &polled)
+		if polled.Status != "pending" {
+			return polled
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api.t.Fatalf("the export %s is still pending", polled.Id)
+	return polled
+}
+
+// archive downloads the user's latest archive and indexes its files by name.
+func (api *testAPI) archive(alias, userId string) map[string]*zip.File {
+	api.t.Helper()
+	var recorder = api.request(http.MethodGet, "/users/"+alias+"/export/archive", userId, nil)
+	api.expect(recorder, http.StatusOK, nil)
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/zip" {
+		api.t.Errorf("served the archive as %q", contentType)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	var files = make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	return files
+}
+
+// decodeFile decodes an archive's JSON document, failing when missing.
+func decodeFile(t testing.TB, files map[string]*zip.File, name string, document any) {
+	t.Helper()
+	var file, found = files[name]
+	if !found {
+		t.Fatalf("the archive lacks %s", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if err = json.NewDecoder(reader).Decode(document); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExports(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId, otherId, adminId = api.register("exporter"), api.register("other"), api.register("admin")
+	api.promote(adminId)
+
+	// the user's data, including what's later deleted or taken down
+	var kept, deleted, takenDown = api.upload(userId, "exporter", nil), api.upload(userId, "exporter", nil),
+		api.upload(userId, "exporter", nil)
+	var image = api.addImage(kept, userId)
+	var othersArtwork = api.upload(otherId, "other", nil)
+	var comment = api.comment(othersArtwork, userId, "A comment which is kept.")
+	var removedComment = api.comment(othersArtwork, userId, "A comment which is taken down.")
+	api.react(othersArtwork, "exporter", userId, "Like", http.StatusOK)
+	api.follow("exporter", userId, "other", http.StatusCreated)
+	api.expect(api.request(http.MethodPost, "/users/exporter/bans", userId, map[string]string{
+		"TargetAlias": "admin",
+	}), http.StatusCreated, nil)
+
+	api.expect(api.request(http.MethodDelete, "/artworks/"+deleted, userId, nil), http.StatusNoContent, nil)
+	for action, subject := range map[string]string{
+		"take-down-artwork": takenDown,
+		"take-down-comment": removedComment,
+	} {
+		api.expect(api.request(http.MethodPost, "/moderation/actions", adminId, map[string]string{
+			"Action": action, "Subject": subject,
+		}), http.StatusCreated, nil)
+	}
+
+	// exports are disclosed to their owners alone, and archives are only served once ready
+	api.expect(api.request(http.MethodGet, "/users/exporter/export", userId, nil), http.StatusNotFound, nil)
+	api.expect(api.request(http.MethodGet, "/users/exporter/export/archive", userId, nil), http.StatusNotFound, nil)
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/users/exporter/export"},
+		{http.MethodGet, "/users/exporter/export"},
+		{http.MethodGet, "/users/exporter/export/archive"},
+	} {
+		api.expect(api.request(route.method, route.path, otherId, nil), http.StatusForbidden, nil)
+		api.expect(api.request(route.method, route.path, "", nil), http.StatusUnauthorized, nil)
+	}
+
+	var requested export
+	api.expect(api.request(http.MethodPost, "/users/exporter/export", userId, nil), http.StatusAccepted, &requested)
+	if polled := api.awaitExport("exporter", userId); polled.Id != requested.Id || polled.Status != "ready" {
+		t.Fatalf("polled the export %+v, rather than the ready %s", polled, requested.Id)
+	}
+	var files = api.archive("exporter", userId)
+
+	var account struct{ User struct{ Alias string } }
+	decodeFile(t, files, "account.json", &account)
+	if account.User.Alias != "exporter" {
+		t.Errorf("exported the account %+v", account)
+	}
+
+	var relations struct{ Followed, Bans []struct{ Alias string } }
+	decodeFile(t, files, "relations.json", &relations)
+	if len(relations.Followed) != 1 || relations.Followed[0].Alias != "other" || len(relations.Bans) != 1 ||
+		relations.Bans[0].Alias != "admin" {
+		t.Errorf("exported the relations %+v", relations)
+	}
+
+	var comments []struct{ Id, ArtworkId, Comment string }
+	decodeFile(t, files, "comments.json", &comments)
+	if len(comments) != 1 || comments[0].Id != comment || comments[0].ArtworkId != othersArtwork {
+		t.Errorf("exported the comments %+v, rather than the one kept", comments)
+	}
+
+	var reactions []struct{ ArtworkId, Reaction string }
+	decodeFile(t, files, "reactions.json", &reactions)
+	if len(reactions) != 1 || reactions[0].ArtworkId != othersArtwork || reactions[0].Reaction != "Like" {
+		t.Errorf("exported the reactions %+v", reactions)
+	}
+
+	// deleted and taken down artworks are left out, along with their images
+	var artworks []struct {
+		Id    string
+		Files []string
+	}
+	decodeFile(t, files, "artworks.json", &artworks)
+	if len(artworks) != 1 || artworks[0].Id != kept || len(artworks[0].Files) != 2 ||
+		artworks[0].Files[0] != "images/"+kept+".png" || artworks[0].Files[1] != "images/"+image+".png" {
+		t.Fatalf("exported the artworks %+v, rather than the one kept", artworks)
+	}
+	for _, removed := range []string{deleted, takenDown} {
+		if files["images/"+removed+".png"] != nil {
+			t.Errorf("exported the image of the removed artwork %s", removed)
+		}
+	}
+	for _, name := range artworks[0].Files {
+		if files[name] == nil {
+			t.Errorf("the archive lacks %s", name)
+		}
+	}
+}
+
+func TestExpiredExports(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Exports.Expiry = -time.Minute
+	})
+	var userId = api.register("exporter")
+	var expired, renewed export
+	api.expect(api.request(http.MethodPost, "/users/exporter/export", userId, nil), http.StatusAccepted, &expired)
+	if polled := api.awaitExport("exporter", userId); polled.Status != "ready" {
+		t.Fatalf("polled the export %+v, rather than a ready one", polled)
+	}
+	api.expect(api.request(http.MethodGet, "/users/exporter/export/archive", userId, nil), http.StatusNotFound, nil)
+
+	// expired exports are purged, along with their archives, while new ones can be requested
+	purged, err := api.services.exports.PurgeExpiredExports(ntime.Now())
+	if err != nil || purged != 1 {
+		t.Errorf("purged %d expired exports, with error %v", purged, err)
+	}
+	if _, err = os.Stat(filepath.Join(api.cfg.Exports.Path, expired.Id+".zip")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("kept the expired archive, with error %v", err)
+	}
+	api.expect(api.request(http.MethodPost, "/users/exporter/export", userId, nil), http.StatusAccepted, &renewed)
+	if renewed.Id == expired.Id {
+		t.Error("returned the expired export, rather than a new one")
+	}
+	api.awaitExport("exporter", userId)
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"github.com/ardanlabs/conf"
 	"gopkg.in/yaml.v2"
-	"io"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 )
 
 // WebAPIConfiguration describes the web API configuration. This structure is automatically parsed by
-// loadConfiguration and values from flags, environment variable or configuration file will be loaded.
+// loadConfiguration and values from flags, environment variable or configuration file will be loaded. Secrets must be
+// tagged with `mask`, so that they're redacted when the configuration is printed.
 type WebAPIConfiguration struct {
 	Config struct {
 		Path string `conf:"default:/conf/config.yml"`
@@ -22,6 +25,28 @@ type WebAPIConfiguration struct {
 		ReadTimeout     time.Duration `conf:"default:5s"`
 		WriteTimeout    time.Duration `conf:"default:5s"`
 		ShutdownTimeout time.Duration `conf:"default:5s"`
+
+		// CORS lists origins as "https://example.com", "https://*.example.com" or "*"; methods default to the routed
+		// ones
+		CORS struct {
+			AllowedOrigins   []string `conf:"default:*"`
+			AllowedMethods   []string
+			AllowedHeaders   []string      `conf:"default:Content-Type;Authorization;If-None-Match;Upload-Offset"`
+			ExposedHeaders   []string      `conf:"default:ETag;Location;Retry-After;Upload-Offset;Content-Disposition"`
+			MaxAge           time.Duration `conf:"default:10m"`
+			AllowCredentials bool
+		}
+
+		// TLS is enabled by setting the certificate and key files, reloaded when modified or on SIGHUP; the redirect
+		// host, when set, listens for plain HTTP requests and redirects them to HTTPS
+		TLS struct {
+			CertFile       string
+			KeyFile        string
+			MinVersion     string        `conf:"default:1.2"`
+			ReloadInterval time.Duration `conf:"default:1m"`
+			RedirectHost   string
+			HSTSMaxAge     time.Duration `conf:"default:4320h"`
+		}
 	}
 	Debug bool
 	DB    struct {
@@ -29,46 +54,259 @@ type WebAPIConfiguration struct {
 		Filename string `conf:"default:data.db"`
 	}
 	Images struct {
-		Path string `conf:"default:/tmp/kvasari/images"`
+		Path         string        `conf:"default:/tmp/kvasari/images"`
+		MinFreeBytes uint64        `conf:"default:536870912"`
+		UploadExpiry time.Duration `conf:"default:24h"`
+	}
+	Quota struct {
+		Bytes    int64 `conf:"default:1073741824"`
+		Artworks int   `conf:"default:1000"`
+	}
+	Accounts struct {
+		DeletionGrace time.Duration `conf:"default:720h"`
+		AliasCooldown time.Duration `conf:"default:720h"`
+	}
+	Exports struct {
+		Path   string        `conf:"default:/tmp/kvasari/exports"`
+		Expiry time.Duration `conf:"default:24h"`
+	}
+	Lockout struct {
+		AliasThreshold int           `conf:"default:5"`
+		IPThreshold    int           `conf:"default:20"`
+		Window         time.Duration `conf:"default:15m"`
+		BaseLock       time.Duration `conf:"default:1m"`
+		MaxLock        time.Duration `conf:"default:1h"`
 	}
+	RateLimit struct {
+		UploadRequests       int           `conf:"default:20"`
+		UploadPeriod         time.Duration `conf:"default:1h"`
+		CommentRequests      int           `conf:"default:60"`
+		CommentPeriod        time.Duration `conf:"default:1h"`
+		RegistrationRequests int           `conf:"default:5"`
+		RegistrationPeriod   time.Duration `conf:"default:1h"`
+		Eviction             time.Duration `conf:"default:10m"`
+	}
+	Maintenance struct {
+		Interval time.Duration `conf:"default:1h"`
+	}
+	Discovery struct {
+		ReactionWeight float64       `conf:"default:1"`
+		CommentWeight  float64       `conf:"default:3"`
+		FollowedBoost  float64       `conf:"default:2"`
+		NetworkBoost   float64       `conf:"default:1.5"`
+		HalfLife       time.Duration `conf:"default:72h"`
+		Window         time.Duration `conf:"default:720h"`
+	}
+	Tags struct {
+		TrendingWindow time.Duration `conf:"default:168h"`
+		TrendingLimit  int           `conf:"default:20"`
+	}
+
+	// Args holds the positional arguments of subcommands, such as the alias of the account to suspend
+	Args conf.Args
+}
+
+/*
+loadConfiguration creates a WebAPIConfiguration from defaults, the configuration file, environment variables and flags,
+in increasing order of precedence: environment variables override the file, while flags override everything. The file
+is specified in WebAPIConfiguration.Config.Path, which can only be set via CLI or environment variable, and is skipped
+when missing. Values aren't validated, see WebAPIConfiguration.Validate.
+*/
+func loadConfiguration(args []string) (WebAPIConfiguration, error) {
+	cfg, _, err := loadConfigurationSources(args)
+	return cfg, err
 }
 
-// loadConfiguration creates a WebAPIConfiguration starting from flags, environment variables and configuration file.
-// It works by loading environment variables first, then update the config using command line flags, finally loading the
-// configuration file (specified in WebAPIConfiguration.Config.Path).
-// So, CLI parameters will override the environment, and configuration file will override everything.
-// Note that the configuration file can be specified only via CLI or environment variable.
-func loadConfiguration() (WebAPIConfiguration, error) {
+// configurationValue describes an effective configuration value, along with the source that set it.
+type configurationValue struct {
+	Flag   string
+	Value  string
+	Source string
+}
+
+// configurationSources lists the configuration's values, in the order they're declared, and the file's ignored keys.
+type configurationSources struct {
+	values  []configurationValue
+	ignored []string
+}
+
+// loadConfigurationSources loads the configuration as loadConfiguration does, also listing its values with their
+// sources, redacted when tagged as secrets.
+func loadConfigurationSources(args []string) (WebAPIConfiguration, configurationSources, error) {
+	var sources configurationSources
 	var cfg WebAPIConfiguration
 
-	// Try to load configuration from environment variables and command line switches
-	if err := conf.Parse(os.Args[1:], "CFG", &cfg); err != nil {
+	// the first pass only determines the configuration file's path, and reports help requests
+	if err := conf.Parse(args, "CFG", &cfg); err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			usage, err := conf.Usage("CFG", &cfg)
 			if err != nil {
-				return cfg, fmt.Errorf("generating config usage: %w", err)
+				return cfg, sources, fmt.Errorf("generating config usage: %w", err)
 			}
 			fmt.Println(usage) //nolint:forbidigo
-			return cfg, conf.ErrHelpWanted
+			return cfg, sources, conf.ErrHelpWanted
 		}
-		return cfg, fmt.Errorf("parsing config: %w", err)
+		return cfg, sources, fmt.Errorf("parsing config: %w", err)
+	}
+
+	// the file's values are provided to conf ahead of environment variables and flags, which override them
+	file, err := readConfigurationFile(cfg.Config.Path)
+	if err != nil {
+		return cfg, sources, err
+	}
+	cfg = WebAPIConfiguration{}
+	if err = conf.Parse(args, "CFG", &cfg, file); err != nil {
+		return cfg, sources, fmt.Errorf("parsing config: %w", err)
 	}
 
-	// Override values from YAML if specified and if it exists (useful in k8s/compose)
-	fp, err := os.Open(cfg.Config.Path)
-	if err != nil && !os.IsNotExist(err) {
-		return cfg, fmt.Errorf("can't read the config file, while it exists: %w", err)
-	} else if err == nil {
-		yamlFile, err := io.ReadAll(fp)
-		if err != nil {
-			return cfg, fmt.Errorf("can't read config file: %w", err)
+	var flags = flagNames(args, file.fields)
+	sources.values = make([]configurationValue, 0, len(file.fields))
+	for _, field := range file.fields {
+		var flag = strings.ToLower(strings.Join(field.FlagKey, "-"))
+		var env = "CFG_" + strings.ToUpper(strings.Join(field.EnvKey, "_"))
+		var value = configurationValue{Flag: "--" + flag, Value: formatField(field), Source: "default"}
+		if _, found := os.LookupEnv(env); flags[flag] {
+			value.Source = "flag"
+		} else if found {
+			value.Source = "env " + env
+		} else if _, found = file.values[fileKey(field.FlagKey)]; found && file.provides(field) {
+			value.Source = "file " + file.path
 		}
-		err = yaml.Unmarshal(yamlFile, &cfg)
-		if err != nil {
-			return cfg, fmt.Errorf("can't unmarshal config file: %w", err)
+		sources.values = append(sources.values, value)
+	}
+	sources.ignored = file.ignoredKeys()
+	return cfg, sources, nil
+}
+
+/*
+fileSource provides conf with the configuration file's values, keyed by their lowercase path stripped of separators, as
+in "webapihost" for `web: apihost:`, which matches both YAML's naming of fields and conf's. Lists are joined by
+semicolons, as conf expects. The fields conf asks for are recorded, in order.
+*/
+type fileSource struct {
+	path   string
+	values map[string]string
+	fields []conf.Field
+
+	// names maps keys to their paths, as written in the file, as in "web.apihost"
+	names map[string]string
+}
+
+// readConfigurationFile reads the YAML configuration file, when it exists.
+func readConfigurationFile(path string) (*fileSource, error) {
+	var source = &fileSource{path: path, values: make(map[string]string), names: make(map[string]string)}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return source, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read the config file, while it exists: %w", err)
+	}
+
+	var document map[interface{}]interface{}
+	if err = yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("can't unmarshal config file: %w", err)
+	}
+	source.flatten("", document)
+	return source, nil
+}
+
+// flatten stores a YAML document's scalar and list values by their key.
+func (source *fileSource) flatten(prefix string, node map[interface{}]interface{}) {
+	for key, value := range node {
+		var name = fmt.Sprint(key)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		var key = fileKey([]string{name})
+		switch typed := value.(type) {
+		case map[interface{}]interface{}:
+			source.flatten(name, typed)
+			continue
+		case []interface{}:
+			var items = make([]string, len(typed))
+			for index, item := range typed {
+				items[index] = fmt.Sprint(item)
+			}
+			source.values[key] = strings.Join(items, ";")
+		case nil:
+			continue
+		default:
+			source.values[key] = fmt.Sprint(typed)
+		}
+		source.names[key] = name
+	}
+}
+
+// fileKey converts a field's key, or a file's path, to the file's naming.
+func fileKey(words []string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.Join(words, ""), ".", ""))
+}
+
+// provides reports whether the file may set a field; the file can't point to another file.
+func (source *fileSource) provides(field conf.Field) bool {
+	return fileKey(field.FlagKey) != "configpath"
+}
+
+func (source *fileSource) Source(field conf.Field) (string, bool) {
+	source.fields = append(source.fields, field)
+	if !source.provides(field) {
+		return "", false
+	}
+	value, found := source.values[fileKey(field.FlagKey)]
+	return value, found
+}
+
+// ignoredKeys lists the file's keys matching no configuration field it may set, usually misspelt ones, sorted.
+func (source *fileSource) ignoredKeys() []string {
+	var known = make(map[string]bool, len(source.fields))
+	for _, field := range source.fields {
+		known[fileKey(field.FlagKey)] = source.provides(field)
+	}
+	var ignored = make([]string, 0)
+	for key := range source.values {
+		if !known[key] {
+			ignored = append(ignored, source.names[key])
+		}
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// flagNames lists the flags set by the arguments: flags precede positional arguments, unless terminated by "--", and
+// those of non-boolean fields may be followed by their value, while boolean ones are only set as "--flag=value".
+func flagNames(args []string, fields []conf.Field) map[string]bool {
+	var booleans = make(map[string]bool, len(fields))
+	for _, field := range fields {
+		booleans[strings.ToLower(strings.Join(field.FlagKey, "-"))] = field.BoolField
+	}
+	var names = make(map[string]bool)
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && args[0] != "--" {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		names[name] = true
+		args = args[1:]
+		if !hasValue && !booleans[name] && len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+			args = args[1:]
 		}
-		_ = fp.Close()
 	}
+	return names
+}
+
+// redacted replaces the values of the fields tagged with `mask`, whether set or not.
+const redacted = "<redacted>"
 
-	return cfg, nil
+// formatField prints a field's value as it would be set, joining lists by semicolons, or redacts it when it's tagged
+// with `mask`.
+func formatField(field conf.Field) string {
+	if field.Options.Mask {
+		return redacted
+	}
+	var value = fmt.Sprint(field.Field.Interface())
+	if field.Field.Kind() == reflect.Slice {
+		var items = make([]string, field.Field.Len())
+		for index := range items {
+			items[index] = fmt.Sprint(field.Field.Index(index).Interface())
+		}
+		value = strings.Join(items, ";")
+	}
+	return value
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"github.com/ardanlabs/conf"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlagNames(t *testing.T) {
+	var source = &fileSource{values: make(map[string]string), names: make(map[string]string)}
+	var cfg WebAPIConfiguration
+	if err := conf.Parse(nil, "CFG", &cfg, source); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"values follow flags", []string{"--web-api-host", ":3001", "--db-filename", "test.db"},
+			[]string{"db-filename", "web-api-host"}},
+		{"values are assigned", []string{"--web-api-host=:3001", "--debug=false"}, []string{"debug", "web-api-host"}},
+		{"boolean flags take no value", []string{"--debug", "check-config", "--db-filename", "test.db"},
+			[]string{"debug"}},
+		{"boolean flags precede flags", []string{"--debug", "--db-filename", "test.db"},
+			[]string{"db-filename", "debug"}},
+		{"positional arguments end flags", []string{"alias", "--debug"}, []string{}},
+		{"dashes end flags", []string{"--debug", "--", "--db-filename", "test.db"}, []string{"debug"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var expected = make(map[string]bool)
+			for _, name := range test.expected {
+				expected[name] = true
+			}
+			if names := flagNames(test.args, source.fields); !reflect.DeepEqual(names, expected) {
+				t.Errorf("found flags %v, rather than %v", names, expected)
+			}
+		})
+	}
+}
+
+func TestConfigurationSources(t *testing.T) {
+	var directory = t.TempDir()
+	_, sources, err := loadConfigurationSources([]string{
+		"--config-path", filepath.Join(directory, "missing.yml"),
+		"--debug",
+		"--web-tls-key-file", "/etc/kvasari/key.pem",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found = make(map[string]configurationValue)
+	for _, value := range sources.values {
+		found[value.Flag] = value
+	}
+	for flag, expected := range map[string]configurationValue{
+		"--debug":             {Flag: "--debug", Value: "true", Source: "flag"},
+		"--web-tls-key-file":  {Flag: "--web-tls-key-file", Value: "/etc/kvasari/key.pem", Source: "flag"},
+		"--web-tls-cert-file": {Flag: "--web-tls-cert-file", Value: "", Source: "default"},
+		"--db-filename":       {Flag: "--db-filename", Value: "data.db", Source: "default"},
+	} {
+		if found[flag] != expected {
+			t.Errorf("listed %+v, rather than %+v", found[flag], expected)
+		}
+	}
+}
+
+func TestFormatField(t *testing.T) {
+	var source = &fileSource{values: make(map[string]string), names: make(map[string]string)}
+	var cfg struct {
+		Host   string        `conf:"default:0.0.0.0:3000"`
+		Hosts  []string      `conf:"default:a.example.com;b.example.com"`
+		Expiry time.Duration `conf:"default:90s"`
+		Token  string        `conf:"default:hunter2,mask"`
+		Unset  string        `conf:"mask"`
+	}
+	if err := conf.Parse(nil, "CFG", &cfg, source); err != nil {
+		t.Fatal(err)
+	}
+
+	var formatted = make(map[string]string)
+	for _, field := range source.fields {
+		formatted[field.Name] = formatField(field)
+	}
+	var expected = map[string]string{
+		"Host":   "0.0.0.0:3000",
+		"Hosts":  "a.example.com;b.example.com",
+		"Expiry": "1m30s",
+		"Token":  redacted,
+		"Unset":  redacted,
+	}
+	if !reflect.DeepEqual(formatted, expected) {
+		t.Errorf("formatted %v, rather than %v", formatted, expected)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// loginFailures counts the failed logins recorded against the given subject.
+func (api *testAPI) loginFailures(kind, subject string) (failures int) {
+	api.t.Helper()
+	err := api.connection.QueryRow(`
+		SELECT coalesce(sum(failures), 0) FROM login_failures WHERE kind = ? AND subject = ?`,
+		kind, subject,
+	).Scan(&failures)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	return failures
+}
+
+func TestLoginRefusesSuspendedAccounts(t *testing.T) {
+	var api = newTestAPI(t)
+	api.register("suspended")
+	if _, err := api.connection.Exec(`UPDATE users SET suspended = '2000-01-01T00:00:00Z'`); err != nil {
+		t.Fatal(err)
+	}
+
+	// the right password mustn't be told apart from wrong ones
+	var wrong, right struct{ Message string }
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "suspended", "Password": "wrong-password",
+	}), http.StatusBadRequest, &wrong)
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "suspended", "Password": testPassword,
+	}), http.StatusBadRequest, &right)
+	if wrong.Message != right.Message {
+		t.Errorf("suspended accounts are refused with %q, rather than %q", right.Message, wrong.Message)
+	}
+
+	// both attempts count towards the lockout
+	if failures := api.loginFailures("alias", "suspended"); failures != 2 {
+		t.Errorf("recorded %d failures, rather than 2", failures)
+	}
+}
+
+func TestLoginClearsFailures(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Lockout.IPThreshold = 3
+	})
+	api.register("forgetful")
+	api.register("other")
+
+	var login = func(alias, password string, status int) {
+		t.Helper()
+		api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+			"Alias": alias, "Password": password,
+		}), status, nil)
+	}
+	login("forgetful", "wrong-password", http.StatusBadRequest)
+	login("other", "wrong-password", http.StatusBadRequest)
+	login("other", "wrong-password", http.StatusBadRequest)
+
+	// httptest requests all come from the same client
+	const ip = "192.0.2.1"
+	if failures := api.loginFailures("ip", ip); failures != 3 {
+		t.Fatalf("recorded %d failures for the client, rather than 3", failures)
+	}
+	login("forgetful", testPassword, http.StatusCreated)
+	if failures := api.loginFailures("alias", "forgetful"); failures != 0 {
+		t.Errorf("kept %d failures for the alias", failures)
+	}
+	if failures := api.loginFailures("ip", ip); failures != 0 {
+		t.Errorf("kept %d failures for the client", failures)
+	}
+
+	// the client's count restarts, so it isn't locked out at the next failure
+	login("other", "wrong-password", http.StatusBadRequest)
+	login("other", testPassword, http.StatusCreated)
+}
@@ -2,11 +2,18 @@
 Webapi is the executable for the main web server.
 It builds a web server around APIs from `service/api`.
 Webapi connects to external resources needed (database) and starts two web servers: the API web server, and the debug.
-Everything is served via the API web server, except debug variables (/debug/vars) and profiler infos (pprof).
+Everything is served via the API web server, except the list of registered routes (/debug/routes), whose server is
+only started in debug mode.
 
 Usage:
 
 	webapi [flags]
+	webapi suspend [flags] <alias>
+	webapi reinstate [flags] <alias>
+	webapi check-config [flags]
+
+The `suspend` and `reinstate` subcommands let scripts administer accounts, without starting the web server. The
+`check-config` subcommand prints the effective configuration, with the source of each value, and validates it.
 
 Flags and configurations are handled automatically by the code in `load-configuration.go`.
 
@@ -25,16 +32,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/ardanlabs/conf"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/silktrader/kvasari/pkg/artworks"
-	"github.com/silktrader/kvasari/pkg/auth"
+	"github.com/silktrader/kvasari/pkg/ntime"
 	"github.com/silktrader/kvasari/pkg/rest"
 	"github.com/silktrader/kvasari/pkg/storage/images"
 	"github.com/silktrader/kvasari/pkg/storage/sqlite"
-	"github.com/silktrader/kvasari/pkg/users"
 	"github.com/sirupsen/logrus"
 	"math/rand"
 	"net/http"
@@ -48,7 +54,15 @@ import (
 // main is the program entry point. The only purpose of this function is to call run() and set the exit code if there is
 // any error
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if action, ok := accountCommands[commandName()]; ok {
+		err = runAccountCommand(action, os.Args[2:])
+	} else if commandName() == "check-config" {
+		err = runConfigCheck(os.Args[2:])
+	} else {
+		err = run()
+	}
+	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, "error: ", err)
 		os.Exit(1)
 	}
@@ -65,7 +79,7 @@ func main() {
 func run() error {
 	rand.Seed(time.Now().UnixNano())
 	// Load Configuration and defaults
-	cfg, err := loadConfiguration()
+	cfg, err := loadConfiguration(os.Args[1:])
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			return nil
@@ -84,6 +98,11 @@ func run() error {
 
 	logger.Infof("application initializing")
 
+	// an invalid configuration is reported before any resource is acquired
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	if err = createDirectories(cfg.Images.Path, cfg.DB.Path); err != nil {
 		return fmt.Errorf("error while creating required directories: %w", err)
 	}
@@ -112,7 +131,7 @@ func run() error {
 
 	// Make a channel to listen for errors coming from the listener. Use a
 	// buffered channel so the goroutine can exit if we don't collect this error.
-	serverErrors := make(chan error, 1)
+	serverErrors := make(chan error, 2)
 
 	e, err := rest.New(rest.Config{
 		Logger: logger,
@@ -124,28 +143,100 @@ func run() error {
 	handler := e.Handler()
 
 	// setup handlers
-	var authRepository = auth.NewRepository(storage.Connection)
-	var usersRepository = users.NewRepository(storage.Connection)
-	var artworksStore = artworks.NewStore(storage.Connection, usersRepository, imageStorage)
-
-	users.RegisterHandlers(e, usersRepository, authRepository)
-	artworks.RegisterHandlers(e, artworksStore, authRepository)
+	services, err := registerHandlers(e, cfg, logger, storage, imageStorage)
+	if err != nil {
+		return err
+	}
 
 	e.ServeFiles("/static/*filepath", http.Dir("static"))
 
+	// the debug server, listing the registered routes, is only started when debugging
+	if cfg.Debug {
+		var stopDebug = serveDebug(logger, cfg.Web.DebugHost, e)
+		defer stopDebug()
+	}
+
+	// periodically purge accounts whose deletion grace period expired, along with their artworks
+	var stopMaintenance = scheduleMaintenance(logger, cfg.Maintenance.Interval, maintenanceTask{
+		name: "purge deleted accounts",
+		run: func() error {
+			var graceStart = ntime.New(time.Now().Add(-cfg.Accounts.DeletionGrace))
+			purged, err := services.artworks.PurgeDeletedAccounts(graceStart)
+			if purged > 0 {
+				logger.Infof("purged %d deleted accounts", purged)
+			}
+			return err
+		},
+	}, maintenanceTask{
+		name: "purge expired exports",
+		run: func() error {
+			purged, err := services.exports.PurgeExpiredExports(ntime.Now())
+			if purged > 0 {
+				logger.Infof("purged %d expired data exports", purged)
+			}
+			return err
+		},
+	}, maintenanceTask{
+		name: "purge abandoned uploads",
+		run: func() error {
+			purged, err := services.artworks.PurgeExpiredUploads(ntime.Now())
+			if purged > 0 {
+				logger.Infof("purged %d abandoned uploads", purged)
+			}
+			return err
+		},
+	}, maintenanceTask{
+		name: "purge stale login failures",
+		run: func() error {
+			_, err := services.users.PurgeLoginFailures(ntime.New(time.Now().Add(-cfg.Lockout.Window)), ntime.Now())
+			return err
+		},
+	})
+	defer stopMaintenance()
+
+	// replenished rate limiting buckets are dropped more frequently, as they're kept in memory
+	var stopEviction = scheduleMaintenance(logger, cfg.RateLimit.Eviction, maintenanceTask{
+		name: "evict rate limiting buckets",
+		run: func() error {
+			services.limiter.Evict()
+			return nil
+		},
+	})
+	defer stopEviction()
+
 	handler, err = registerWebUI(handler)
 	if err != nil {
 		logger.WithError(err).Error("error registering web UI handler")
 		return fmt.Errorf("registering web UI handler: %w", err)
 	}
 
-	// Apply CORS policy
-	handler = applyCORSHandler(handler)
+	// Apply CORS policy, whose preflight responses list the methods of the registered routes
+	handler, err = applyCORSHandler(handler, e, cfg)
+	if err != nil {
+		logger.WithError(err).Error("error applying the CORS policy")
+		return err
+	}
+
+	// TLS is optional, and enables HTTP/2 along with HSTS
+	var useTLS = tlsEnabled(cfg)
+	var tlsConfig *tls.Config
+	if useTLS {
+		var stopTLS func()
+		if tlsConfig, stopTLS, err = setupTLS(logger, cfg); err != nil {
+			logger.WithError(err).Error("error setting up TLS")
+			return fmt.Errorf("setting up TLS: %w", err)
+		}
+		defer stopTLS()
+		if cfg.Web.TLS.HSTSMaxAge > 0 {
+			handler = applyHSTS(handler, cfg.Web.TLS.HSTSMaxAge)
+		}
+	}
 
 	// create the API server
 	server := http.Server{
 		Addr:              cfg.Web.APIHost,
 		Handler:           handler,
+		TLSConfig:         tlsConfig,
 		ReadTimeout:       cfg.Web.ReadTimeout,
 		ReadHeaderTimeout: cfg.Web.ReadTimeout,
 		WriteTimeout:      cfg.Web.WriteTimeout,
@@ -153,11 +244,36 @@ func run() error {
 
 	// Start the service listening for requests in a separate goroutine
 	go func() {
-		logger.Infof("API listening on %s", server.Addr)
-		serverErrors <- server.ListenAndServe()
+		logger.Infof("API listening on %s, TLS enabled: %t", server.Addr, useTLS)
+		if useTLS {
+			// the certificate is provided by the TLS configuration
+			serverErrors <- server.ListenAndServeTLS("", "")
+		} else {
+			serverErrors <- server.ListenAndServe()
+		}
 		logger.Infof("stopping API server")
 	}()
 
+	// plain HTTP requests are redirected to HTTPS, when a redirect host is configured
+	if useTLS && cfg.Web.TLS.RedirectHost != "" {
+		redirect, err := redirectToHTTPS(cfg.Web.APIHost)
+		if err != nil {
+			return err
+		}
+		var redirectServer = http.Server{
+			Addr:              cfg.Web.TLS.RedirectHost,
+			Handler:           redirect,
+			ReadHeaderTimeout: cfg.Web.ReadTimeout,
+		}
+		go func() {
+			logger.Infof("redirecting HTTP requests from %s", redirectServer.Addr)
+			serverErrors <- redirectServer.ListenAndServe()
+		}()
+		defer func() {
+			_ = redirectServer.Close()
+		}()
+	}
+
 	// Waiting for shutdown signal or POSIX signals
 	select {
 	case err := <-serverErrors:
@@ -197,6 +313,23 @@ func run() error {
 	return nil
 }
 
+// serveDebug starts the debug server on the given address, returning a function to close it.
+func serveDebug(logger *logrus.Logger, address string, e rest.Engine) func() {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/debug/routes", e.ServeRoutes)
+	var server = http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		logger.Infof("debug server listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Warning("debug server error")
+		}
+	}()
+	return func() {
+		_ = server.Close()
+	}
+}
+
 // createDirectories creates required directories when missing and checks for the right permissions.
 func createDirectories(imagesPath string, dbPath string) error {
 	// won't return anything, won't have side effects when directories exist
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// maintenanceTask is a named job, periodically run by scheduleMaintenance.
+type maintenanceTask struct {
+	name string
+	run  func() error
+}
+
+// scheduleMaintenance runs the tasks in a separate goroutine, immediately and then at every interval, logging their
+// failures. The returned function stops further runs.
+func scheduleMaintenance(logger logrus.FieldLogger, interval time.Duration, tasks ...maintenanceTask) (stop func()) {
+	var done = make(chan struct{})
+	var ticker = time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			for _, task := range tasks {
+				if err := task.run(); err != nil {
+					logger.WithError(err).WithField("task", task.name).Error("maintenance task failed")
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleMaintenance(t *testing.T) {
+	var logger, hook = test.NewNullLogger()
+	var failing, succeeding atomic.Int32
+	var stop = scheduleMaintenance(logger, 10*time.Millisecond, maintenanceTask{
+		name: "failing",
+		run: func() error {
+			failing.Add(1)
+			return errors.New("failed")
+		},
+	}, maintenanceTask{
+		name: "succeeding",
+		run: func() error {
+			succeeding.Add(1)
+			return nil
+		},
+	})
+
+	// tasks run immediately, then at every interval, regardless of previous failures
+	var deadline = time.Now().Add(time.Second)
+	for succeeding.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if succeeding.Load() < 3 || failing.Load() < 3 {
+		t.Fatalf("tasks ran %d and %d times, rather than at least thrice", failing.Load(), succeeding.Load())
+	}
+
+	// failures are logged along with their task's name
+	var entry = hook.LastEntry()
+	if entry == nil || entry.Level != logrus.ErrorLevel || entry.Data["task"] != "failing" {
+		t.Errorf("the failure wasn't logged: %v", entry)
+	}
+
+	// runs stop, except for the one which may be in progress
+	time.Sleep(20 * time.Millisecond)
+	var runs = succeeding.Load()
+	time.Sleep(50 * time.Millisecond)
+	if succeeding.Load() != runs {
+		t.Error("tasks kept running after being stopped")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// mentionNotifications lists the artworks and comments whose mentions were notified to the user.
+func (api *testAPI) mentionNotifications(alias, userId string) (notifications []struct {
+	Kind    string
+	Artwork string
+	Comment *string
+}) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodGet, "/users/"+alias+"/notifications", userId, nil), http.StatusOK,
+		&notifications)
+	return notifications
+}
+
+func TestDescriptionMentions(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, friendId, otherId = api.register("author"), api.register("friend"), api.register("Other")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	var describe = func(description any) {
+		t.Helper()
+		api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/description", authorId, map[string]any{
+			"Description": description,
+		}), http.StatusNoContent, nil)
+	}
+	type mention struct {
+		UserId, Alias  string
+		Offset, Length int
+	}
+	var expectMentions = func(expected ...mention) {
+		t.Helper()
+		var artwork struct{ DescriptionMentions []mention }
+		api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", otherId, nil), http.StatusOK, &artwork)
+		if len(artwork.DescriptionMentions) != len(expected) {
+			t.Fatalf("returned mentions %+v, rather than %+v", artwork.DescriptionMentions, expected)
+		}
+		for index := range expected {
+			if artwork.DescriptionMentions[index] != expected[index] {
+				t.Errorf("returned mentions %+v, rather than %+v", artwork.DescriptionMentions, expected)
+			}
+		}
+	}
+	var expectNotified = func(alias, userId string, expected int) {
+		t.Helper()
+		var notifications = api.mentionNotifications(alias, userId)
+		if len(notifications) != expected {
+			t.Fatalf("notified %s %d times, rather than %d", alias, len(notifications), expected)
+		}
+		for _, notification := range notifications {
+			if notification.Kind != "mention" || notification.Artwork != artworkId || notification.Comment != nil {
+				t.Errorf("notified %s of %+v", alias, notification)
+			}
+		}
+	}
+
+	// mentions of missing users are ignored, while authors mentioning themselves aren't notified
+	describe("For @friend and @Other, not @other nor @author")
+	expectMentions(
+		mention{friendId, "friend", 4, 7},
+		mention{otherId, "Other", 16, 6},
+		mention{authorId, "author", 39, 7},
+	)
+	expectNotified("friend", friendId, 1)
+	expectNotified("Other", otherId, 1)
+	expectNotified("author", authorId, 0)
+
+	// users mentioned again aren't notified twice, while those no longer mentioned have their notifications withdrawn
+	describe("Only @friend")
+	expectMentions(mention{friendId, "friend", 5, 7})
+	expectNotified("friend", friendId, 1)
+	expectNotified("Other", otherId, 0)
+
+	describe(nil)
+	expectMentions()
+	expectNotified("friend", friendId, 0)
+}
+
+// comment adds a comment to an artwork, returning its ID.
+func (api *testAPI) comment(artworkId, userId, text string) string {
+	api.t.Helper()
+	var comment struct{ Id string }
+	api.expect(api.request(http.MethodPost, "/artworks/"+artworkId+"/comments", userId, map[string]string{
+		"Comment": text,
+	}), http.StatusCreated, &comment)
+	return comment.Id
+}
+
+// notificationRows counts the notifications stored for a user, including those the API would filter out.
+func (api *testAPI) notificationRows(userId string) (count int) {
+	api.t.Helper()
+	if err := api.connection.QueryRow(`SELECT count(*) FROM notifications WHERE user = ?`, userId).Scan(
+		&count); err != nil {
+		api.t.Fatal(err)
+	}
+	return count
+}
+
+func TestCommentMentions(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, commenterId = api.register("author"), api.register("commenter")
+	var mixedId, accentedId = api.register("MixedCase"), api.register("Jürgen")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	type mention struct {
+		UserId, Alias  string
+		Offset, Length int
+	}
+	var expectMentions = func(commentId string, expected ...mention) {
+		t.Helper()
+		var comments []struct {
+			Id       string
+			Mentions []mention
+		}
+		api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/comments", authorId, nil), http.StatusOK,
+			&comments)
+		for _, comment := range comments {
+			if comment.Id != commentId {
+				continue
+			}
+			if len(comment.Mentions) != len(expected) {
+				t.Fatalf("returned mentions %+v, rather than %+v", comment.Mentions, expected)
+			}
+			for index := range expected {
+				if comment.Mentions[index] != expected[index] {
+					t.Errorf("returned mentions %+v, rather than %+v", comment.Mentions, expected)
+				}
+			}
+			return
+		}
+		t.Fatalf("comment %s not found", commentId)
+	}
+	var expectNotified = func(alias, userId, commentId string, expected int) {
+		t.Helper()
+		var notifications = api.mentionNotifications(alias, userId)
+		if len(notifications) != expected {
+			t.Fatalf("notified %s %d times, rather than %d", alias, len(notifications), expected)
+		}
+		for _, notification := range notifications {
+			if notification.Artwork != artworkId || notification.Comment == nil || *notification.Comment != commentId {
+				t.Errorf("notified %s of %+v", alias, notification)
+			}
+		}
+	}
+
+	// aliases are matched as typed, in any script, and offsets count characters rather than bytes
+	var commentId = api.comment(artworkId, commenterId, "Hello @MixedCase and @Jürgen, not @mixedcase")
+	expectMentions(commentId, mention{mixedId, "MixedCase", 6, 10}, mention{accentedId, "Jürgen", 21, 7})
+	expectNotified("MixedCase", mixedId, commentId, 1)
+	expectNotified("Jürgen", accentedId, commentId, 1)
+	expectNotified("author", authorId, commentId, 0)
+
+	// edits notify users mentioned anew, withdraw the notifications of those no longer mentioned, and don't notify
+	// the others twice
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/comments/"+commentId, commenterId,
+		map[string]string{"Comment": "Now only @Jürgen and @author"}), http.StatusOK, nil)
+	expectMentions(commentId, mention{accentedId, "Jürgen", 9, 7}, mention{authorId, "author", 21, 7})
+	expectNotified("MixedCase", mixedId, commentId, 0)
+	expectNotified("Jürgen", accentedId, commentId, 1)
+	expectNotified("author", authorId, commentId, 1)
+
+	// only authors may edit their comments
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/comments/"+commentId, mixedId,
+		map[string]string{"Comment": "Hijacked by @MixedCase"}), http.StatusNotFound, nil)
+
+	// bans suppress notifications, whichever user banned the other, while mentions are still recorded
+	var banningId, bannedId = api.register("banning"), api.register("banned")
+	api.expect(api.request(http.MethodPost, "/users/banning/bans", banningId, map[string]string{
+		"TargetAlias": "commenter",
+	}), http.StatusCreated, nil)
+	api.expect(api.request(http.MethodPost, "/users/commenter/bans", commenterId, map[string]string{
+		"TargetAlias": "banned",
+	}), http.StatusCreated, nil)
+	var bannedComment = api.comment(artworkId, commenterId, "Ignoring @banning and @banned")
+	expectMentions(bannedComment, mention{banningId, "banning", 9, 8}, mention{bannedId, "banned", 22, 7})
+	if rows := api.notificationRows(banningId); rows != 0 {
+		t.Errorf("notified a user who banned the commenter %d times", rows)
+	}
+	if rows := api.notificationRows(bannedId); rows != 0 {
+		t.Errorf("notified a user banned by the commenter %d times", rows)
+	}
+
+	// nor are users mentioned anew on edits notified, when banned
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/comments/"+commentId, commenterId,
+		map[string]string{"Comment": "Now @Jürgen, @author and @banned"}), http.StatusOK, nil)
+	if rows := api.notificationRows(bannedId); rows != 0 {
+		t.Errorf("notified a user banned by the commenter %d times, on editing", rows)
+	}
+	expectNotified("author", authorId, commentId, 1)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// promote grants a user the admin role, which can't be granted through the API.
+func (api *testAPI) promote(userId string) {
+	api.t.Helper()
+	if _, err := api.connection.Exec(`UPDATE users SET role = 'admin' WHERE id = ?`, userId); err != nil {
+		api.t.Fatal(err)
+	}
+}
+
+func TestModerationRequiresAdmins(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("member")
+	var action = map[string]string{"Action": "suspend-user", "Subject": "member"}
+
+	for _, route := range []struct {
+		method, path string
+		payload      any
+	}{
+		{http.MethodGet, "/moderation/reports", nil},
+		{http.MethodGet, "/moderation/actions", nil},
+		{http.MethodPost, "/moderation/actions", action},
+	} {
+		api.expect(api.request(route.method, route.path, userId, route.payload), http.StatusForbidden, nil)
+		api.expect(api.request(route.method, route.path, "", route.payload), http.StatusUnauthorized, nil)
+	}
+
+	// the member is still active
+	api.expect(api.request(http.MethodGet, "/users/member", userId, nil), http.StatusOK, nil)
+}
+
+func TestSuspensions(t *testing.T) {
+	var api = newTestAPI(t)
+	var adminId, reporterId, offenderId = api.register("admin"), api.register("reporter"), api.register("offender")
+	api.promote(adminId)
+
+	var reports = func(status string) (listed []struct{ Id, Status string }) {
+		t.Helper()
+		api.expect(api.request(http.MethodGet, "/moderation/reports?status="+status, adminId, nil), http.StatusOK,
+			&listed)
+		return listed
+	}
+	var act = func(action, subject string, status int) {
+		t.Helper()
+		api.expect(api.request(http.MethodPost, "/moderation/actions", adminId, map[string]string{
+			"Action": action, "Subject": subject,
+		}), status, nil)
+	}
+
+	api.expect(api.request(http.MethodPost, "/reports", reporterId, map[string]string{
+		"SubjectType": "user", "Subject": "offender", "Reason": "harassment",
+	}), http.StatusCreated, nil)
+	if open := reports("open"); len(open) != 1 {
+		t.Fatalf("listed %d open reports, rather than 1", len(open))
+	}
+
+	// suspensions deny access to authenticated routes and logins, while resolving the reports on the account
+	act("suspend-user", "admin", http.StatusBadRequest)
+	act("suspend-user", "offender", http.StatusCreated)
+	act("suspend-user", "offender", http.StatusNotFound)
+	api.expect(api.request(http.MethodGet, "/users/offender", offenderId, nil), http.StatusUnauthorized, nil)
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "offender", "Password": testPassword,
+	}), http.StatusBadRequest, nil)
+	if open, resolved := reports("open"), reports("resolved"); len(open) != 0 || len(resolved) != 1 {
+		t.Errorf("listed %d open and %d resolved reports, rather than none and 1", len(open), len(resolved))
+	}
+
+	act("reinstate-user", "offender", http.StatusCreated)
+	act("reinstate-user", "offender", http.StatusNotFound)
+	api.expect(api.request(http.MethodGet, "/users/offender", offenderId, nil), http.StatusOK, nil)
+	api.expect(api.request(http.MethodPost, "/sessions", "", map[string]string{
+		"Alias": "offender", "Password": testPassword,
+	}), http.StatusCreated, nil)
+
+	// actions are audited
+	var actions []struct{ AdminAlias, Action string }
+	api.expect(api.request(http.MethodGet, "/moderation/actions", adminId, nil), http.StatusOK, &actions)
+	if len(actions) != 2 {
+		t.Fatalf("audited %d actions, rather than 2", len(actions))
+	}
+	for _, audited := range actions {
+		if audited.AdminAlias != "admin" {
+			t.Errorf("audited %+v", audited)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// mute has the user mute the target, until the expiry date when given.
+func (api *testAPI) mute(alias, userId, target string, expires *string, status int) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodPost, "/users/"+alias+"/mutes", userId, map[string]any{
+		"TargetAlias": target, "Expires": expires,
+	}), status, nil)
+}
+
+// quietened reports which of the target's contents are hidden from the user: the artwork from the stream and
+// discovery, the comment from the artwork's comments, and the mention from the notifications.
+func (api *testAPI) quietened(alias, userId, artworkId, commentId, commentedId string) (stream, discovery,
+	comment, mention bool) {
+	api.t.Helper()
+	var streamed struct{ Artworks, NewArtworks []struct{ Id string } }
+	api.expect(api.request(http.MethodGet,
+		"/users/"+alias+"/stream?since=2000-01-01T00:00:00Z&latest=2100-01-01T00:00:00Z", userId, nil),
+		http.StatusOK, &streamed)
+	var discovered []struct{ Id string }
+	api.expect(api.request(http.MethodGet, "/users/"+alias+"/discover", userId, nil), http.StatusOK, &discovered)
+	var comments []struct{ Id string }
+	api.expect(api.request(http.MethodGet, "/artworks/"+commentedId+"/comments", userId, nil), http.StatusOK,
+		&comments)
+
+	mention = true
+	for _, notification := range api.mentionNotifications(alias, userId) {
+		if notification.Comment != nil && *notification.Comment == commentId {
+			mention = false
+		}
+	}
+	return !listedIds(streamed.Artworks, streamed.NewArtworks)[artworkId], !listedIds(discovered)[artworkId],
+		!listedIds(comments)[commentId], mention
+}
+
+func TestMutes(t *testing.T) {
+	var api = newTestAPI(t)
+	var muterId, mutedId, otherId = api.register("muter"), api.register("muted"), api.register("other")
+	var artworkId = api.upload(mutedId, "muted", nil)
+	var commentedId = api.upload(muterId, "muter", nil)
+	var commentId = api.comment(commentedId, mutedId, "A comment mentioning @muter.")
+	api.follow("muter", muterId, "muted", http.StatusCreated)
+	api.follow("other", otherId, "muted", http.StatusCreated)
+
+	var expect = func(quiet bool, context string) {
+		t.Helper()
+		stream, discovery, comment, mention := api.quietened("muter", muterId, artworkId, commentId, commentedId)
+		if stream != quiet || discovery != quiet || comment != quiet || mention != quiet {
+			t.Errorf("%s, hid the artwork from the stream %t and discovery %t, the comment %t and its mention %t",
+				context, stream, discovery, comment, mention)
+		}
+	}
+	expect(false, "before muting")
+
+	// users can only manage their own mutes, and can't mute themselves
+	api.mute("muter", otherId, "muted", nil, http.StatusForbidden)
+	api.mute("muter", "", "muted", nil, http.StatusUnauthorized)
+	api.mute("muter", muterId, "muter", nil, http.StatusBadRequest)
+	api.mute("muter", muterId, "missing", nil, http.StatusNotFound)
+	api.expect(api.request(http.MethodGet, "/users/muter/mutes", otherId, nil), http.StatusForbidden, nil)
+
+	api.mute("muter", muterId, "muted", nil, http.StatusCreated)
+	expect(true, "once muted")
+	var muted []struct{ Alias string }
+	api.expect(api.request(http.MethodGet, "/users/muter/mutes", muterId, nil), http.StatusOK, &muted)
+	if len(muted) != 1 || muted[0].Alias != "muted" {
+		t.Errorf("listed the mutes %+v", muted)
+	}
+
+	// others, the muted user included, notice no change
+	if accessed, listed, streamed := api.sees(otherId, "other", "muted", artworkId); !accessed || !listed ||
+		!streamed {
+		t.Errorf("hid the artwork from others, accessed %t, listed %t, streamed %t", accessed, listed, streamed)
+	}
+	var comments []struct{ Id string }
+	api.expect(api.request(http.MethodGet, "/artworks/"+commentedId+"/comments", mutedId, nil), http.StatusOK,
+		&comments)
+	if !listedIds(comments)[commentId] {
+		t.Error("hid the comment from its author")
+	}
+
+	api.expect(api.request(http.MethodDelete, "/users/muter/mutes/muted", otherId, nil), http.StatusForbidden, nil)
+	api.expect(api.request(http.MethodDelete, "/users/muter/mutes/muted", muterId, nil), http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodDelete, "/users/muter/mutes/muted", muterId, nil), http.StatusNotFound, nil)
+	expect(false, "once unmuted")
+}
+
+func TestExpiringMutes(t *testing.T) {
+	var api = newTestAPI(t)
+	var muterId, mutedId = api.register("muter"), api.register("muted")
+	var artworkId = api.upload(mutedId, "muted", nil)
+	var commentedId = api.upload(muterId, "muter", nil)
+	var commentId = api.comment(commentedId, mutedId, "A comment mentioning @muter.")
+	api.follow("muter", muterId, "muted", http.StatusCreated)
+
+	var past, future = "2000-01-01T00:00:00Z", "2100-01-01T00:00:00Z"
+	api.mute("muter", muterId, "muted", &past, http.StatusBadRequest)
+	api.mute("muter", muterId, "muted", &future, http.StatusCreated)
+	if stream, discovery, comment, mention := api.quietened("muter", muterId, artworkId, commentId,
+		commentedId); !stream || !discovery || !comment || !mention {
+		t.Errorf("hid the artwork from the stream %t and discovery %t, the comment %t and its mention %t, "+
+			"before the mute's expiry", stream, discovery, comment, mention)
+	}
+
+	// expired mutes lapse without being lifted
+	var expired = ntime.New(time.Now().Add(-time.Second))
+	if _, err := api.connection.Exec(`UPDATE mutes SET expires = ?`, expired); err != nil {
+		t.Fatal(err)
+	}
+	if stream, discovery, comment, mention := api.quietened("muter", muterId, artworkId, commentId,
+		commentedId); stream || discovery || comment || mention {
+		t.Errorf("hid the artwork from the stream %t and discovery %t, the comment %t and its mention %t, "+
+			"after the mute's expiry", stream, discovery, comment, mention)
+	}
+	var muted []struct{ Alias string }
+	api.expect(api.request(http.MethodGet, "/users/muter/mutes", muterId, nil), http.StatusOK, &muted)
+	if len(muted) != 0 {
+		t.Errorf("listed the expired mutes %+v", muted)
+	}
+	api.expect(api.request(http.MethodDelete, "/users/muter/mutes/muted", muterId, nil), http.StatusNotFound, nil)
+}
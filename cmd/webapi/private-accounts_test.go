@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// follow requests to follow the target, expecting the given status.
+func (api *testAPI) follow(alias, userId, target string, status int) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodPost, "/users/"+alias+"/followed", userId, map[string]string{
+		"TargetAlias": target,
+	}), status, nil)
+}
+
+// sees reports whether the user can access the artwork, and finds it among the author's listed ones and the stream.
+func (api *testAPI) sees(userId, alias, author, artworkId string) (accessed, listed, streamed bool) {
+	api.t.Helper()
+	var recorder = api.request(http.MethodGet, "/artworks/"+artworkId+"/data", userId, nil)
+	accessed = recorder.Code == http.StatusOK
+
+	var artworks struct{ Requested, New []struct{ Id string } }
+	api.expect(api.request(http.MethodGet,
+		"/artworks?artist="+author+"&since=2100-01-01T00:00:00Z&latest=2000-01-01T00:00:00Z", userId, nil),
+		http.StatusOK, &artworks)
+	listed = listedIds(artworks.Requested, artworks.New)[artworkId]
+
+	var stream struct{ Artworks, NewArtworks []struct{ Id string } }
+	api.expect(api.request(http.MethodGet,
+		"/users/"+alias+"/stream?since=2000-01-01T00:00:00Z&latest=2100-01-01T00:00:00Z", userId, nil),
+		http.StatusOK, &stream)
+	streamed = listedIds(stream.Artworks, stream.NewArtworks)[artworkId]
+	return accessed, listed, streamed
+}
+
+func TestPrivateAccounts(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, requesterId, rejectedId = api.register("author"), api.register("requester"), api.register("rejected")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	var private = true
+	api.expect(api.request(http.MethodPut, "/users/author/private", authorId, map[string]*bool{"Private": &private}),
+		http.StatusNoContent, nil)
+
+	// following private accounts requires their approval, until which their artworks stay hidden
+	api.follow("requester", requesterId, "author", http.StatusAccepted)
+	api.follow("rejected", rejectedId, "author", http.StatusAccepted)
+	api.follow("requester", requesterId, "author", http.StatusBadRequest)
+	for alias, userId := range map[string]string{"requester": requesterId, "rejected": rejectedId} {
+		if accessed, listed, streamed := api.sees(userId, alias, "author", artworkId); accessed || listed || streamed {
+			t.Errorf("%s accessed %t, listed %t, streamed %t the artwork, pending approval", alias, accessed,
+				listed, streamed)
+		}
+	}
+
+	// requests are only disclosed to the account's owner
+	api.expect(api.request(http.MethodGet, "/users/author/follow-requests", requesterId, nil), http.StatusForbidden,
+		nil)
+	var requests []struct{ Alias string }
+	api.expect(api.request(http.MethodGet, "/users/author/follow-requests", authorId, nil), http.StatusOK, &requests)
+	if len(requests) != 2 {
+		t.Fatalf("listed %d follow requests, rather than 2", len(requests))
+	}
+	api.expect(api.request(http.MethodPut, "/users/author/follow-requests/requester", requesterId, nil),
+		http.StatusForbidden, nil)
+
+	api.expect(api.request(http.MethodPut, "/users/author/follow-requests/requester", authorId, nil),
+		http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodDelete, "/users/author/follow-requests/rejected", authorId, nil),
+		http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodPut, "/users/author/follow-requests/rejected", authorId, nil),
+		http.StatusNotFound, nil)
+
+	if accessed, listed, streamed := api.sees(requesterId, "requester", "author", artworkId); !accessed || !listed ||
+		!streamed {
+		t.Errorf("the approved follower accessed %t, listed %t, streamed %t the artwork", accessed, listed, streamed)
+	}
+	if accessed, listed, streamed := api.sees(rejectedId, "rejected", "author", artworkId); accessed || listed ||
+		streamed {
+		t.Errorf("the rejected requester accessed %t, listed %t, streamed %t the artwork", accessed, listed, streamed)
+	}
+
+	// accounts made public approve pending requests
+	api.follow("rejected", rejectedId, "author", http.StatusAccepted)
+	private = false
+	api.expect(api.request(http.MethodPut, "/users/author/private", authorId, map[string]*bool{"Private": &private}),
+		http.StatusNoContent, nil)
+	if accessed, listed, streamed := api.sees(rejectedId, "rejected", "author", artworkId); !accessed || !listed ||
+		!streamed {
+		t.Errorf("the approved follower accessed %t, listed %t, streamed %t the artwork", accessed, listed, streamed)
+	}
+}
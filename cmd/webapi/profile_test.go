@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProfileHidesInvisibleArtworks(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, viewerId = api.register("author"), api.register("viewer")
+	for _, visibility := range []string{"public", "unlisted", "followers", "private"} {
+		api.upload(authorId, "author", map[string]string{"visibility": visibility})
+	}
+
+	var tests = []struct {
+		name      string
+		requester string
+		expected  int
+	}{
+		{"strangers only count listed artworks", viewerId, 1},
+		{"authors count all their artworks", authorId, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var profile struct {
+				Details       struct{ ArtworksAdded int }
+				TotalArtworks int
+				Artworks      []struct{ Id string }
+			}
+			var recorder = api.request(http.MethodGet, "/users/author/profile", test.requester, nil)
+			api.expect(recorder, http.StatusOK, &profile)
+			if profile.TotalArtworks != test.expected || profile.Details.ArtworksAdded != test.expected {
+				t.Errorf("counted %d artworks, %d in details, rather than %d",
+					profile.TotalArtworks, profile.Details.ArtworksAdded, test.expected)
+			}
+
+			// the first page includes the artworks added during the current second
+			if len(profile.Artworks) != test.expected {
+				t.Errorf("listed %d artworks, rather than %d", len(profile.Artworks), test.expected)
+			}
+		})
+	}
+}
+
+func TestProfileListsCoversAndImages(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId = api.register("author")
+	var single = api.upload(authorId, "author", nil)
+	var multiple = api.upload(authorId, "author", nil)
+
+	var image struct{ Id string }
+	api.expect(api.postImage("/artworks/"+multiple+"/images", authorId, nil), http.StatusCreated, &image)
+	api.expect(api.request(http.MethodPut, "/artworks/"+multiple+"/images", authorId, map[string][]string{
+		"Images": {image.Id, multiple},
+	}), http.StatusNoContent, nil)
+
+	var profile struct {
+		Artworks []struct {
+			Id, Cover string
+			Images    int
+		}
+	}
+	api.expect(api.request(http.MethodGet, "/users/author/profile", authorId, nil), http.StatusOK, &profile)
+	var expected = map[string]struct {
+		cover  string
+		images int
+	}{single: {single, 1}, multiple: {image.Id, 2}}
+	for _, artwork := range profile.Artworks {
+		if artwork.Cover != expected[artwork.Id].cover || artwork.Images != expected[artwork.Id].images {
+			t.Errorf("listed %s with cover %s and %d images, rather than %+v",
+				artwork.Id, artwork.Cover, artwork.Images, expected[artwork.Id])
+		}
+	}
+	if len(profile.Artworks) != len(expected) {
+		t.Errorf("listed %d artworks, rather than %d", len(profile.Artworks), len(expected))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// react sets the user's reaction to the artwork, expecting the given status.
+func (api *testAPI) react(artworkId, alias, userId, reaction string, status int) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/reactions/"+alias, userId, map[string]string{
+		"Reaction": reaction,
+	}), status, nil)
+}
+
+// reactionsSummary is the breakdown of an artwork's reactions, as seen by a requester.
+type reactionsSummary struct {
+	ReactionsByType map[string]int
+	UserReaction    *string
+}
+
+// summary fetches the breakdown of the artwork's reactions, as seen by the requester.
+func (api *testAPI) summary(artworkId, userId string) reactionsSummary {
+	api.t.Helper()
+	var summary reactionsSummary
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", userId, nil), http.StatusOK, &summary)
+	return summary
+}
+
+func TestReactionKinds(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("reactor")
+	var artworkId = api.upload(userId, "reactor", nil)
+
+	var kinds []struct{ Name, Icon string }
+	api.expect(api.request(http.MethodGet, "/reactions", userId, nil), http.StatusOK, &kinds)
+	if len(kinds) != 2 || kinds[0].Name != "Like" || kinds[1].Name != "Perplexed" || kinds[0].Icon == "" {
+		t.Errorf("listed the reaction kinds %+v, rather than the default ones", kinds)
+	}
+	api.react(artworkId, "reactor", userId, "Applause", http.StatusBadRequest)
+
+	// kinds added to the database are accepted once listed again
+	if _, err := api.connection.Exec(`INSERT INTO reaction_types(name, icon, position) VALUES(?, ?, ?)`,
+		"Applause", "/static/applause.png", 2); err != nil {
+		t.Fatal(err)
+	}
+	api.expect(api.request(http.MethodGet, "/reactions", userId, nil), http.StatusOK, &kinds)
+	if len(kinds) != 3 || kinds[2].Name != "Applause" {
+		t.Errorf("listed the reaction kinds %+v, missing the added one", kinds)
+	}
+	api.react(artworkId, "reactor", userId, "Applause", http.StatusOK)
+	api.expect(api.request(http.MethodGet, "/reactions", "", nil), http.StatusUnauthorized, nil)
+}
+
+func TestReactionsSummaries(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, firstId, secondId = api.register("author"), api.register("first"), api.register("second")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	api.react(artworkId, "first", firstId, "Like", http.StatusOK)
+	api.react(artworkId, "second", secondId, "Like", http.StatusOK)
+	api.react(artworkId, "author", authorId, "Perplexed", http.StatusOK)
+
+	// users can only react on their own behalf
+	api.react(artworkId, "first", secondId, "Perplexed", http.StatusForbidden)
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/reactions/first", secondId, nil),
+		http.StatusForbidden, nil)
+
+	var summary = api.summary(artworkId, firstId)
+	if !reflect.DeepEqual(summary.ReactionsByType, map[string]int{"Like": 2, "Perplexed": 1}) ||
+		summary.UserReaction == nil || *summary.UserReaction != "Like" {
+		t.Errorf("summarised the reactions as %v, with the requester's %v", summary.ReactionsByType,
+			summary.UserReaction)
+	}
+
+	// changing reactions moves them between kinds, while setting the same one again changes nothing
+	api.react(artworkId, "first", firstId, "Perplexed", http.StatusOK)
+	var status struct{ Status string }
+	api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/reactions/first", firstId, map[string]string{
+		"Reaction": "Perplexed",
+	}), http.StatusOK, &status)
+	if status.Status != "unchanged" {
+		t.Errorf("reported the repeated reaction as %q", status.Status)
+	}
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/reactions/second", secondId, nil),
+		http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId+"/reactions/second", secondId, nil),
+		http.StatusNotFound, nil)
+	if summary = api.summary(artworkId, secondId); !reflect.DeepEqual(summary.ReactionsByType,
+		map[string]int{"Perplexed": 2}) || summary.UserReaction != nil {
+		t.Errorf("summarised the reactions as %v, with the requester's %v", summary.ReactionsByType,
+			summary.UserReaction)
+	}
+
+	var reactions []struct{ AuthorAlias, Reaction string }
+	api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/reactions", secondId, nil), http.StatusOK,
+		&reactions)
+	var byAlias = make(map[string]string, len(reactions))
+	for _, reaction := range reactions {
+		byAlias[reaction.AuthorAlias] = reaction.Reaction
+	}
+	if !reflect.DeepEqual(byAlias, map[string]string{"first": "Perplexed", "author": "Perplexed"}) {
+		t.Errorf("listed the reactions %+v", reactions)
+	}
+}
+
+func TestUserReactions(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId, userId, strangerId = api.register("author"), api.register("reactor"), api.register("stranger")
+	var first, second = api.upload(authorId, "author", nil), api.upload(authorId, "author", nil)
+	var private = api.upload(authorId, "author", map[string]string{"visibility": "private"})
+	api.react(first, "reactor", userId, "Like", http.StatusOK)
+	api.react(second, "reactor", userId, "Perplexed", http.StatusOK)
+	api.react(private, "author", authorId, "Like", http.StatusOK)
+
+	// reactions are mapped by artwork, as those added within the same second have no definite order
+	var listed = func(alias, requesterId string) map[string]string {
+		t.Helper()
+		var reactions []struct{ ArtworkId, Reaction string }
+		api.expect(api.request(http.MethodGet, "/users/"+alias+"/reactions", requesterId, nil), http.StatusOK,
+			&reactions)
+		var byArtwork = make(map[string]string, len(reactions))
+		for _, reaction := range reactions {
+			byArtwork[reaction.ArtworkId] = reaction.Reaction
+		}
+		return byArtwork
+	}
+	if reactions := listed("reactor", strangerId); !reflect.DeepEqual(reactions,
+		map[string]string{first: "Like", second: "Perplexed"}) {
+		t.Errorf("listed the reactions %v", reactions)
+	}
+
+	// artworks the requester can't see aren't disclosed, unlike the author's own
+	if reactions := listed("author", strangerId); len(reactions) != 0 {
+		t.Errorf("listed the reactions %v to a private artwork", reactions)
+	}
+	if reactions := listed("author", authorId); !reflect.DeepEqual(reactions, map[string]string{private: "Like"}) {
+		t.Errorf("listed the reactions %v, rather than the author's own", reactions)
+	}
+
+	// users banning the requester don't disclose their reactions
+	api.expect(api.request(http.MethodPost, "/users/reactor/bans", userId, map[string]string{
+		"TargetAlias": "stranger",
+	}), http.StatusCreated, nil)
+	if reactions := listed("reactor", strangerId); len(reactions) != 0 {
+		t.Errorf("listed the reactions %v to a banned requester", reactions)
+	}
+	api.expect(api.request(http.MethodGet, "/users/reactor/reactions", "", nil), http.StatusUnauthorized, nil)
+}
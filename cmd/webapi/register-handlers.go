@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/artworks"
+	"github.com/silktrader/kvasari/pkg/auth"
+	"github.com/silktrader/kvasari/pkg/exports"
+	"github.com/silktrader/kvasari/pkg/moderation"
+	"github.com/silktrader/kvasari/pkg/ratelimit"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"github.com/silktrader/kvasari/pkg/storage/images"
+	"github.com/silktrader/kvasari/pkg/storage/sqlite"
+	"github.com/silktrader/kvasari/pkg/users"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// services gathers the stores shared by the API's handlers and the maintenance tasks.
+type services struct {
+	users    users.UserRepository
+	artworks *artworks.Store
+	exports  *exports.Store
+	limiter  *ratelimit.Limiter
+}
+
+// registerHandlers creates the stores and registers the API's handlers on the engine, as configured. It's shared by
+// the web server and the API specification's check, so that the latter covers the very same routes.
+func registerHandlers(
+	e rest.Engine, cfg WebAPIConfiguration, logger *logrus.Logger, storage sqlite.Storage, imageStorage images.Storage,
+) (services, error) {
+	var authRepository = auth.NewRepository(storage.Connection)
+	var usersRepository = users.NewRepository(storage.Connection, cfg.Accounts.AliasCooldown)
+	var artworksStore = artworks.NewStore(storage.Connection, usersRepository, imageStorage)
+	var limiter = ratelimit.New(time.Now)
+
+	users.RegisterHandlers(e, usersRepository, authRepository, users.Options{
+		AliasLockout: users.LockoutPolicy{
+			Threshold: cfg.Lockout.AliasThreshold,
+			Window:    cfg.Lockout.Window,
+			BaseLock:  cfg.Lockout.BaseLock,
+			MaxLock:   cfg.Lockout.MaxLock,
+		},
+		IPLockout: users.LockoutPolicy{
+			Threshold: cfg.Lockout.IPThreshold,
+			Window:    cfg.Lockout.Window,
+			BaseLock:  cfg.Lockout.BaseLock,
+			MaxLock:   cfg.Lockout.MaxLock,
+		},
+		Limiter: limiter,
+		Registrations: ratelimit.Budget{
+			Requests: cfg.RateLimit.RegistrationRequests,
+			Period:   cfg.RateLimit.RegistrationPeriod,
+		},
+	})
+	artworks.RegisterHandlers(e, artworksStore, authRepository, artworks.Options{
+		Discovery: artworks.DiscoveryWeights{
+			Reaction:       cfg.Discovery.ReactionWeight,
+			Comment:        cfg.Discovery.CommentWeight,
+			FollowedAuthor: cfg.Discovery.FollowedBoost,
+			NetworkAuthor:  cfg.Discovery.NetworkBoost,
+			HalfLife:       cfg.Discovery.HalfLife,
+			Window:         cfg.Discovery.Window,
+		},
+		Quota: artworks.Quota{
+			Bytes:    cfg.Quota.Bytes,
+			Artworks: cfg.Quota.Artworks,
+		},
+		MinFreeBytes: cfg.Images.MinFreeBytes,
+		UploadExpiry: cfg.Images.UploadExpiry,
+		Trending: artworks.TrendingOptions{
+			Window: cfg.Tags.TrendingWindow,
+			Limit:  cfg.Tags.TrendingLimit,
+		},
+		Limiter: limiter,
+		Uploads: ratelimit.Budget{
+			Requests: cfg.RateLimit.UploadRequests,
+			Period:   cfg.RateLimit.UploadPeriod,
+		},
+		Comments: ratelimit.Budget{
+			Requests: cfg.RateLimit.CommentRequests,
+			Period:   cfg.RateLimit.CommentPeriod,
+		},
+	})
+
+	exportsStore, err := exports.NewStore(storage.Connection, artworksStore, logger, cfg.Exports.Path, cfg.Exports.Expiry)
+	if err != nil {
+		return services{}, fmt.Errorf("error initialising exports storage: %w", err)
+	}
+	exports.RegisterHandlers(e, exportsStore, authRepository)
+	moderation.RegisterHandlers(e, moderation.NewStore(storage.Connection), authRepository)
+
+	return services{usersRepository, artworksStore, exportsStore, limiter}, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// createUpload opens a resumable upload session of the given size, expecting the given status.
+func (api *testAPI) createUpload(userId string, size int, status int) (session struct{ Id string }) {
+	api.t.Helper()
+	var recorder = api.request(http.MethodPost, "/uploads", userId, map[string]int{"Size": size})
+	api.expect(recorder, status, &session)
+	return session
+}
+
+// appendChunk sends a chunk at the given offset, returning the response.
+func (api *testAPI) appendChunk(uploadId, userId string, offset int, chunk io.Reader) *httptest.ResponseRecorder {
+	var request = httptest.NewRequest(http.MethodPatch, "/uploads/"+uploadId, chunk)
+	request.Header.Set("Content-Type", "application/offset+octet-stream")
+	request.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	return api.serve(request, userId)
+}
+
+// uploadOffset queries an upload's offset, checking that its header and body agree.
+func (api *testAPI) uploadOffset(uploadId, userId string) int64 {
+	api.t.Helper()
+	var session struct{ Offset int64 }
+	var recorder = api.request(http.MethodGet, "/uploads/"+uploadId, userId, nil)
+	api.expect(recorder, http.StatusOK, &session)
+	if header := recorder.Header().Get("Upload-Offset"); header != strconv.FormatInt(session.Offset, 10) {
+		api.t.Errorf("reported offset %d, while the header is %q", session.Offset, header)
+	}
+	return session.Offset
+}
+
+// stagingPath returns the path of an upload's staging file.
+func (api *testAPI) stagingPath(uploadId string) string {
+	return filepath.Join(api.cfg.Images.Path, "staging", uploadId)
+}
+
+// failingReader yields its content, then fails as interrupted connections do.
+type failingReader struct {
+	content *bytes.Reader
+}
+
+func (reader failingReader) Read(buffer []byte) (int, error) {
+	if reader.content.Len() == 0 {
+		return 0, errors.New("connection reset")
+	}
+	return reader.content.Read(buffer)
+}
+
+func TestResumableUpload(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId, otherId = api.register("uploader"), api.register("other")
+	var image = api.image()
+	var half = len(image) / 2
+
+	var session = api.createUpload(userId, len(image), http.StatusCreated)
+	if _, err := os.Stat(api.stagingPath(session.Id)); err != nil {
+		t.Fatalf("didn't create the staging file: %v", err)
+	}
+	api.expect(api.request(http.MethodGet, "/uploads/"+session.Id, otherId, nil), http.StatusNotFound, nil)
+
+	// chunks must be sent at the upload's offset, which is reported on conflicts
+	api.expect(api.appendChunk(session.Id, userId, 0, bytes.NewReader(image[:half])), http.StatusOK, nil)
+	var conflict = api.appendChunk(session.Id, userId, 0, bytes.NewReader(image[half:]))
+	api.expect(conflict, http.StatusConflict, nil)
+	if header := conflict.Header().Get("Upload-Offset"); header != strconv.Itoa(half) {
+		t.Errorf("reported the offset %q on conflicts, rather than %d", header, half)
+	}
+
+	// incomplete uploads can't be finalised
+	api.expect(api.request(http.MethodPost, "/uploads/"+session.Id+"/artwork", userId, nil), http.StatusConflict, nil)
+
+	// interrupted chunks are kept as far as they were received
+	var partial = half + (len(image)-half)/2
+	api.expect(api.appendChunk(session.Id, userId, half, failingReader{bytes.NewReader(image[half:partial])}),
+		http.StatusBadRequest, nil)
+	if offset := api.uploadOffset(session.Id, userId); offset != int64(partial) {
+		t.Fatalf("recorded the offset %d after an interrupted chunk, rather than %d", offset, partial)
+	}
+
+	// chunks exceeding the announced size aren't written at all
+	api.expect(api.appendChunk(session.Id, userId, partial, bytes.NewReader(append(image[partial:], 0))),
+		http.StatusBadRequest, nil)
+	if offset := api.uploadOffset(session.Id, userId); offset != int64(partial) {
+		t.Fatalf("recorded the offset %d after an oversized chunk, rather than %d", offset, partial)
+	}
+
+	api.expect(api.appendChunk(session.Id, userId, partial, bytes.NewReader(image[partial:])), http.StatusOK, nil)
+	var artwork struct{ Id, Format string }
+	api.expect(api.request(http.MethodPost, "/uploads/"+session.Id+"/artwork", userId, nil), http.StatusCreated,
+		&artwork)
+	var checksum = sha256.Sum256(image)
+	if artwork.Id != hex.EncodeToString(checksum[:]) || artwork.Format != "png" {
+		t.Errorf("added the artwork %+v, rather than the image's checksum", artwork)
+	}
+	api.expect(api.request(http.MethodGet, "/artworks/"+artwork.Id+"/data", userId, nil), http.StatusOK, nil)
+
+	// finalised sessions are removed, along with their staging files
+	api.expect(api.request(http.MethodGet, "/uploads/"+session.Id, userId, nil), http.StatusNotFound, nil)
+	if _, err := os.Stat(api.stagingPath(session.Id)); !os.IsNotExist(err) {
+		t.Errorf("kept the staging file of a finalised upload: %v", err)
+	}
+
+	// uploads of the same image are refused as duplicates, and discarded
+	var duplicate = api.createUpload(userId, len(image), http.StatusCreated)
+	api.expect(api.appendChunk(duplicate.Id, userId, 0, bytes.NewReader(image)), http.StatusOK, nil)
+	api.expect(api.request(http.MethodPost, "/uploads/"+duplicate.Id+"/artwork", userId, nil),
+		http.StatusBadRequest, nil)
+	api.expect(api.request(http.MethodGet, "/uploads/"+duplicate.Id, userId, nil), http.StatusNotFound, nil)
+}
+
+func TestResumableUploadTypes(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("uploader")
+	var content = bytes.Repeat([]byte("not an image "), 100)
+
+	var session = api.createUpload(userId, len(content), http.StatusCreated)
+	var wrongType = httptest.NewRequest(http.MethodPatch, "/uploads/"+session.Id, bytes.NewReader(content))
+	wrongType.Header.Set("Content-Type", "application/octet-stream")
+	wrongType.Header.Set("Upload-Offset", "0")
+	api.expect(api.serve(wrongType, userId), http.StatusBadRequest, nil)
+
+	// complete uploads of other types are refused, and discarded since they can't be amended
+	api.expect(api.appendChunk(session.Id, userId, 0, bytes.NewReader(content)), http.StatusOK, nil)
+	api.expect(api.request(http.MethodPost, "/uploads/"+session.Id+"/artwork", userId, nil), http.StatusBadRequest,
+		nil)
+	api.expect(api.request(http.MethodGet, "/uploads/"+session.Id, userId, nil), http.StatusNotFound, nil)
+}
+
+func TestResumableUploadQuotas(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Quota.Artworks = 2
+	})
+	var userId = api.register("uploader")
+	var image = api.image()
+
+	// sessions exceeding the bytes quota are refused before any chunk is sent
+	var limited = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Quota.Bytes = int64(len(image) - 1)
+	})
+	limited.createUpload(limited.register("uploader"), len(image), http.StatusInsufficientStorage)
+
+	// quotas exceeded while uploading are reported on finalising, which may be retried once they're freed
+	var session = api.createUpload(userId, len(image), http.StatusCreated)
+	api.expect(api.appendChunk(session.Id, userId, 0, bytes.NewReader(image)), http.StatusOK, nil)
+	api.upload(userId, "uploader", nil)
+	var latest = api.upload(userId, "uploader", nil)
+	api.expect(api.request(http.MethodPost, "/uploads/"+session.Id+"/artwork", userId, nil),
+		http.StatusInsufficientStorage, nil)
+	if offset := api.uploadOffset(session.Id, userId); offset != int64(len(image)) {
+		t.Errorf("recorded the offset %d after exceeding the quota, rather than %d", offset, len(image))
+	}
+	api.createUpload(userId, len(image), http.StatusInsufficientStorage)
+
+	api.expect(api.request(http.MethodDelete, "/artworks/"+latest, userId, nil), http.StatusNoContent, nil)
+	api.expect(api.request(http.MethodPost, "/uploads/"+session.Id+"/artwork", userId, nil), http.StatusCreated,
+		nil)
+}
+
+func TestPurgeExpiredUploads(t *testing.T) {
+	var api = newTestAPI(t)
+	var userId = api.register("uploader")
+	var expired = api.createUpload(userId, 100, http.StatusCreated)
+	var pending = api.createUpload(userId, 100, http.StatusCreated)
+	var orphan = api.stagingPath("orphan")
+	if err := os.WriteFile(orphan, []byte("left behind by a crash"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.connection.Exec(`UPDATE upload_sessions SET expires = ? WHERE id = ?`,
+		ntime.New(time.Now().Add(-time.Minute)), expired.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := api.services.artworks.PurgeExpiredUploads(ntime.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d uploads, rather than 1", purged)
+	}
+	api.expect(api.request(http.MethodGet, "/uploads/"+expired.Id, userId, nil), http.StatusNotFound, nil)
+	api.uploadOffset(pending.Id, userId)
+	for path, kept := range map[string]bool{
+		api.stagingPath(expired.Id): false,
+		api.stagingPath(pending.Id): true,
+		orphan:                      false,
+	} {
+		if _, err = os.Stat(path); (err == nil) != kept {
+			t.Errorf("kept %s: %t, rather than %t", filepath.Base(path), err == nil, kept)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// storageUsage is the storage usage reported to users, along with their quotas.
+type storageUsage struct {
+	Bytes       int64
+	Artworks    int
+	MaxBytes    int64
+	MaxArtworks int
+}
+
+// usage fetches the user's storage usage.
+func (api *testAPI) usage(alias, userId string) (usage storageUsage) {
+	api.t.Helper()
+	api.expect(api.request(http.MethodGet, "/users/"+alias+"/storage", userId, nil), http.StatusOK, &usage)
+	return usage
+}
+
+// expectCode fails the test unless a response has the 507 status and the given error code.
+func (api *testAPI) expectCode(recorder *httptest.ResponseRecorder, code string) {
+	api.t.Helper()
+	var message struct{ Code string }
+	api.expect(recorder, http.StatusInsufficientStorage, &message)
+	if message.Code != code {
+		api.t.Errorf("responded with the code %q, rather than %q", message.Code, code)
+	}
+}
+
+// imageSizes returns the sizes of the first images encoded by any test API, which are the same for all of them.
+func imageSizes(t testing.TB, count int) []int64 {
+	var probe = testAPI{t: t}
+	var sizes = make([]int64, count)
+	for index := range sizes {
+		sizes[index] = int64(len(probe.image()))
+	}
+	return sizes
+}
+
+func TestStorageUsage(t *testing.T) {
+	var sizes = imageSizes(t, 3)
+	var api = newTestAPI(t)
+	var userId, otherId = api.register("uploader"), api.register("other")
+	var artworkId = api.upload(userId, "uploader", nil)
+	api.upload(userId, "uploader", nil)
+
+	var expected = storageUsage{Bytes: sizes[0] + sizes[1], Artworks: 2, MaxBytes: api.cfg.Quota.Bytes,
+		MaxArtworks: api.cfg.Quota.Artworks}
+	if usage := api.usage("uploader", userId); usage != expected {
+		t.Errorf("reported the usage %+v, rather than %+v", usage, expected)
+	}
+
+	// added images count towards their artworks' sizes, unlike their number
+	api.addImage(artworkId, userId)
+	expected.Bytes += sizes[2]
+	if usage := api.usage("uploader", userId); usage != expected {
+		t.Errorf("reported the usage %+v after adding an image, rather than %+v", usage, expected)
+	}
+	api.expect(api.request(http.MethodDelete, "/artworks/"+artworkId, userId, nil), http.StatusNoContent, nil)
+	expected.Bytes, expected.Artworks = sizes[1], 1
+	if usage := api.usage("uploader", userId); usage != expected {
+		t.Errorf("reported the usage %+v after a deletion, rather than %+v", usage, expected)
+	}
+
+	// usage is disclosed to its owner alone
+	api.expect(api.request(http.MethodGet, "/users/uploader/storage", otherId, nil), http.StatusForbidden, nil)
+	api.expect(api.request(http.MethodGet, "/users/uploader/storage", "", nil), http.StatusUnauthorized, nil)
+	if usage := api.usage("other", otherId); usage.Bytes != 0 || usage.Artworks != 0 {
+		t.Errorf("reported the usage %+v, rather than none", usage)
+	}
+}
+
+func TestStorageQuotas(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Quota.Artworks = 2
+	})
+	var userId = api.register("uploader")
+	api.upload(userId, "uploader", nil)
+	var latest = api.upload(userId, "uploader", nil)
+	api.expectCode(api.postImage("/artworks", userId, map[string]string{"alias": "uploader"}),
+		"quota-artworks-exceeded")
+
+	// deleted artworks free their share of the quota, which is kept per user
+	api.expect(api.request(http.MethodDelete, "/artworks/"+latest, userId, nil), http.StatusNoContent, nil)
+	api.upload(userId, "uploader", nil)
+	var otherId = api.register("other")
+	api.upload(otherId, "other", nil)
+
+	// bytes quotas apply to both artworks and their added images
+	var sizes = imageSizes(t, 2)
+	var limited = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Quota.Bytes = sizes[0] + sizes[1]
+	})
+	userId = limited.register("uploader")
+	var artworkId = limited.upload(userId, "uploader", nil)
+	limited.addImage(artworkId, userId)
+	limited.expectCode(limited.postImage("/artworks/"+artworkId+"/images", userId, nil), "quota-bytes-exceeded")
+	limited.expectCode(limited.postImage("/artworks", userId, map[string]string{"alias": "uploader"}),
+		"quota-bytes-exceeded")
+	if usage := limited.usage("uploader", userId); usage.Bytes != sizes[0]+sizes[1] || usage.Artworks != 1 {
+		t.Errorf("reported the usage %+v after refusing uploads over quota", usage)
+	}
+}
+
+func TestFreeSpaceGuard(t *testing.T) {
+	var api = newTestAPI(t, func(cfg *WebAPIConfiguration) {
+		cfg.Images.MinFreeBytes = math.MaxUint64 / 2
+	})
+	var userId = api.register("uploader")
+	api.expectCode(api.postImage("/artworks", userId, map[string]string{"alias": "uploader"}), "insufficient-storage")
+	if usage := api.usage("uploader", userId); usage.Bytes != 0 || usage.Artworks != 0 {
+		t.Errorf("reported the usage %+v after refusing an upload", usage)
+	}
+}
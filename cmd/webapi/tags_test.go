@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTagSources(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId = api.register("author")
+	var artworkId = api.upload(authorId, "author", nil)
+
+	var describe = func(description any) {
+		t.Helper()
+		api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/description", authorId, map[string]any{
+			"Description": description,
+		}), http.StatusNoContent, nil)
+	}
+	var tag = func(tags ...string) {
+		t.Helper()
+		if tags == nil {
+			tags = []string{}
+		}
+		api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/tags", authorId, map[string][]string{
+			"Tags": tags,
+		}), http.StatusOK, nil)
+	}
+	var expectTags = func(expected ...string) {
+		t.Helper()
+		var artwork struct{ Tags []string }
+		api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", authorId, nil), http.StatusOK, &artwork)
+		sort.Strings(artwork.Tags)
+		if len(expected) == 0 {
+			expected = []string{}
+		}
+		if !reflect.DeepEqual(artwork.Tags, expected) {
+			t.Errorf("tagged %v, rather than %v", artwork.Tags, expected)
+		}
+	}
+
+	// uploads carry no description, hence no hashtags
+	expectTags()
+
+	describe("Study in #ink for a #sketch")
+	tag("sketch", "oil")
+	expectTags("ink", "oil", "sketch")
+
+	// authors dropping their tags keep the ones still written as hashtags
+	tag()
+	expectTags("ink", "sketch")
+
+	// and vice versa
+	tag("sketch")
+	describe(nil)
+	expectTags("sketch")
+
+	tag()
+	expectTags()
+}
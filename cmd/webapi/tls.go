@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// tlsVersions maps the configurable minimum TLS versions to their identifiers; older versions are insecure.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsEnabled reports whether the configuration sets a certificate or a key, in which case both are required.
+func tlsEnabled(cfg WebAPIConfiguration) bool {
+	return cfg.Web.TLS.CertFile != "" || cfg.Web.TLS.KeyFile != ""
+}
+
+/*
+certificateStore holds the API server's certificate, which is reloaded when its files change, so that renewals don't
+require restarts. Certificates failing to load are reported, while the previous one is kept in use.
+*/
+type certificateStore struct {
+	certFile, keyFile string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+
+	// modified is the latest modification time of the files, as of the certificate's loading
+	modified time.Time
+}
+
+// newCertificateStore loads a certificate and its key, PEM encoded, failing when either is missing or invalid.
+func newCertificateStore(certFile, keyFile string) (*certificateStore, error) {
+	var store = &certificateStore{certFile: certFile, keyFile: keyFile}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files.
+func (store *certificateStore) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{store.certFile, store.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload loads the certificate and key files anew, regardless of their modification times.
+func (store *certificateStore) reload() error {
+	modified, err := store.lastModified()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(store.certFile, store.keyFile)
+	if err != nil {
+		return fmt.Errorf("can't load the TLS certificate: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.certificate = &certificate
+	store.modified = modified
+	return nil
+}
+
+// reloadIfChanged reloads the certificate when its files were modified since it was loaded, reporting whether it did.
+// Files being replaced may fail to load, until both are written.
+func (store *certificateStore) reloadIfChanged() (bool, error) {
+	modified, err := store.lastModified()
+	if err != nil {
+		return false, err
+	}
+
+	store.mutex.RLock()
+	var changed = modified.After(store.modified)
+	store.mutex.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+	return true, store.reload()
+}
+
+// getCertificate serves the current certificate to TLS handshakes.
+func (store *certificateStore) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.certificate, nil
+}
+
+// newTLSConfig configures TLS with the stored certificate and the minimum version, enabling HTTP/2.
+func newTLSConfig(minVersion string, store *certificateStore) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, rather than 1.2 or 1.3", minVersion)
+	}
+	return &tls.Config{
+		MinVersion:     version,
+		GetCertificate: store.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// applyHSTS instructs browsers to only reach the API over HTTPS, for the given duration.
+func applyHSTS(h http.Handler, maxAge time.Duration) http.Handler {
+	var value = "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Strict-Transport-Security", value)
+		h.ServeHTTP(writer, request)
+	})
+}
+
+// redirectToHTTPS permanently redirects requests to the same host and URL over HTTPS, on the API server's port, which
+// is omitted when it's the default one.
+func redirectToHTTPS(apiHost string) (http.Handler, error) {
+	_, port, err := net.SplitHostPort(apiHost)
+	if err != nil {
+		return nil, fmt.Errorf("can't determine the API server's port: %w", err)
+	}
+	if port == "" {
+		return nil, errors.New("the API server's port is missing")
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var host = strings.Trim(request.Host, "[]")
+		if hostname, _, e := net.SplitHostPort(request.Host); e == nil {
+			host = hostname
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		// 308 preserves methods and bodies, unlike 301
+		http.Redirect(writer, request, "https://"+host+request.URL.RequestURI(), http.StatusPermanentRedirect)
+	}), nil
+}
+
+/*
+setupTLS loads the configured certificate and returns the API server's TLS configuration, along with a function
+stopping the certificate's reloads, which happen when its files are modified, as checked at every reload interval, and
+on SIGHUP.
+*/
+func setupTLS(logger logrus.FieldLogger, cfg WebAPIConfiguration) (*tls.Config, func(), error) {
+	if cfg.Web.TLS.CertFile == "" || cfg.Web.TLS.KeyFile == "" {
+		return nil, nil, errors.New("both the TLS certificate and key files are required")
+	}
+	store, err := newCertificateStore(cfg.Web.TLS.CertFile, cfg.Web.TLS.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := newTLSConfig(cfg.Web.TLS.MinVersion, store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stopReloads = scheduleMaintenance(logger, cfg.Web.TLS.ReloadInterval, maintenanceTask{
+		name: "reload modified TLS certificate",
+		run: func() error {
+			reloaded, err := store.reloadIfChanged()
+			if reloaded && err == nil {
+				logger.Info("reloaded the modified TLS certificate")
+			}
+			return err
+		},
+	})
+
+	var hangup = make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			if err := store.reload(); err != nil {
+				logger.WithError(err).Error("can't reload the TLS certificate on SIGHUP")
+			} else {
+				logger.Info("reloaded the TLS certificate on SIGHUP")
+			}
+		}
+	}()
+
+	return config, func() {
+		stopReloads()
+		signal.Stop(hangup)
+		close(hangup)
+	}, nil
+}
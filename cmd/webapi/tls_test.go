@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for localhost, named after the common name, along with its key,
+// returning the certificate itself for clients to trust.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	var keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+// touch sets the modification time of files, since rewrites may happen within the file system's time resolution.
+func touch(t *testing.T, modified time.Time, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// servedName returns the common name of the certificate served by the store.
+func servedName(t *testing.T, store *certificateStore) string {
+	t.Helper()
+	certificate, err := store.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateStoreReloads(t *testing.T) {
+	var directory = t.TempDir()
+	var certFile, keyFile = filepath.Join(directory, "cert.pem"), filepath.Join(directory, "key.pem")
+	writeCertificate(t, certFile, keyFile, "first")
+	var loaded = time.Now().Add(-time.Hour)
+	touch(t, loaded, certFile, keyFile)
+
+	store, err := newCertificateStore(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, store); name != "first" {
+		t.Fatalf("served the %q certificate", name)
+	}
+
+	// unchanged files aren't reloaded
+	if reloaded, e := store.reloadIfChanged(); reloaded || e != nil {
+		t.Errorf("reloaded unchanged files: %t, %v", reloaded, e)
+	}
+
+	// rewritten files are, once their modification times change
+	writeCertificate(t, certFile, keyFile, "renewed")
+	touch(t, loaded, certFile, keyFile)
+	if reloaded, _ := store.reloadIfChanged(); reloaded {
+		t.Error("reloaded files whose modification times didn't change")
+	}
+	touch(t, loaded.Add(time.Minute), certFile, keyFile)
+	if reloaded, e := store.reloadIfChanged(); !reloaded || e != nil {
+		t.Errorf("didn't reload modified files: %t, %v", reloaded, e)
+	}
+	if name := servedName(t, store); name != "renewed" {
+		t.Errorf("served the %q certificate, rather than the renewed one", name)
+	}
+
+	// invalid files are reported, while the previous certificate is kept in use
+	if err = os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, loaded.Add(2*time.Minute), certFile)
+	if _, e := store.reloadIfChanged(); e == nil {
+		t.Error("loaded an invalid certificate")
+	}
+	if name := servedName(t, store); name != "renewed" {
+		t.Errorf("served the %q certificate, rather than the previous one", name)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	var directory = t.TempDir()
+	var certFile, keyFile = filepath.Join(directory, "cert.pem"), filepath.Join(directory, "key.pem")
+	var certificate = writeCertificate(t, certFile, keyFile, "localhost")
+	store, err := newCertificateStore(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = newTLSConfig("1.1", store); err == nil {
+		t.Error("accepted TLS 1.1")
+	}
+	config, err := newTLSConfig("1.3", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("the minimum version is %x, rather than TLS 1.3", config.MinVersion)
+	}
+
+	// handshakes negotiate HTTP/2 with clients offering it
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	go func() {
+		if connection, e := listener.Accept(); e == nil {
+			_ = connection.(*tls.Conn).Handshake()
+			_ = connection.Close()
+		}
+	}()
+
+	var roots = x509.NewCertPool()
+	roots.AddCert(certificate)
+	connection, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		RootCAs:    roots,
+		ServerName: "localhost",
+		NextProtos: []string{"h2", "http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = connection.Close()
+	}()
+	var state = connection.ConnectionState()
+	if state.NegotiatedProtocol != "h2" {
+		t.Errorf("negotiated %q, rather than h2", state.NegotiatedProtocol)
+	}
+	if state.Version != tls.VersionTLS13 {
+		t.Errorf("negotiated version %x, rather than TLS 1.3", state.Version)
+	}
+}
+
+func TestApplyHSTS(t *testing.T) {
+	var handler = applyHSTS(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusTeapot)
+	}), 365*24*time.Hour)
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusTeapot {
+		t.Errorf("responded %d, rather than passing the request along", recorder.Code)
+	}
+	if value := recorder.Header().Get("Strict-Transport-Security"); value != "max-age=31536000" {
+		t.Errorf("the Strict-Transport-Security header is %q", value)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	var tests = []struct {
+		name     string
+		apiHost  string
+		method   string
+		host     string
+		target   string
+		location string
+	}{
+		{"default ports are omitted", ":443", http.MethodGet, "example.com", "/users?page=2",
+			"https://example.com/users?page=2"},
+		{"other ports are kept", "0.0.0.0:8443", http.MethodGet, "example.com:8080", "/artworks",
+			"https://example.com:8443/artworks"},
+		{"methods are preserved", ":443", http.MethodPost, "example.com", "/sessions", "https://example.com/sessions"},
+		{"IPv6 hosts are bracketed", ":8443", http.MethodGet, "[::1]:8080", "/", "https://[::1]:8443/"},
+		{"bare IPv6 hosts are bracketed", ":8443", http.MethodGet, "[::1]", "/", "https://[::1]:8443/"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, err := redirectToHTTPS(test.apiHost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var request = httptest.NewRequest(test.method, test.target, nil)
+			request.Host = test.host
+			var recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusPermanentRedirect {
+				t.Errorf("responded %d, rather than 308", recorder.Code)
+			}
+			if location := recorder.Header().Get("Location"); location != test.location {
+				t.Errorf("redirected to %q, rather than %q", location, test.location)
+			}
+		})
+	}
+
+	if _, err := redirectToHTTPS("localhost"); err == nil {
+		t.Error("accepted an API host lacking a port")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkImageSize approaches the 40MiB upload limit, leaving room for the image's header.
+const benchmarkImageSize = 40<<20 - 1024
+
+// uploadBody encodes an upload form whose image is a PNG header followed by zeros, returning the whole body along
+// with the image's bytes, which share its memory, so that images can be altered without encoding forms again.
+func uploadBody(tb testing.TB, alias string) (body []byte, image []byte, contentType string) {
+	var buffer bytes.Buffer
+	var writer = multipart.NewWriter(&buffer)
+	_ = writer.WriteField("alias", alias)
+	part, err := writer.CreateFormFile("image", "benchmark.png")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var offset = buffer.Len()
+	var content = make([]byte, benchmarkImageSize)
+	copy(content, "\x89PNG\r\n\x1a\n")
+	_, _ = part.Write(content)
+	_ = writer.Close()
+
+	body = buffer.Bytes()
+	return body, body[offset : offset+benchmarkImageSize], writer.FormDataContentType()
+}
+
+// parsedUpload replicates how uploads were handled before being streamed: the form is parsed in memory, then the
+// image is read thrice, to detect its type, hash it and write it. Artworks aren't registered, which favours it.
+func parsedUpload(imagesPath string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := request.ParseMultipartForm(40 << 20); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// servers remove forms' temporary files once handlers return, while tests serve requests directly
+		defer func() {
+			_ = request.MultipartForm.RemoveAll()
+		}()
+
+		file, _, err := request.FormFile("image")
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		var header = make([]byte, 512)
+		if _, err = file.Read(header); err != nil || http.DetectContentType(header) != "image/png" {
+			http.Error(writer, "invalid image", http.StatusBadRequest)
+			return
+		}
+
+		var hash = sha256.New()
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			_, err = io.Copy(hash, file)
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		stored, err := os.Create(filepath.Join(imagesPath, hex.EncodeToString(hash.Sum(nil))+".png"))
+		if err == nil {
+			_, err = io.Copy(stored, file)
+			_ = stored.Close()
+		}
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.WriteHeader(http.StatusCreated)
+	}
+}
+
+/*
+BenchmarkUploadArtwork compares the streamed uploads of addArtwork with the previous approach, which parsed forms in
+memory, for images close to the size limit. Run it with:
+
+	go test ./cmd/webapi -run '^$' -bench UploadArtwork
+
+Each iteration alters the image, lest it be refused as a duplicate, and removes the stored file, so that disk usage
+doesn't grow with the iterations.
+*/
+func BenchmarkUploadArtwork(b *testing.B) {
+	var api = newTestAPI(b, func(cfg *WebAPIConfiguration) {
+		cfg.Images.MinFreeBytes = 0
+		cfg.Quota.Bytes = 0
+		cfg.Quota.Artworks = 0
+		cfg.RateLimit.UploadRequests = 0
+	})
+	var userId = api.register("benchmark")
+	body, image, contentType := uploadBody(b, "benchmark")
+
+	var upload = func(b *testing.B, handler http.Handler, iteration int) {
+		binary.BigEndian.PutUint64(image[16:], uint64(iteration))
+		var request = httptest.NewRequest(http.MethodPost, "/artworks", bytes.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		request.Header.Set("Authorization", "Bearer "+userId)
+		var recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusCreated {
+			b.Fatalf("responded %d: %s", recorder.Code, recorder.Body.String())
+		}
+
+		b.StopTimer()
+		var checksum = sha256.Sum256(image)
+		if err := os.Remove(filepath.Join(api.cfg.Images.Path, hex.EncodeToString(checksum[:])+".png")); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+
+	var iterations int
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			iterations++
+			upload(b, api.handler, iterations)
+		}
+	})
+	b.Run("parsed", func(b *testing.B) {
+		var handler = parsedUpload(api.cfg.Images.Path)
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			iterations++
+			upload(b, handler, iterations)
+		}
+	})
+}
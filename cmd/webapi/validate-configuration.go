@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configurationErrors aggregates the problems found in a configuration, so that all of them are reported at once.
+type configurationErrors []error
+
+func (errs configurationErrors) Error() string {
+	var messages = make([]string, len(errs))
+	for index, err := range errs {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+/*
+Validate reports every problem found in the configuration, rather than only the first one, which would otherwise
+surface at runtime, if at all:
+
+  - timeouts, intervals and periods which must be positive, and limits which can't be negative
+  - malformed listening addresses
+  - the CORS policy and, when enabled, the TLS settings and certificate files
+  - storage paths which can't be written, or are shared by the database, images and exports
+
+Directories are only probed for writing, by creating and removing a temporary file in them, or in their closest
+existing parent.
+*/
+func (cfg WebAPIConfiguration) Validate() error {
+	var errs = make(configurationErrors, 0)
+	var fail = func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for flag, duration := range map[string]time.Duration{
+		"--web-read-timeout":               cfg.Web.ReadTimeout,
+		"--web-write-timeout":              cfg.Web.WriteTimeout,
+		"--web-shutdown-timeout":           cfg.Web.ShutdownTimeout,
+		"--images-upload-expiry":           cfg.Images.UploadExpiry,
+		"--exports-expiry":                 cfg.Exports.Expiry,
+		"--lockout-window":                 cfg.Lockout.Window,
+		"--lockout-base-lock":              cfg.Lockout.BaseLock,
+		"--lockout-max-lock":               cfg.Lockout.MaxLock,
+		"--rate-limit-upload-period":       cfg.RateLimit.UploadPeriod,
+		"--rate-limit-comment-period":      cfg.RateLimit.CommentPeriod,
+		"--rate-limit-registration-period": cfg.RateLimit.RegistrationPeriod,
+		"--rate-limit-eviction":            cfg.RateLimit.Eviction,
+		"--maintenance-interval":           cfg.Maintenance.Interval,
+		"--discovery-half-life":            cfg.Discovery.HalfLife,
+		"--discovery-window":               cfg.Discovery.Window,
+		"--tags-trending-window":           cfg.Tags.TrendingWindow,
+	} {
+		if duration <= 0 {
+			fail("%s must be positive, rather than %s", flag, duration)
+		}
+	}
+	for flag, duration := range map[string]time.Duration{
+		"--accounts-deletion-grace": cfg.Accounts.DeletionGrace,
+		"--accounts-alias-cooldown": cfg.Accounts.AliasCooldown,
+	} {
+		if duration < 0 {
+			fail("%s can't be negative, rather than %s", flag, duration)
+		}
+	}
+	if cfg.Lockout.MaxLock < cfg.Lockout.BaseLock {
+		fail("--lockout-max-lock can't be shorter than --lockout-base-lock")
+	}
+
+	// zero quotas and rate limits disable them
+	for flag, limit := range map[string]int64{
+		"--quota-bytes":                      cfg.Quota.Bytes,
+		"--quota-artworks":                   int64(cfg.Quota.Artworks),
+		"--lockout-alias-threshold":          int64(cfg.Lockout.AliasThreshold),
+		"--lockout-ip-threshold":             int64(cfg.Lockout.IPThreshold),
+		"--rate-limit-upload-requests":       int64(cfg.RateLimit.UploadRequests),
+		"--rate-limit-comment-requests":      int64(cfg.RateLimit.CommentRequests),
+		"--rate-limit-registration-requests": int64(cfg.RateLimit.RegistrationRequests),
+		"--tags-trending-limit":              int64(cfg.Tags.TrendingLimit),
+	} {
+		if limit < 0 {
+			fail("%s can't be negative, rather than %d", flag, limit)
+		}
+	}
+	for flag, weight := range map[string]float64{
+		"--discovery-reaction-weight": cfg.Discovery.ReactionWeight,
+		"--discovery-comment-weight":  cfg.Discovery.CommentWeight,
+		"--discovery-followed-boost":  cfg.Discovery.FollowedBoost,
+		"--discovery-network-boost":   cfg.Discovery.NetworkBoost,
+	} {
+		if weight < 0 {
+			fail("%s can't be negative, rather than %v", flag, weight)
+		}
+	}
+
+	var addresses = map[string]string{"--web-api-host": cfg.Web.APIHost}
+	if cfg.Debug {
+		addresses["--web-debug-host"] = cfg.Web.DebugHost
+	}
+	if cfg.Web.TLS.RedirectHost != "" {
+		addresses["--web-tls-redirect-host"] = cfg.Web.TLS.RedirectHost
+	}
+	for flag, address := range addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			fail("%s isn't a valid address: %w", flag, err)
+		}
+	}
+
+	if err := corsPolicy(cfg).Validate(); err != nil {
+		fail("invalid CORS policy: %w", err)
+	}
+	errs = append(errs, cfg.validateTLS()...)
+	errs = append(errs, cfg.validatePaths()...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	// maps are iterated randomly, while reports should be stable
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
+
+// validateTLS checks the TLS settings, when TLS is enabled, including the certificate files' readability.
+func (cfg WebAPIConfiguration) validateTLS() []error {
+	var errs = make([]error, 0)
+	if !tlsEnabled(cfg) {
+		if cfg.Web.TLS.RedirectHost != "" {
+			errs = append(errs, errors.New("--web-tls-redirect-host requires TLS to be enabled"))
+		}
+		return errs
+	}
+
+	for flag, path := range map[string]string{
+		"--web-tls-cert-file": cfg.Web.TLS.CertFile,
+		"--web-tls-key-file":  cfg.Web.TLS.KeyFile,
+	} {
+		if path == "" {
+			errs = append(errs, fmt.Errorf("%s is required when TLS is enabled", flag))
+		} else if file, err := os.Open(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s can't be read: %w", flag, err))
+		} else {
+			_ = file.Close()
+		}
+	}
+	if _, ok := tlsVersions[cfg.Web.TLS.MinVersion]; !ok {
+		errs = append(errs, fmt.Errorf("--web-tls-min-version must be 1.2 or 1.3, rather than %q",
+			cfg.Web.TLS.MinVersion))
+	}
+	if cfg.Web.TLS.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--web-tls-reload-interval must be positive, rather than %s",
+			cfg.Web.TLS.ReloadInterval))
+	}
+	if cfg.Web.TLS.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("--web-tls-hsts-max-age can't be negative, rather than %s",
+			cfg.Web.TLS.HSTSMaxAge))
+	}
+	if cfg.Web.TLS.RedirectHost == cfg.Web.APIHost {
+		errs = append(errs, errors.New("--web-tls-redirect-host can't be the same as --web-api-host"))
+	}
+	return errs
+}
+
+// validatePaths checks that the storage directories are distinct and writable, and that the database's filename is
+// a plain name.
+func (cfg WebAPIConfiguration) validatePaths() []error {
+	var errs = make([]error, 0)
+	if cfg.DB.Filename == "" || filepath.Base(cfg.DB.Filename) != cfg.DB.Filename {
+		errs = append(errs, fmt.Errorf("--db-filename must be a file name, rather than %q", cfg.DB.Filename))
+	}
+
+	var flags = []string{"--db-path", "--images-path", "--exports-path"}
+	var paths = make(map[string]string, len(flags))
+	for index, path := range []string{cfg.DB.Path, cfg.Images.Path, cfg.Exports.Path} {
+		if path == "" {
+			errs = append(errs, fmt.Errorf("%s is required", flags[index]))
+			continue
+		}
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s can't be resolved: %w", flags[index], err))
+			continue
+		}
+		if other, found := paths[absolute]; found {
+			errs = append(errs, fmt.Errorf("%s can't be the same directory as %s", flags[index], other))
+			continue
+		}
+		paths[absolute] = flags[index]
+		if err = probeWritable(absolute); err != nil {
+			errs = append(errs, fmt.Errorf("%s isn't writable: %w", flags[index], err))
+		}
+	}
+	return errs
+}
+
+// probeWritable checks whether a directory, or its closest existing parent when it's missing, can be written to.
+func probeWritable(path string) error {
+	var directory = path
+	for {
+		info, err := os.Stat(directory)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s isn't a directory", directory)
+			}
+			break
+		}
+		if !os.IsNotExist(err) || filepath.Dir(directory) == directory {
+			return err
+		}
+		directory = filepath.Dir(directory)
+	}
+
+	file, err := os.CreateTemp(directory, ".kvasari-probe-")
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
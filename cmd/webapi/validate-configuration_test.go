@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// validConfiguration returns the default configuration, storing data in a scratch directory.
+func validConfiguration(t *testing.T) WebAPIConfiguration {
+	t.Helper()
+	var directory = t.TempDir()
+	cfg, err := loadConfiguration([]string{"--config-path", filepath.Join(directory, "missing.yml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DB.Path = directory
+	cfg.Images.Path = filepath.Join(directory, "images")
+	cfg.Exports.Path = filepath.Join(directory, "exports")
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfiguration(t).Validate(); err != nil {
+		t.Fatalf("refused the default configuration: %v", err)
+	}
+
+	var tests = []struct {
+		name      string
+		configure func(cfg *WebAPIConfiguration)
+		expected  []string
+	}{
+		{
+			name:      "zero timeouts",
+			configure: func(cfg *WebAPIConfiguration) { cfg.Web.ReadTimeout = 0 },
+			expected:  []string{"--web-read-timeout must be positive, rather than 0s"},
+		},
+		{
+			name:      "negative timeouts",
+			configure: func(cfg *WebAPIConfiguration) { cfg.Web.ShutdownTimeout = -time.Second },
+			expected:  []string{"--web-shutdown-timeout must be positive, rather than -1s"},
+		},
+		{
+			name:      "shared paths",
+			configure: func(cfg *WebAPIConfiguration) { cfg.Images.Path = cfg.DB.Path + string(filepath.Separator) },
+			expected:  []string{"--images-path can't be the same directory as --db-path"},
+		},
+		{
+			name: "certificates lacking keys",
+			configure: func(cfg *WebAPIConfiguration) {
+				cfg.Web.TLS.CertFile = filepath.Join(cfg.DB.Path, "cert.pem")
+				if err := os.WriteFile(cfg.Web.TLS.CertFile, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expected: []string{"--web-tls-key-file is required when TLS is enabled"},
+		},
+		{
+			name: "several problems",
+			configure: func(cfg *WebAPIConfiguration) {
+				cfg.Web.WriteTimeout = 0
+				cfg.Lockout.IPThreshold = -1
+				cfg.Exports.Path = cfg.Images.Path
+				cfg.Web.APIHost = "localhost"
+				cfg.Web.TLS.RedirectHost = ":80"
+			},
+			expected: []string{
+				"--exports-path can't be the same directory as --images-path",
+				"--lockout-ip-threshold can't be negative, rather than -1",
+				"--web-api-host isn't a valid address: address localhost: missing port in address",
+				"--web-tls-redirect-host requires TLS to be enabled",
+				"--web-write-timeout must be positive, rather than 0s",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg = validConfiguration(t)
+			test.configure(&cfg)
+
+			var errs configurationErrors
+			if err := cfg.Validate(); !errors.As(err, &errs) {
+				t.Fatalf("returned %v, rather than the configuration's errors", err)
+			}
+			var messages = make([]string, len(errs))
+			for index, err := range errs {
+				messages[index] = err.Error()
+			}
+			if !reflect.DeepEqual(messages, test.expected) {
+				t.Errorf("reported %q, rather than %q", messages, test.expected)
+			}
+			if !sort.StringsAreSorted(messages) {
+				t.Errorf("reported unsorted errors %q", messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// listedIds gathers the IDs of the artworks listed by a response, among the requested and new ones.
+func listedIds(lists ...[]struct{ Id string }) map[string]bool {
+	var ids = make(map[string]bool)
+	for _, list := range lists {
+		for _, artwork := range list {
+			ids[artwork.Id] = true
+		}
+	}
+	return ids
+}
+
+func TestArtworkVisibility(t *testing.T) {
+	var api = newTestAPI(t)
+	var authorId = api.register("author")
+	var requesters = map[string]string{
+		"author":   authorId,
+		"follower": api.register("follower"),
+		"stranger": api.register("stranger"),
+		"banned":   api.register("banned"),
+	}
+	api.expect(api.request(http.MethodPost, "/users/follower/followed", requesters["follower"], map[string]string{
+		"TargetAlias": "author",
+	}), http.StatusCreated, nil)
+	api.expect(api.request(http.MethodPost, "/users/author/bans", authorId, map[string]string{
+		"TargetAlias": "banned",
+	}), http.StatusCreated, nil)
+
+	// each artwork has a comment and a reaction by its author
+	var artworks = make(map[string]string)
+	for _, visibility := range []string{"public", "followers", "unlisted", "private"} {
+		var artworkId = api.upload(authorId, "author", map[string]string{"visibility": visibility})
+		api.comment(artworkId, authorId, "A comment by the author")
+		api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/reactions/author", authorId,
+			map[string]string{"Reaction": "Like"}), http.StatusOK, nil)
+		artworks[visibility] = artworkId
+	}
+
+	var tests = []struct {
+		requester  string
+		visibility string
+		visible    bool
+		listed     bool
+	}{
+		{"author", "public", true, true},
+		{"author", "followers", true, true},
+		{"author", "unlisted", true, true},
+		{"author", "private", true, true},
+		{"follower", "public", true, true},
+		{"follower", "followers", true, true},
+		{"follower", "unlisted", true, false},
+		{"follower", "private", false, false},
+		{"stranger", "public", true, true},
+		{"stranger", "followers", false, false},
+		{"stranger", "unlisted", true, false},
+		{"stranger", "private", false, false},
+		{"banned", "public", false, false},
+		{"banned", "followers", false, false},
+		{"banned", "unlisted", false, false},
+		{"banned", "private", false, false},
+	}
+
+	// reads precede writes, which would otherwise change the comments and reactions counted
+	for _, test := range tests {
+		t.Run(test.requester+" reads "+test.visibility, func(t *testing.T) {
+			var api = api.with(t)
+			var requesterId, artworkId = requesters[test.requester], artworks[test.visibility]
+			var status, entries = http.StatusNotFound, 0
+			if test.visible {
+				status, entries = http.StatusOK, 1
+			}
+			api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/data", requesterId, nil), status, nil)
+
+			var comments, reactions []struct{ AuthorAlias string }
+			api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/comments", requesterId, nil),
+				http.StatusOK, &comments)
+			api.expect(api.request(http.MethodGet, "/artworks/"+artworkId+"/reactions", requesterId, nil),
+				http.StatusOK, &reactions)
+			if len(comments) != entries || len(reactions) != entries {
+				t.Errorf("returned %d comments and %d reactions, rather than %d", len(comments), len(reactions),
+					entries)
+			}
+
+			var profile struct{ Requested, New []struct{ Id string } }
+			api.expect(api.request(http.MethodGet,
+				"/artworks?artist=author&since=2100-01-01T00:00:00Z&latest=2000-01-01T00:00:00Z", requesterId, nil),
+				http.StatusOK, &profile)
+			if listed := listedIds(profile.Requested, profile.New)[artworkId]; listed != test.listed {
+				t.Errorf("listed the artwork among the author's: %t, rather than %t", listed, test.listed)
+			}
+
+			// only followers' streams include the author's artworks
+			var stream struct{ Artworks, NewArtworks []struct{ Id string } }
+			api.expect(api.request(http.MethodGet, "/users/"+test.requester+
+				"/stream?since=2000-01-01T00:00:00Z&latest=2100-01-01T00:00:00Z", requesterId, nil),
+				http.StatusOK, &stream)
+			var streamed = listedIds(stream.Artworks, stream.NewArtworks)[artworkId]
+			if expected := test.listed && test.requester == "follower"; streamed != expected {
+				t.Errorf("streamed the artwork: %t, rather than %t", streamed, expected)
+			}
+		})
+	}
+
+	for _, test := range tests {
+		t.Run(test.requester+" writes "+test.visibility, func(t *testing.T) {
+			var api = api.with(t)
+			var requesterId, artworkId = requesters[test.requester], artworks[test.visibility]
+			var commented, reacted = http.StatusNotFound, http.StatusNotFound
+			if test.visible {
+				commented, reacted = http.StatusCreated, http.StatusOK
+			}
+			api.expect(api.request(http.MethodPost, "/artworks/"+artworkId+"/comments", requesterId,
+				map[string]string{"Comment": "A comment by the " + test.requester}), commented, nil)
+			api.expect(api.request(http.MethodPut, "/artworks/"+artworkId+"/reactions/"+test.requester, requesterId,
+				map[string]string{"Reaction": "Perplexed"}), reacted, nil)
+		})
+	}
+}
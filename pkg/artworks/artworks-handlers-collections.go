@@ -0,0 +1,151 @@
+package artworks
+
+import (
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+)
+
+// getCollections handles the authenticated GET "/users/:alias/collections" route, listing the collections of a user
+// which the requester may see.
+func getCollections(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		collections, err := ar.GetCollections(GetParam(request, "alias"), auth.MustGetUser(request).Id)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		JSON.Ok(writer, collections)
+	}
+}
+
+// getCollection handles the authenticated GET "/users/:alias/collections/:collectionId" route, providing a collection
+// along with the artworks the requester may see.
+func getCollection(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var collectionId, alias = GetParam(request, "collectionId"), GetParam(request, "alias")
+		switch collection, err := ar.GetCollection(collectionId, alias, auth.MustGetUser(request).Id); {
+		case err == nil:
+			JSON.Ok(writer, collection)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// createCollection handles the authenticated POST "/users/:alias/collections" route.
+func createCollection(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[CollectionData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		collection, err := ar.CreateCollection(auth.MustGetUser(request).Id, data)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		JSON.Created(writer, collection)
+	}
+}
+
+// updateCollection handles the authenticated PUT "/users/:alias/collections/:collectionId" route, replacing a
+// collection's name, description and visibility.
+func updateCollection(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[CollectionData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		switch err = ar.UpdateCollection(GetParam(request, "collectionId"), auth.MustGetUser(request).Id, data); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// deleteCollection handles the authenticated DELETE "/users/:alias/collections/:collectionId" route.
+func deleteCollection(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch err := ar.DeleteCollection(GetParam(request, "collectionId"), auth.MustGetUser(request).Id); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// addCollectionEntry handles the authenticated PUT "/users/:alias/collections/:collectionId/artworks/:artworkId"
+// route, appending an artwork to a collection.
+func addCollectionEntry(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var collectionId, artworkId = GetParam(request, "collectionId"), GetParam(request, "artworkId")
+		switch err := ar.AddCollectionEntry(collectionId, auth.MustGetUser(request).Id, artworkId); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection or artwork not found")
+		case errors.Is(err, ErrCollectionFull):
+			JSON.BadRequestWithMessage(writer, fmt.Sprintf("Collections can list up to %d artworks.",
+				maxCollectionEntries))
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// removeCollectionEntry handles the authenticated DELETE "/users/:alias/collections/:collectionId/artworks/:artworkId"
+// route.
+func removeCollectionEntry(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var collectionId, artworkId = GetParam(request, "collectionId"), GetParam(request, "artworkId")
+		switch err := ar.RemoveCollectionEntry(collectionId, auth.MustGetUser(request).Id, artworkId); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection entry not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// sortCollection handles the authenticated PUT "/users/:alias/collections/:collectionId/artworks" route, moving the
+// listed artworks ahead of the collection's remaining ones.
+func sortCollection(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[SortCollectionData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var collectionId = GetParam(request, "collectionId")
+		switch err = ar.SortCollection(collectionId, auth.MustGetUser(request).Id, data.Artworks); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Collection not found")
+		case errors.Is(err, ErrCollectionOrder):
+			JSON.BadRequestWithMessage(writer, "List the collection's artworks once each, in the desired order.")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
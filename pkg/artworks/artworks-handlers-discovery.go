@@ -0,0 +1,119 @@
+package artworks
+
+import (
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// discoveryPageSize matches the stream's page size, so that clients can render both feeds alike.
+const discoveryPageSize = 12
+
+// getDiscovery handles the authenticated GET "/users/:alias/discover?page=number" route, which ranks recent
+// artworks authored by anyone, rather than followed users only. The clock determines the candidates' ages.
+func getDiscovery(ar Storer, weights DiscoveryWeights, clock func() time.Time) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		page, err := getPage(request.URL.Query(), maxDiscoveryPage)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var now = clock()
+		candidates, err := ar.GetDiscoveryCandidates(user.Id, ntime.New(now.Add(-weights.Window)))
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		// reactions breakdowns are only fetched for the artworks being sent
+		var artworks = paginateDiscovery(rankDiscovery(candidates, weights, now), page)
+		var ids = make([]string, len(artworks))
+		for index, artwork := range artworks {
+			ids[index] = artwork.Id
+		}
+		summaries, err := ar.GetReactionsSummaries(ids, user.Id)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		for index := range artworks {
+			artworks[index].ReactionsSummary = summaries[artworks[index].Id]
+		}
+
+		JSON.Ok(writer, artworks)
+	}
+}
+
+// rankDiscovery sorts candidates by descending score, as of the provided time. The function is pure, so that
+// rankings can be reproduced given a fixed clock.
+//
+// The score grows linearly with reactions and comments, is multiplied by the relevant proximity boost and decays
+// exponentially with the artwork's age.
+func rankDiscovery(candidates []DiscoveryCandidate, weights DiscoveryWeights, now time.Time) []ArtworkStreamPreview {
+	var scores = make([]float64, len(candidates))
+	for index, candidate := range candidates {
+		scores[index] = scoreDiscovery(candidate, weights, now)
+	}
+
+	// sort indexes rather than candidates, to keep scores aligned; ties are broken by recency
+	var order = make([]int, len(candidates))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if scores[order[i]] != scores[order[j]] {
+			return scores[order[i]] > scores[order[j]]
+		}
+		return candidates[order[j]].Artwork.Added.Before(candidates[order[i]].Artwork.Added)
+	})
+
+	var ranked = make([]ArtworkStreamPreview, 0, len(candidates))
+	for _, index := range order {
+		ranked = append(ranked, candidates[index].Artwork)
+	}
+	return ranked
+}
+
+// scoreDiscovery computes a single candidate's score; artworks without feedback still get a base score of one.
+func scoreDiscovery(candidate DiscoveryCandidate, weights DiscoveryWeights, now time.Time) float64 {
+	var score = 1 +
+		weights.Reaction*float64(candidate.Artwork.Reactions) +
+		weights.Comment*float64(candidate.Artwork.Comments)
+
+	// followed authors take precedence over the ones merely followed by followed users
+	switch {
+	case candidate.FollowedAuthor:
+		score *= weights.FollowedAuthor
+	case candidate.NetworkAuthor:
+		score *= weights.NetworkAuthor
+	}
+
+	// artworks from the future, due to clock skews, aren't rewarded
+	var age = now.Sub(candidate.Artwork.Added.Time())
+	if age < 0 {
+		age = 0
+	}
+	if weights.HalfLife > 0 {
+		score *= math.Pow(0.5, age.Hours()/weights.HalfLife.Hours())
+	}
+	return score
+}
+
+// paginateDiscovery returns the requested page of ranked artworks, or an empty slice when out of bounds.
+func paginateDiscovery(ranked []ArtworkStreamPreview, page int) []ArtworkStreamPreview {
+	var start = page * discoveryPageSize
+	if start >= len(ranked) {
+		return make([]ArtworkStreamPreview, 0)
+	}
+	var end = start + discoveryPageSize
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+	return ranked[start:end]
+}
@@ -0,0 +1,138 @@
+package artworks
+
+import (
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// discoveryNow is the fixed clock against which candidates' ages are computed.
+var discoveryNow = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+var discoveryWeights = DiscoveryWeights{
+	Reaction:       1,
+	Comment:        3,
+	FollowedAuthor: 2,
+	NetworkAuthor:  1.5,
+	HalfLife:       24 * time.Hour,
+	Window:         30 * 24 * time.Hour,
+}
+
+// candidate builds a discovery candidate added the given time before discoveryNow.
+func candidate(id string, age time.Duration, reactions, comments int) DiscoveryCandidate {
+	return DiscoveryCandidate{Artwork: ArtworkStreamPreview{
+		Id:        id,
+		Reactions: reactions,
+		Comments:  comments,
+		Added:     ntime.New(discoveryNow.Add(-age)),
+	}}
+}
+
+func rankedIds(ranked []ArtworkStreamPreview) []string {
+	var ids = make([]string, len(ranked))
+	for index, artwork := range ranked {
+		ids[index] = artwork.Id
+	}
+	return ids
+}
+
+func TestRankDiscovery(t *testing.T) {
+	var followed = candidate("followed", time.Hour, 2, 0)
+	followed.FollowedAuthor = true
+	var network = candidate("network", time.Hour, 2, 0)
+	network.NetworkAuthor = true
+	var both = candidate("both", time.Hour, 2, 0)
+	both.FollowedAuthor, both.NetworkAuthor = true, true
+	var recent = candidate("recent", 30*time.Minute, 2, 0)
+	recent.FollowedAuthor = true
+
+	var tests = []struct {
+		name       string
+		candidates []DiscoveryCandidate
+		expected   []string
+	}{
+		{
+			name:       "comments weigh more than reactions",
+			candidates: []DiscoveryCandidate{candidate("reactions", time.Hour, 2, 0), candidate("comment", time.Hour, 0, 1)},
+			expected:   []string{"comment", "reactions"},
+		},
+		{
+			name: "scores halve every half-life",
+			candidates: []DiscoveryCandidate{
+				// 5 halved twice scores 1.25, less than 3 halved once
+				candidate("old", 48*time.Hour, 4, 0),
+				candidate("recent", 24*time.Hour, 2, 0),
+			},
+			expected: []string{"recent", "old"},
+		},
+		{
+			name: "feedback outweighs decay",
+			candidates: []DiscoveryCandidate{
+				candidate("fresh", 0, 0, 0),
+				candidate("popular", 24*time.Hour, 0, 3),
+			},
+			expected: []string{"popular", "fresh"},
+		},
+		{
+			name:       "ties are broken by recency",
+			candidates: []DiscoveryCandidate{candidate("older", 2*time.Hour, 0, 0), candidate("newer", time.Hour, 0, 0)},
+			expected:   []string{"newer", "older"},
+		},
+		{
+			name: "followed authors are boosted above network ones, which are boosted above strangers",
+			candidates: []DiscoveryCandidate{
+				candidate("stranger", time.Hour, 2, 0),
+				network,
+				followed,
+			},
+			expected: []string{"followed", "network", "stranger"},
+		},
+		{
+			name:       "the followed boost isn't compounded with the network one",
+			candidates: []DiscoveryCandidate{both, recent},
+			expected:   []string{"recent", "both"},
+		},
+		{
+			name:       "artworks from the future aren't rewarded",
+			candidates: []DiscoveryCandidate{candidate("future", -48*time.Hour, 0, 0), candidate("now", 0, 1, 0)},
+			expected:   []string{"now", "future"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ranked = rankedIds(rankDiscovery(test.candidates, discoveryWeights, discoveryNow))
+			if !reflect.DeepEqual(ranked, test.expected) {
+				t.Errorf("ranked %v, expected %v", ranked, test.expected)
+			}
+		})
+	}
+}
+
+func TestScoreDiscovery(t *testing.T) {
+	var followed = candidate("followed", 24*time.Hour, 1, 1)
+	followed.FollowedAuthor = true
+	var network = candidate("network", 0, 1, 0)
+	network.NetworkAuthor = true
+
+	var tests = []struct {
+		name      string
+		candidate DiscoveryCandidate
+		expected  float64
+	}{
+		{"base score", candidate("plain", 0, 0, 0), 1},
+		{"weighted feedback", candidate("feedback", 0, 2, 1), 6},
+		{"half-life decay", candidate("decayed", 48*time.Hour, 3, 0), 1},
+		{"followed boost and decay", followed, 5},
+		{"network boost", network, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if score := scoreDiscovery(test.candidate, discoveryWeights, discoveryNow); score != test.expected {
+				t.Errorf("scored %v, expected %v", score, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package artworks
+
+import (
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// serveImage serves an artwork image's binary data.
+func serveImage(writer http.ResponseWriter, request *http.Request, ar Storer, image ArtworkImage) {
+	http.ServeFile(writer, request, fmt.Sprintf("%s/%s.%s", ar.GetImagesPath(), image.Id, image.Format))
+}
+
+// getArtworkImage handles the authenticated GET "/artworks/:artworkId/image" route and serves the cover's binary data.
+func getArtworkImage(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch images, err := ar.GetArtworkImages(GetParam(request, "artworkId"), auth.MustGetUser(request).Id); {
+		case err == nil:
+			serveImage(writer, request, ar, images[0])
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Image not found, or forbidden access")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getArtworkImages handles the authenticated GET "/artworks/:artworkId/images" route, listing an artwork's images.
+func getArtworkImages(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch images, err := ar.GetArtworkImages(GetParam(request, "artworkId"), auth.MustGetUser(request).Id); {
+		case err == nil:
+			JSON.Ok(writer, images)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Artwork not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+/*
+getArtworkImageAt handles the authenticated GET "/artworks/:artworkId/images/:image" route and serves binary data.
+Images are addressed either by their zero based index, or by their ID; the two can't be confused, as IDs are hashes.
+*/
+func getArtworkImageAt(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		images, err := ar.GetArtworkImages(GetParam(request, "artworkId"), auth.MustGetUser(request).Id)
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Image not found, or forbidden access")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		var selector = GetParam(request, "image")
+		if index, e := strconv.Atoi(selector); e == nil {
+			if index >= 0 && index < len(images) {
+				serveImage(writer, request, ar, images[index])
+				return
+			}
+		} else {
+			for _, image := range images {
+				if image.Id == selector {
+					serveImage(writer, request, ar, image)
+					return
+				}
+			}
+		}
+		JSON.NotFound(writer, "Image not found, or forbidden access")
+	}
+}
+
+/*
+addArtworkImage handles the authenticated POST "/artworks/:artworkId/images" route, appending an image to an artwork,
+within the uploader's quota. The multipart form's `image` field is streamed, as in artworks' uploads.
+*/
+func addArtworkImage(ar Storer, options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if enough, err := hasFreeSpace(ar, request.ContentLength, options); err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		} else if !enough {
+			JSON.InsufficientStorage(writer, "insufficient-storage", "The server is running out of storage space.")
+			return
+		}
+
+		request.Body = http.MaxBytesReader(writer, request.Body, maxFileUploadSize+maxFormOverhead)
+		reader, err := request.MultipartReader()
+		if err != nil {
+			JSON.BadRequestWithMessage(writer, "Malformed image upload")
+			return
+		}
+
+		var staged *stagedImage
+		defer func() {
+			if staged != nil {
+				_ = os.Remove(staged.path)
+			}
+		}()
+
+		// fields other than the image are ignored
+		for staged == nil {
+			part, e := reader.NextPart()
+			if errors.Is(e, io.EOF) {
+				break
+			} else if e != nil {
+				JSON.BadRequestWithMessage(writer, "Malformed image upload")
+				return
+			}
+
+			if part.FormName() == "image" {
+				var image stagedImage
+				if image, e = stageImage(part, ar.GetImagesPath()); e != nil {
+					_ = part.Close()
+					reportUploadError(writer, e)
+					return
+				}
+				staged = &image
+			}
+			_ = part.Close()
+		}
+		if staged == nil {
+			JSON.BadRequestWithMessage(writer, "Malformed image upload")
+			return
+		}
+
+		var user = auth.MustGetUser(request)
+		image, err := registerArtworkImage(ar, *staged, GetParam(request, "artworkId"), user.Id, options.Quota)
+		if err != nil {
+			reportUploadError(writer, err)
+			return
+		}
+		JSON.Created(writer, image)
+	}
+}
+
+// removeArtworkImage handles the authenticated DELETE "/artworks/:artworkId/images/:image" route, addressing
+// images by ID alone.
+func removeArtworkImage(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var artworkId, imageId = GetParam(request, "artworkId"), GetParam(request, "image")
+		switch err := ar.RemoveArtworkImage(artworkId, auth.MustGetUser(request).Id, imageId); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Image not found")
+		case errors.Is(err, ErrLastImage):
+			JSON.BadRequestWithMessage(writer, "Artworks need at least an image; delete the artwork instead.")
+		case errors.Is(err, ErrFirstImage):
+			JSON.BadRequestWithMessage(writer, "The artwork was uploaded with this image, which identifies it.")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// sortArtworkImages handles the authenticated PUT "/artworks/:artworkId/images" route, rearranging an artwork's
+// images; the first one becomes the artwork's cover.
+func sortArtworkImages(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[SortImagesData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		switch err = ar.SortArtworkImages(GetParam(request, "artworkId"), auth.MustGetUser(request).Id, data.Images); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Artwork not found")
+		case errors.Is(err, ErrImagesOrder):
+			JSON.BadRequestWithMessage(writer, "List each of the artwork's images once, in the desired order.")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
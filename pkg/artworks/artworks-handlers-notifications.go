@@ -0,0 +1,37 @@
+package artworks
+
+import (
+	"errors"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+)
+
+// getNotifications handles the authenticated GET "/users/:alias/notifications" route, listing the user's most recent
+// notifications.
+func getNotifications(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		notifications, err := ar.GetNotifications(auth.MustGetUser(request).Id)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		JSON.Ok(writer, notifications)
+	}
+}
+
+// deleteNotification handles the authenticated DELETE "/users/:alias/notifications/:notificationId" route, which
+// dismisses a notification.
+func deleteNotification(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch err := ar.DeleteNotification(auth.MustGetUser(request).Id, GetParam(request, "notificationId")); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Notification not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package artworks
+
+import (
+	"errors"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"github.com/silktrader/kvasari/pkg/users"
+	"net/http"
+)
+
+// profilePageSize sets the number of artworks, followers and followed users included in profile responses.
+const profilePageSize = 12
+
+// getProfile handles the authenticated GET "/users/:alias/profile" route, composing the user's details along with
+// the first pages of their artworks, followers and followed users.
+// Responses are tagged, so that repeated visits to unchanged profiles are answered with a 304 status.
+func getProfile(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var alias = GetParam(request, "alias")
+		if err := users.ValidateUserAlias(alias); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		// bans are enforced by the details query, which reports banned requesters as not found
+		var requester = auth.MustGetUser(request)
+		details, err := ar.GetUserStore().GetDetails(alias, requester.Id)
+		if errors.Is(err, users.ErrNotFound) {
+			JSON.NotFound(writer, "user not found or unavailable")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		target, err := ar.GetUserStore().GetUserByAlias(alias)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		artworks, err := ar.GetUserArtworks(alias, requester.Id, PageData{pageSize: profilePageSize, firstPage: true})
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		// the details' count includes hidden artworks, which are only disclosed to their author
+		total, err := ar.CountUserArtworks(alias, requester.Id)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		details.ArtworksAdded = total
+
+		followers, followed, err := ar.GetUserStore().GetUserRelations(target.Id)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		JSON.OkWithETag(writer, request, ProfileData{
+			Details:       details,
+			TotalArtworks: total,
+			Artworks:      artworks.Requested,
+			Followers:     firstRelations(followers),
+			FollowedUsers: firstRelations(followed),
+		})
+	}
+}
+
+// firstRelations truncates relations, already sorted by date, to the profile's page size.
+func firstRelations(relations []users.RelationData) []users.RelationData {
+	if len(relations) > profilePageSize {
+		return relations[:profilePageSize]
+	}
+	return relations
+}
@@ -0,0 +1,310 @@
+package artworks
+
+import (
+	"errors"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Resumable uploads let clients send images in chunks, which are short enough to fit the server's read timeout, and
+resume them after failures, rather than starting anew:
+
+ 1. POST "/uploads" announces the image's size and opens a session
+ 2. PATCH "/uploads/:uploadId" appends a chunk at the `Upload-Offset` header's position, which must match the session's
+    offset; clients query the offset with GET "/uploads/:uploadId" after failures
+ 3. POST "/uploads/:uploadId/artwork" validates the complete image and adds its artwork, as single pass uploads do
+
+Chunks are appended to a staging file, whose progress is tracked in the database. Idle sessions expire.
+*/
+
+// uploadChunkType is the content type of resumable uploads' chunks, borrowed from the tus protocol.
+const uploadChunkType = "application/offset+octet-stream"
+
+// uploadLocks prevents concurrent requests from writing to, or finalising, the same upload.
+type uploadLocks struct {
+	sync.Map
+}
+
+// acquire locks an upload, reporting false when it's locked already.
+func (locks *uploadLocks) acquire(uploadId string) bool {
+	_, locked := locks.LoadOrStore(uploadId, struct{}{})
+	return !locked
+}
+
+func (locks *uploadLocks) release(uploadId string) {
+	locks.Delete(uploadId)
+}
+
+// uploadExpiry returns the expiry of upload sessions updated now.
+func uploadExpiry(options Options) ntime.NTime {
+	return ntime.New(time.Now().Add(options.UploadExpiry))
+}
+
+// reportUpload encodes an upload session, along with its offset's header.
+func reportUpload(writer http.ResponseWriter, status int, session UploadSession) {
+	writer.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	if status == http.StatusCreated {
+		JSON.Created(writer, session)
+	} else {
+		JSON.Ok(writer, session)
+	}
+}
+
+// createUpload handles the authenticated POST "/uploads" route, opening resumable upload sessions.
+func createUpload(ar Storer, options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[CreateUploadData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		// refuse uploads which can't be completed, before any chunk is sent
+		if enough, e := hasFreeSpace(ar, data.Size, options); e != nil {
+			JSON.InternalServerError(writer, e)
+			return
+		} else if !enough {
+			JSON.InsufficientStorage(writer, "insufficient-storage", "The server is running out of storage space.")
+			return
+		}
+
+		var user = auth.MustGetUser(request)
+		usage, err := ar.GetStorageUsage(user.Id, options.Quota)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		if usage.MaxArtworks > 0 && usage.Artworks >= usage.MaxArtworks {
+			reportUploadError(writer, ErrArtworksQuota)
+			return
+		}
+		if usage.MaxBytes > 0 && usage.Bytes+data.Size > usage.MaxBytes {
+			reportUploadError(writer, ErrBytesQuota)
+			return
+		}
+
+		session, err := ar.CreateUpload(user.Id, data, uploadExpiry(options))
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		reportUpload(writer, http.StatusCreated, session)
+	}
+}
+
+// getUpload handles the authenticated GET "/uploads/:uploadId" route, reporting an upload's offset.
+func getUpload(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch session, err := ar.GetUpload(GetParam(request, "uploadId"), auth.MustGetUser(request).Id); {
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Upload not found")
+		case err == nil:
+			reportUpload(writer, http.StatusOK, session)
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+/*
+appendChunk handles the authenticated PATCH "/uploads/:uploadId" route, appending a chunk to an upload.
+
+Chunks interrupted by network failures or timeouts are kept as far as they were received, so that clients can resume
+from the returned offset. Bytes written beyond the recorded offset, for instance by crashes, are discarded.
+*/
+func appendChunk(ar Storer, locks *uploadLocks, options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Content-Type") != uploadChunkType {
+			JSON.BadRequestWithMessage(writer, "Chunks must be sent as "+uploadChunkType)
+			return
+		}
+		offset, err := strconv.ParseInt(request.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			JSON.BadRequestWithMessage(writer, "Invalid or missing Upload-Offset header")
+			return
+		}
+
+		var uploadId = GetParam(request, "uploadId")
+		if !locks.acquire(uploadId) {
+			JSON.Conflict(writer, "The upload is being modified by another request.")
+			return
+		}
+		defer locks.release(uploadId)
+
+		var user = auth.MustGetUser(request)
+		session, err := ar.GetUpload(uploadId, user.Id)
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Upload not found")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		if offset != session.Offset {
+			writer.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+			JSON.Conflict(writer, "The chunk's offset doesn't match the upload's one.")
+			return
+		}
+
+		if enough, e := hasFreeSpace(ar, request.ContentLength, options); e != nil {
+			JSON.InternalServerError(writer, e)
+			return
+		} else if !enough {
+			JSON.InsufficientStorage(writer, "insufficient-storage", "The server is running out of storage space.")
+			return
+		}
+
+		written, err := writeChunk(ar.GetUploadPath(uploadId), session, http.MaxBytesReader(writer, request.Body,
+			session.Size-session.Offset))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			JSON.BadRequestWithMessage(writer, "The chunk exceeds the upload's announced size.")
+			return
+		}
+
+		// partial chunks are recorded all the same, regardless of read errors
+		if written > 0 {
+			var expires = uploadExpiry(options)
+			if e := ar.AdvanceUpload(uploadId, user.Id, session.Offset, session.Offset+written, expires); e != nil {
+				JSON.InternalServerError(writer, e)
+				return
+			}
+			session.Offset += written
+			session.Updated = ntime.Now()
+			session.Expires = expires
+		}
+
+		if err != nil {
+			JSON.BadRequestWithMessage(writer, "The chunk was interrupted; resume the upload from its offset.")
+			return
+		}
+		reportUpload(writer, http.StatusOK, session)
+	}
+}
+
+// writeChunk appends a chunk to a staging file, at the session's offset, returning the bytes written. Oversized chunks
+// aren't written at all.
+func writeChunk(path string, session UploadSession, chunk io.Reader) (written int64, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0640)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	if err = file.Truncate(session.Offset); err != nil {
+		return 0, err
+	}
+	if _, err = file.Seek(session.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, err = io.Copy(file, chunk)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		_ = file.Truncate(session.Offset)
+		return 0, err
+	}
+	return written, err
+}
+
+/*
+finaliseUpload handles the authenticated POST "/uploads/:uploadId/artwork" route, adding the artwork of a complete
+upload. The image is hashed and validated as single pass uploads are, then its session is removed.
+
+Sessions are kept when artworks can't be added for lack of quota, or server errors, so that clients can retry.
+*/
+func finaliseUpload(ar Storer, locks *uploadLocks, options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var uploadId = GetParam(request, "uploadId")
+		if !locks.acquire(uploadId) {
+			JSON.Conflict(writer, "The upload is being modified by another request.")
+			return
+		}
+		defer locks.release(uploadId)
+
+		var user = auth.MustGetUser(request)
+		session, err := ar.GetUpload(uploadId, user.Id)
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Upload not found")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		if session.Offset < session.Size {
+			writer.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+			JSON.Conflict(writer, "The upload is incomplete.")
+			return
+		}
+
+		staged, err := stageUpload(ar.GetUploadPath(uploadId), ar.GetImagesPath())
+		if err == nil {
+			var date ntime.NTime
+			if date, err = registerImage(ar, staged, user.Id, session.Visibility, options.Quota); err == nil {
+				_ = ar.DeleteUpload(uploadId, user.Id)
+				JSON.Created(writer, struct {
+					Id      string
+					Updated ntime.NTime
+					Format  string
+				}{
+					Id:      staged.checksum,
+					Updated: date,
+					Format:  string(staged.format),
+				})
+				return
+			}
+		}
+
+		// invalid images and duplicates can't be amended, unlike quota issues
+		if errors.Is(err, errImageType) || errors.Is(err, errImageTooLarge) || errors.Is(err, ErrDupArtwork) {
+			_ = ar.DeleteUpload(uploadId, user.Id)
+		}
+		reportUploadError(writer, err)
+	}
+}
+
+// stageUpload stages an upload's complete image, as single pass uploads are, leaving the upload's file untouched.
+func stageUpload(path string, imagesPath string) (stagedImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return stagedImage{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return stageImage(file, imagesPath)
+}
+
+// deleteUpload handles the authenticated DELETE "/uploads/:uploadId" route, discarding an upload and its chunks.
+func deleteUpload(ar Storer, locks *uploadLocks) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var uploadId = GetParam(request, "uploadId")
+		if !locks.acquire(uploadId) {
+			JSON.Conflict(writer, "The upload is being modified by another request.")
+			return
+		}
+		defer locks.release(uploadId)
+
+		switch err := ar.DeleteUpload(uploadId, auth.MustGetUser(request).Id); {
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Upload not found")
+		case err == nil:
+			JSON.NoContent(writer)
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package artworks
+
+import (
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	. "github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+	"time"
+)
+
+// setTags handles the authenticated PUT "/artworks/:artworkId/tags" route, replacing the tags set by the artwork's
+// author; tags written as hashtags in the artwork's description are kept.
+func setTags(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[UpdateTagsData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		// validation ensures tags can be normalised
+		tags, err := normaliseTags(data.Tags)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		switch err = ar.SetArtworkTags(GetParam(request, "artworkId"), auth.MustGetUser(request).Id, tags); {
+		case err == nil:
+			JSON.Ok(writer, struct{ Tags []string }{tags})
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Artwork not found")
+		case errors.Is(err, ErrTagsLimit):
+			JSON.BadRequestWithMessage(writer, fmt.Sprintf("Artworks can have up to %d tags, hashtags included.",
+				maxArtworkTags))
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// setDescription handles the authenticated PUT "/artworks/:artworkId/description" route, whose hashtags replace the
+// tags previously sourced from the description, and whose mentions replace the previous ones, notifying new users.
+func setDescription(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[UpdateDescriptionData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var hashtags = make([]string, 0)
+		var mentions = make([]Mention, 0)
+		if data.Description != nil {
+			hashtags = extractHashtags(*data.Description)
+			mentions = parseMentions(*data.Description)
+		}
+
+		var artworkId, userId = GetParam(request, "artworkId"), auth.MustGetUser(request).Id
+		switch err = ar.SetArtworkDescription(artworkId, userId, data.Description, hashtags, mentions); {
+		case err == nil:
+			JSON.NoContent(writer)
+		case errors.Is(err, ErrNotFound):
+			JSON.NotFound(writer, "Artwork not found")
+		default:
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getTaggedArtworks handles the authenticated GET "/tags/:tag/artworks?page=number" route, listing the artworks
+// sharing a tag, most recent first. Tags are normalised, so that "#Sketch" and "sketch" match alike.
+func getTaggedArtworks(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		tag, err := NormaliseTag(GetParam(request, "tag"))
+		if err != nil {
+			JSON.BadRequestWithMessage(writer, ErrInvalidTag.Error())
+			return
+		}
+		page, err := getPage(request.URL.Query(), maxTagPage)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		artworks, err := ar.GetTaggedArtworks(tag, auth.MustGetUser(request).Id, page)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		JSON.Ok(writer, TaggedArtworks{Tag: tag, Page: page, Artworks: artworks})
+	}
+}
+
+// getTrendingTags handles the authenticated GET "/trending/tags" route, ranking the tags most attached to artworks
+// during the configured window.
+func getTrendingTags(ar Storer, options TrendingOptions) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var since = ntime.New(time.Now().Add(-options.Window))
+		tags, err := ar.GetTrendingTags(auth.MustGetUser(request).Id, since, options.Limit)
+		if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+		JSON.Ok(writer, tags)
+	}
+}
@@ -0,0 +1,328 @@
+package artworks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxFormFieldSize limits the upload form's text fields, which are read in memory
+const maxFormFieldSize = 1024
+
+// maxFormOverhead accounts for the upload form's text fields and multipart boundaries, besides the image
+const maxFormOverhead = 1 << 20
+
+// stagedImagePattern names the temporary files of staged images, in the images directory
+const stagedImagePattern = ".upload-*"
+
+var (
+	errImageTooLarge = errors.New("image is too large; limit file sizes to 40MiB")
+	errImageType     = fmt.Errorf("invalid image type; choose among: %s",
+		strings.Trim(fmt.Sprintf("%v", acceptableFileTypes), "[]"))
+)
+
+// stagedImage is an image written to a temporary file in the images directory, pending its registration.
+type stagedImage struct {
+	path     string
+	checksum string
+	format   ImageFormat
+	size     int64
+}
+
+/*
+stageImage streams an image to a temporary file in the images directory, reading it only once.
+
+The stream is teed to both the file and a hash, while its first 512 bytes are sniffed to detect the image type, which
+avoids extension renaming issues. Temporary files are removed on errors, including invalid types and oversized images.
+*/
+func stageImage(source io.Reader, imagesPath string) (staged stagedImage, err error) {
+	file, err := os.CreateTemp(imagesPath, stagedImagePattern)
+	if err != nil {
+		return staged, err
+	}
+	staged.path = file.Name()
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+		if err != nil {
+			_ = os.Remove(staged.path)
+		}
+	}()
+
+	// an extra byte is read to detect oversized images
+	var hash = sha256.New()
+	var tee = io.TeeReader(io.LimitReader(source, maxFileUploadSize+1), io.MultiWriter(hash, file))
+
+	var header = make([]byte, 512)
+	read, err := io.ReadFull(tee, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return staged, errImageType
+		}
+		return staged, err
+	}
+
+	var imageType = http.DetectContentType(header[:read])
+	var validType = false
+	for _, acceptableType := range acceptableFileTypes {
+		if imageType == acceptableType {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		return staged, errImageType
+	}
+
+	rest, err := io.Copy(io.Discard, tee)
+	if err != nil {
+		return staged, err
+	}
+	if staged.size = int64(read) + rest; staged.size > maxFileUploadSize {
+		return staged, errImageTooLarge
+	}
+
+	staged.checksum = hex.EncodeToString(hash.Sum(nil))
+	staged.format = getFormat(imageType)
+	return staged, nil
+}
+
+// removeStagedImages removes the staged images left behind by crashes or kills, which interrupted their uploads.
+// Images are staged while serving requests, so none should be removed while the server is accepting them.
+func removeStagedImages(imagesPath string) error {
+	files, err := filepath.Glob(filepath.Join(imagesPath, stagedImagePattern))
+	if err != nil {
+		return err
+	}
+	return removeFiles(files)
+}
+
+/*
+registerImage adds a staged image's artwork and moves the image to its final location, named after its hash and
+format. The rename is atomic, so that partially written images are never served.
+
+Soft-deleted artworks sharing the image are cleaned up beforehand, along with their images, comments and reactions.
+The staged image is removed whenever the registration fails.
+*/
+func registerImage(
+	ar Storer, staged stagedImage, userId string, visibility Visibility, quota Quota,
+) (ntime.NTime, error) {
+	if err := ar.CleanDeletedArtwork(staged.checksum, userId); err != nil {
+		_ = os.Remove(staged.path)
+		return ntime.NTime{}, err
+	}
+
+	date, err := ar.AddArtwork(AddArtworkData{
+		Id:         staged.checksum,
+		AuthorId:   userId,
+		Format:     staged.format,
+		Type:       Painting, // default for the moment
+		Visibility: visibility,
+		Size:       staged.size,
+	}, quota)
+	if err != nil {
+		_ = os.Remove(staged.path)
+		return date, err
+	}
+
+	if err = os.Rename(staged.path, stagedImagePath(ar, staged)); err != nil {
+		_ = os.Remove(staged.path)
+		// in the unlikely case an error occurs while writing to disk, attempt to clean the related DB entry
+		_ = ar.CleanArtwork(staged.checksum, userId)
+		return date, err
+	}
+	return date, nil
+}
+
+// registerArtworkImage appends a staged image to an artwork's images, as registerImage adds artworks.
+func registerArtworkImage(
+	ar Storer, staged stagedImage, artworkId, userId string, quota Quota,
+) (ArtworkImage, error) {
+	if err := ar.CleanDeletedArtwork(staged.checksum, userId); err != nil {
+		_ = os.Remove(staged.path)
+		return ArtworkImage{}, err
+	}
+
+	image, err := ar.AddArtworkImage(artworkId, userId, AddArtworkImageData{
+		Id:     staged.checksum,
+		Format: staged.format,
+		Size:   staged.size,
+	}, quota)
+	if err != nil {
+		_ = os.Remove(staged.path)
+		return image, err
+	}
+
+	if err = os.Rename(staged.path, stagedImagePath(ar, staged)); err != nil {
+		_ = os.Remove(staged.path)
+		_ = ar.RemoveArtworkImage(artworkId, userId, staged.checksum)
+		return image, err
+	}
+	return image, nil
+}
+
+// stagedImagePath returns the final location of a staged image.
+func stagedImagePath(ar Storer, staged stagedImage) string {
+	return fmt.Sprintf("%s/%s.%s", ar.GetImagesPath(), staged.checksum, staged.format)
+}
+
+// reportUploadError maps the errors of staged images and their registrations to responses.
+func reportUploadError(writer http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errImageTooLarge), errors.As(err, &maxBytesErr):
+		JSON.BadRequestWithMessage(writer, errImageTooLarge.Error())
+	case errors.Is(err, errImageType):
+		JSON.BadRequestWithMessage(writer, errImageType.Error())
+	case errors.Is(err, ErrDupArtwork):
+		JSON.BadRequestWithMessage(writer, "The artwork image is already present.")
+	case errors.Is(err, ErrBytesQuota):
+		JSON.InsufficientStorage(writer, "quota-bytes-exceeded", "The image exceeds your storage quota.")
+	case errors.Is(err, ErrArtworksQuota):
+		JSON.InsufficientStorage(writer, "quota-artworks-exceeded", "You reached the maximum number of artworks.")
+	case errors.Is(err, ErrImagesLimit):
+		JSON.BadRequestWithMessage(writer, fmt.Sprintf("Artworks can have up to %d images.", maxArtworkImages))
+	case errors.Is(err, ErrNotFound):
+		JSON.NotFound(writer, "Artwork not found")
+	default:
+		JSON.InternalServerError(writer, err)
+	}
+}
+
+// hasFreeSpace reports whether the images' volume can take the incoming bytes, while leaving the required free space.
+func hasFreeSpace(ar Storer, incoming int64, options Options) (bool, error) {
+	if incoming < 0 {
+		incoming = 0
+	}
+	free, err := ar.GetFreeBytes()
+	return free >= options.MinFreeBytes+uint64(incoming), err
+}
+
+/*
+addArtwork handles the authenticated POST "/artworks" route, storing images within the uploader's quota.
+
+The multipart form is streamed, rather than parsed in memory or cached on disk, and the image is read only once.
+The `alias` and `visibility` fields can be sent in any order, although the ones preceding the image spare its upload
+when invalid. Uploads carry no description, whose hashtags and mentions are only parsed by setDescription.
+*/
+func addArtwork(ar Storer, options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+
+		// refuse uploads which would leave the images' volume short of space, before any is used
+		if enough, err := hasFreeSpace(ar, request.ContentLength, options); err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		} else if !enough {
+			JSON.InsufficientStorage(writer, "insufficient-storage", "The server is running out of storage space.")
+			return
+		}
+
+		request.Body = http.MaxBytesReader(writer, request.Body, maxFileUploadSize+maxFormOverhead)
+		reader, err := request.MultipartReader()
+		if err != nil {
+			JSON.BadRequestWithMessage(writer, "Malformed image upload")
+			return
+		}
+
+		var alias string
+		var visibility = Public // artworks are public unless otherwise specified
+		var staged *stagedImage
+
+		// staged images which weren't registered are removed; registered ones were renamed already
+		defer func() {
+			if staged != nil {
+				_ = os.Remove(staged.path)
+			}
+		}()
+
+		for {
+			part, e := reader.NextPart()
+			if errors.Is(e, io.EOF) {
+				break
+			} else if e != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(e, &maxBytesErr) {
+					reportUploadError(writer, e)
+				} else {
+					JSON.BadRequestWithMessage(writer, "Malformed image upload")
+				}
+				return
+			}
+
+			switch part.FormName() {
+			case "alias":
+				// ensure that the uploader alias matches the authenticated user's one
+				if alias, e = readFormField(part); e == nil && alias != user.Alias {
+					JSON.Forbidden(writer)
+					return
+				}
+			case "visibility":
+				var value string
+				if value, e = readFormField(part); e == nil && value != "" {
+					visibility = Visibility(value)
+					if e = ValidateVisibility(visibility); e != nil {
+						JSON.ValidationError(writer, e)
+						return
+					}
+				}
+			case "image":
+				if staged != nil {
+					JSON.BadRequestWithMessage(writer, "Upload a single image at a time")
+					return
+				}
+				var image stagedImage
+				if image, e = stageImage(part, ar.GetImagesPath()); e == nil {
+					staged = &image
+				}
+			}
+			_ = part.Close()
+
+			if e != nil {
+				reportUploadError(writer, e)
+				return
+			}
+		}
+
+		if alias != user.Alias {
+			JSON.Forbidden(writer)
+			return
+		}
+		if staged == nil {
+			JSON.BadRequestWithMessage(writer, "Malformed image upload")
+			return
+		}
+
+		date, err := registerImage(ar, *staged, user.Id, visibility, options.Quota)
+		if err != nil {
+			reportUploadError(writer, err)
+			return
+		}
+
+		JSON.Created(writer, struct {
+			Id      string
+			Updated ntime.NTime
+			Format  string
+		}{
+			Id:      staged.checksum,
+			Updated: date,
+			Format:  string(staged.format),
+		})
+	}
+}
+
+// readFormField reads a multipart form's text field, up to maxFormFieldSize bytes.
+func readFormField(part io.Reader) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize))
+	return string(value), err
+}
@@ -0,0 +1,32 @@
+package artworks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveStagedImages(t *testing.T) {
+	var directory = t.TempDir()
+	for _, name := range []string{".upload-1", ".upload-2", "checksum.png", "upload-3"} {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte("image"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removeStagedImages(directory); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names = make([]string, len(entries))
+	for index, entry := range entries {
+		names[index] = entry.Name()
+	}
+	if kept := strings.Join(names, ","); kept != "checksum.png,upload-3" {
+		t.Errorf("kept %s, rather than only the registered images", kept)
+	}
+}
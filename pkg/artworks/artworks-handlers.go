@@ -1,21 +1,17 @@
 package artworks
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
-	"fmt"
 	"github.com/silktrader/kvasari/pkg/auth"
 	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
 	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/ratelimit"
 	. "github.com/silktrader/kvasari/pkg/rest"
 	"github.com/silktrader/kvasari/pkg/users"
-	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
-	"strings"
+	"time"
 )
 
 // maxFileUploadSize determines the maximum incoming file size; set to ~40MiB
@@ -24,29 +20,98 @@ const maxFileUploadSize = 41943040
 // acceptableFileTypes describes which file types can be uploaded by users
 var acceptableFileTypes = [...]string{"image/jpeg", "image/png", "image/webp"}
 
-func RegisterHandlers(engine Engine, ar Storer, aur auth.IRepository) {
+// Options carries the artworks' handlers settings, sourced from the web API configuration.
+type Options struct {
+	Discovery DiscoveryWeights
+
+	// Quota limits each user's artworks, while uploads are refused once the images' volume free space falls below
+	// MinFreeBytes
+	Quota        Quota
+	MinFreeBytes uint64
+
+	// UploadExpiry is the time after which idle resumable uploads are discarded
+	UploadExpiry time.Duration
+
+	// Trending determines how many tags are ranked as trending, and over which recent period
+	Trending TrendingOptions
+
+	// Limiter enforces the uploads' and comments' budgets
+	Limiter  *ratelimit.Limiter
+	Uploads  ratelimit.Budget
+	Comments ratelimit.Budget
+}
+
+func RegisterHandlers(engine Engine, ar Storer, aur auth.IRepository, options Options) {
 	var authenticated = auth.Auth(aur)
+	var self = auth.RequireSelf("alias")
+	var owner = auth.RequireArtworkOwner(ar, "artworkId")
+	var uploads = options.Limiter.Limit("uploads", options.Uploads)
+	var comments = options.Limiter.Limit("comments", options.Comments)
+	var canonical = users.CanonicalAlias(ar.GetUserStore())
+	var locks = &uploadLocks{}
 
 	// artworks management
-	engine.Post("/artworks", addArtwork(ar), authenticated)
-	engine.Delete("/artworks/:artworkId", deleteArtwork(ar), authenticated)
+	engine.Post("/artworks", addArtwork(ar, options), authenticated, uploads)
+	engine.Delete("/artworks/:artworkId", deleteArtwork(ar), authenticated, owner)
 	engine.Get("/artworks/:artworkId/data", getArtworkData(ar), authenticated)
 	engine.Get("/artworks/:artworkId/image", getArtworkImage(ar), authenticated)
 	engine.Get("/artworks", getArtworks(ar), authenticated)
-	engine.Put("/artworks/:artworkId/title", setTitle(ar), authenticated)
+	engine.Put("/artworks/:artworkId/title", setTitle(ar), authenticated, owner)
+	engine.Put("/artworks/:artworkId/visibility", setVisibility(ar), authenticated, owner)
+	engine.Put("/artworks/:artworkId/description", setDescription(ar), authenticated, owner)
+
+	// tags, either set by authors or written as hashtags in descriptions
+	engine.Put("/artworks/:artworkId/tags", setTags(ar), authenticated, owner)
+	engine.Get("/tags/:tag/artworks", getTaggedArtworks(ar), authenticated)
+	engine.Get("/trending/tags", getTrendingTags(ar, options.Trending), authenticated)
+
+	// artwork images, whose first one is the cover
+	engine.Get("/artworks/:artworkId/images", getArtworkImages(ar), authenticated)
+	engine.Get("/artworks/:artworkId/images/:image", getArtworkImageAt(ar), authenticated)
+	engine.Post("/artworks/:artworkId/images", addArtworkImage(ar, options), authenticated, owner, uploads)
+	engine.Put("/artworks/:artworkId/images", sortArtworkImages(ar), authenticated, owner)
+	engine.Delete("/artworks/:artworkId/images/:image", removeArtworkImage(ar), authenticated, owner)
+
+	// resumable uploads; only their creation counts against the uploads' budget, as chunks are numerous
+	engine.Post("/uploads", createUpload(ar, options), authenticated, uploads)
+	engine.Get("/uploads/:uploadId", getUpload(ar), authenticated)
+	engine.Patch("/uploads/:uploadId", appendChunk(ar, locks, options), authenticated)
+	engine.Post("/uploads/:uploadId/artwork", finaliseUpload(ar, locks, options), authenticated)
+	engine.Delete("/uploads/:uploadId", deleteUpload(ar, locks), authenticated)
 
 	// comments
-	engine.Post("/artworks/:artworkId/comments", addComment(ar), authenticated)
+	engine.Post("/artworks/:artworkId/comments", addComment(ar), authenticated, comments)
+	engine.Put("/artworks/:artworkId/comments/:commentId", editComment(ar), authenticated)
 	engine.Delete("/artworks/:artworkId/comments/:commentId", deleteComment(ar), authenticated)
 	engine.Get("/artworks/:artworkId/comments", getArtworkComments(ar), authenticated)
 
 	// reactions
-	engine.Put("/artworks/:artworkId/reactions/:alias", setReaction(ar), authenticated)
-	engine.Delete("/artworks/:artworkId/reactions/:alias", removeReaction(ar), authenticated)
+	engine.Put("/artworks/:artworkId/reactions/:alias", setReaction(ar), authenticated, self)
+	engine.Delete("/artworks/:artworkId/reactions/:alias", removeReaction(ar), authenticated, self)
 	engine.Get("/artworks/:artworkId/reactions", getArtworkReactions(ar), authenticated)
+	engine.Get("/reactions", getReactionKinds(ar), authenticated)
+	engine.Get("/users/:alias/reactions", getUserReactions(ar), authenticated, canonical)
+
+	// collections
+	engine.Get("/users/:alias/collections", getCollections(ar), authenticated, canonical)
+	engine.Post("/users/:alias/collections", createCollection(ar), authenticated, self)
+	engine.Get("/users/:alias/collections/:collectionId", getCollection(ar), authenticated, canonical)
+	engine.Put("/users/:alias/collections/:collectionId", updateCollection(ar), authenticated, self)
+	engine.Delete("/users/:alias/collections/:collectionId", deleteCollection(ar), authenticated, self)
+	engine.Put("/users/:alias/collections/:collectionId/artworks", sortCollection(ar), authenticated, self)
+	engine.Put("/users/:alias/collections/:collectionId/artworks/:artworkId", addCollectionEntry(ar), authenticated, self)
+	engine.Delete("/users/:alias/collections/:collectionId/artworks/:artworkId", removeCollectionEntry(ar),
+		authenticated, self)
+
+	// notifications, such as mentions in comments
+	engine.Get("/users/:alias/notifications", getNotifications(ar), authenticated, self)
+	engine.Delete("/users/:alias/notifications/:notificationId", deleteNotification(ar), authenticated, self)
 
 	// user specific aggregates
-	engine.Get("/users/:alias/stream", getStream(ar), authenticated)
+	engine.Get("/users/:alias/stream", getStream(ar), authenticated, self)
+	engine.Get("/users/:alias/discover", getDiscovery(ar, options.Discovery, time.Now), authenticated, self)
+	engine.Get("/users/:alias/profile", getProfile(ar), authenticated, canonical)
+	engine.Get("/users/:alias/storage", getStorageUsage(ar, options.Quota), authenticated, self)
 }
 
 func closeFile(file multipart.File) {
@@ -64,144 +129,6 @@ func getFormat(imageType string) ImageFormat {
 	}
 }
 
-func addArtwork(ar Storer) http.HandlerFunc {
-	return func(writer http.ResponseWriter, request *http.Request) {
-		// ensure that the uploader alias matches the authenticated user's one
-		var user = auth.MustGetUser(request)
-		if user.Alias != request.FormValue("alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
-		// ParseMultipartForm's argument refers to a memory limit, additional bytes will be cached on disk
-		if err := request.ParseMultipartForm(maxFileUploadSize); err != nil {
-			JSON.InternalServerError(writer, err)
-			return
-		}
-
-		uploadedFile, header, err := request.FormFile("image")
-		if err != nil {
-			JSON.BadRequestWithMessage(writer, "Malformed image upload")
-			return
-		}
-
-		defer closeFile(uploadedFile)
-
-		// ensure files are sized appropriately
-		if header.Size > maxFileUploadSize {
-			JSON.BadRequestWithMessage(writer, fmt.Sprintf("%s is too large; limit file sizes to 40MiB", header.Filename))
-			return
-		}
-
-		var buffer = make([]byte, 512)
-		_, err = uploadedFile.Read(buffer)
-		if err != nil {
-			JSON.InternalServerError(writer, err)
-			return
-		}
-
-		// format detection by reading a file's header avoid extension renaming issues
-		filetype := http.DetectContentType(buffer)
-		var invalidFileType = true
-		for _, acceptableType := range acceptableFileTypes {
-			if filetype == acceptableType {
-				invalidFileType = false
-				break
-			}
-		}
-		if invalidFileType {
-			JSON.BadRequestWithMessage(writer, fmt.Sprintf("%s isn't a valid file type; choose among: %v",
-				header.Filename,
-				strings.Trim(fmt.Sprintf("%v", acceptableFileTypes), "[]")))
-			return
-		}
-
-		// end filetype detection, seek to start to avoid parsing issues
-		if _, err = uploadedFile.Seek(0, io.SeekStart); err != nil {
-			JSON.InternalServerError(writer, err)
-			return
-		}
-
-		// hash the image, to identify it and ensure it's not a duplicate
-		hash := sha256.New()
-		if _, err = io.Copy(hash, uploadedFile); err != nil {
-			JSON.InternalServerError(writer, err)
-			return
-		}
-		var checksum = hex.EncodeToString(hash.Sum(nil))
-
-		// detect file extension or format
-		var fileFormat = getFormat(filetype)
-
-		// guard against duplicate uploads, before an existing file is truncated
-		// attempt to clean a previously soft-deleted artwork and related comments, reactions
-		existsImage, err := ar.CleanDeletedArtwork(checksum, user.Id)
-		if err != nil {
-			JSON.InternalServerError(writer, err)
-			return
-		}
-
-		// attempt to update the database
-		date, err := ar.AddArtwork(AddArtworkData{
-			Id:       checksum,
-			AuthorId: user.Id,
-			Format:   fileFormat,
-			Type:     Painting, // default for the moment
-		})
-
-		if err != nil {
-			if errors.Is(err, ErrDupArtwork) {
-				JSON.BadRequestWithMessage(writer, "The artwork image is already present.")
-				return
-			}
-			JSON.InternalServerError(writer, err)
-			return
-		}
-
-		// write image file named after its hash and detected file extension
-		// there's no need to perform the operation if the file hasn't been deleted yet
-		if !existsImage {
-			if err = writeImage(uploadedFile, checksum, string(fileFormat), ar.GetImagesPath()); err != nil {
-				JSON.InternalServerError(writer, err)
-				// in the unlikely case an error occurs while writing to disk, attempt to clean the related DB entry
-				_ = ar.CleanArtwork(checksum, user.Id)
-				return
-			}
-		}
-
-		JSON.Created(writer, struct {
-			Id      string
-			Updated ntime.NTime
-			Format  string
-		}{
-			Id:      checksum,
-			Updated: date,
-			Format:  string(fileFormat),
-		})
-	}
-}
-
-// writeImage creates (or truncates) an image file named after its hash and header-detected file extension.
-func writeImage(file multipart.File, checksum, format, path string) error {
-	storedFile, err := os.Create(fmt.Sprintf("%s/%s.%s", path, checksum, format))
-	if err != nil {
-		return err
-	}
-	defer closeFile(storedFile)
-
-	// seek to file start, to offset previous checksum calculations or header reads
-	if _, err = file.Seek(0, io.SeekStart); err != nil {
-		return err
-	}
-
-	if written, e := io.Copy(storedFile, file); e != nil {
-		return e
-	} else if written == 0 {
-		return errors.New("no bytes written")
-	}
-	return nil
-}
-
 // deleteArtwork handles the authenticated DELETE "/artworks/:artworkId" route
 func deleteArtwork(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -228,21 +155,6 @@ func getArtworkData(ar Storer) http.HandlerFunc {
 	}
 }
 
-// getArtworkImage handles the authenticated GET "/artworks/:artworkId/image" route and serves binary data.
-func getArtworkImage(ar Storer) http.HandlerFunc {
-	return func(writer http.ResponseWriter, request *http.Request) {
-		var artworkId = GetParam(request, "artworkId")
-		switch metadata, err := ar.GetImageMetadata(GetParam(request, "artworkId"), auth.MustGetUser(request).Id); {
-		case err == nil:
-			http.ServeFile(writer, request, fmt.Sprintf("%s/%s.%s", ar.GetImagesPath(), artworkId, metadata.Format))
-		case errors.Is(err, ErrNotFound):
-			JSON.NotFound(writer, "Image not found, or forbidden access")
-		default:
-			JSON.InternalServerError(writer, err)
-		}
-	}
-}
-
 // getArtworks handles the authenticated GET "/artworks" route, with parameters: "alias", "since" and "latest"
 func getArtworks(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -250,7 +162,7 @@ func getArtworks(ar Storer) http.HandlerFunc {
 		author, since, latest, err := getValidateArtworkParameters(request.URL.Query())
 		if err != nil {
 			JSON.ValidationError(writer, err)
-		} else if artworks, e := ar.GetUserArtworks(author, auth.MustGetUser(request).Id, PageData{12, since, latest}); e != nil {
+		} else if artworks, e := ar.GetUserArtworks(author, auth.MustGetUser(request).Id, PageData{pageSize: 12, since: since, latest: latest}); e != nil {
 			JSON.InternalServerError(writer, e)
 		} else {
 			JSON.Ok(writer, artworks)
@@ -276,13 +188,7 @@ func getValidateArtworkParameters(params url.Values) (alias, since, latest strin
 // setReaction handles the authenticated PUT "/artworks/:artworkId/reactions/:alias" route
 func setReaction(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// the path user must match the authorised one
 		var user = auth.MustGetUser(request)
-		if user.Alias != GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// validate
 		data, err := JSON.DecodeValidate[AddReactionRequest](request)
 		if err != nil {
@@ -300,6 +206,8 @@ func setReaction(ar Storer) http.HandlerFunc {
 			}{"changed", date})
 		} else if errors.Is(err, ErrNotModified) {
 			JSON.Ok(writer, struct{ Status string }{"unchanged"})
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Artwork not found")
 		} else {
 			JSON.InternalServerError(writer, err)
 		}
@@ -310,13 +218,7 @@ func setReaction(ar Storer) http.HandlerFunc {
 func removeReaction(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 
-		// the alias must match the authorised one
 		var user = auth.MustGetUser(request)
-		if user.Alias != GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		if err := ar.RemoveReaction(user.Id, GetParam(request, "artworkId")); err == nil {
 			JSON.NoContent(writer)
 		} else if errors.Is(err, ErrNotFound) {
@@ -327,6 +229,35 @@ func removeReaction(ar Storer) http.HandlerFunc {
 	}
 }
 
+// getReactionKinds handles the authenticated GET "/reactions" route, listing the available reaction types
+func getReactionKinds(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if kinds, err := ar.GetReactionKinds(); err == nil {
+			JSON.Ok(writer, kinds)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getUserReactions handles the authenticated GET "/users/:alias/reactions" route, listing the artworks the user
+// reacted to
+func getUserReactions(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var alias = GetParam(request, "alias")
+		if err := users.ValidateUserAlias(alias); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if reactions, err := ar.GetUserReactions(alias, auth.MustGetUser(request).Id); err == nil {
+			JSON.Ok(writer, reactions)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
 // addComment handles the POST "/artworks/:artworkId/comments route
 func addComment(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -338,9 +269,11 @@ func addComment(ar Storer) http.HandlerFunc {
 		}
 
 		var artworkId = GetParam(request, "artworkId")
-		id, date, err := ar.AddComment(auth.MustGetUser(request).Id, artworkId, data)
-
-		if err != nil {
+		id, date, err := ar.AddComment(auth.MustGetUser(request).Id, artworkId, data, parseMentions(data.Comment))
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Artwork not found")
+			return
+		} else if err != nil {
 			JSON.InternalServerError(writer, err)
 			return
 		}
@@ -355,6 +288,37 @@ func addComment(ar Storer) http.HandlerFunc {
 	}
 }
 
+// editComment handles the authenticated PUT "/artworks/:artworkId/comments/:commentId" route, replacing a comment's
+// text; its mentions are parsed anew.
+func editComment(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[AddCommentData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var artworkId, commentId, userId = GetParam(request, "artworkId"), GetParam(request, "commentId"),
+			auth.MustGetUser(request).Id
+		date, err := ar.EditComment(userId, artworkId, commentId, data, parseMentions(data.Comment))
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Comment not found, or unauthorised action")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		JSON.Ok(writer, struct {
+			Id     string
+			Edited ntime.NTime
+		}{
+			Id:     commentId,
+			Edited: date,
+		})
+	}
+}
+
 // deleteComment handles the authenticated DELETE "/artworks/:artworkId/comments/:commentId" route
 func deleteComment(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -393,13 +357,7 @@ func getArtworkReactions(ar Storer) http.HandlerFunc {
 // getStream handles the authenticated GET "/users/:alias/stream?since=date&latest=date" route
 func getStream(ar Storer) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// check whether the user has legitimate access to the route
 		var user = auth.MustGetUser(request)
-		if user.Alias != GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// get and validate the two required parameters from the URL query
 		var since, latest, err = getStreamParams(request.URL.Query())
 		if err != nil {
@@ -444,3 +402,40 @@ func setTitle(ar Storer) http.HandlerFunc {
 		}
 	}
 }
+
+// setVisibility handles the authenticated PUT "/artworks/:artworkId/visibility" route
+func setVisibility(ar Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var artworkId = GetParam(request, "artworkId")
+		if !isValidArtworkId(artworkId) {
+			JSON.BadRequestWithMessage(writer, "invalid artwork ID provided")
+			return
+		}
+
+		data, err := JSON.DecodeValidate[UpdateVisibilityData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if err = ar.SetArtworkVisibility(artworkId, auth.MustGetUser(request).Id, data.Visibility); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Unauthorised action or resource not found")
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getStorageUsage handles the authenticated GET "/users/:alias/storage" route, reporting the user's storage usage
+// against their quota
+func getStorageUsage(ar Storer, quota Quota) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if usage, err := ar.GetStorageUsage(auth.MustGetUser(request).Id, quota); err == nil {
+			JSON.Ok(writer, usage)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package artworks
 
 import (
+	"errors"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/silktrader/kvasari/pkg/ntime"
 	"github.com/silktrader/kvasari/pkg/users"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type ArtworkType string
@@ -27,20 +32,43 @@ const (
 	WEBP ImageFormat = "webp"
 )
 
+// Visibility determines who can access an artwork, besides its author.
+type Visibility string
+
+const (
+	Public    Visibility = "public"    // anyone, save for banned users and non-followers of private accounts
+	Followers Visibility = "followers" // approved followers
+	Unlisted  Visibility = "unlisted"  // as public ones, but only reachable by ID, rather than in listings
+	Private   Visibility = "private"   // the author alone
+)
+
+var visibilityRules = []validation.Rule{validation.In(Public, Followers, Unlisted, Private)}
+
+// ValidateVisibility checks an optional visibility value; empty strings are valid, meaning the default one.
+func ValidateVisibility(visibility Visibility) error {
+	return validation.Validate(visibility, visibilityRules...)
+}
+
 // Artwork describes all the publicly available metadata relevant to an artwork.
 type Artwork struct {
-	Author      ArtworkAuthor
-	Title       *string
-	Description *string
-	Format      string
-	Location    *string
-	Year        *int
-	Type        ArtworkType
-	Created     ntime.NTime
-	Added       ntime.NTime
-	Updated     ntime.NTime
-	Comments    int
-	Reactions   int
+	Author              ArtworkAuthor
+	Title               *string
+	Description         *string
+	DescriptionMentions []Mention
+	Cover               string
+	Format              string
+	Images              int
+	Tags                []string
+	Location            *string
+	Year                *int
+	Type                ArtworkType
+	Visibility          Visibility
+	Created             ntime.NTime
+	Added               ntime.NTime
+	Updated             ntime.NTime
+	Comments            int
+	Reactions           int
+	ReactionsSummary
 }
 
 // ArtworkAuthor holds data relevant for artwork data responses.
@@ -52,10 +80,277 @@ type ArtworkAuthor struct {
 }
 
 type AddArtworkData struct {
-	Id       string
-	AuthorId string
-	Format   ImageFormat
-	Type     ArtworkType
+	Id         string
+	AuthorId   string
+	Format     ImageFormat
+	Type       ArtworkType
+	Visibility Visibility
+
+	// Size is the image's size in bytes, accounted in the author's storage usage
+	Size int64
+}
+
+// Quota limits the storage taken up by each user's artworks; zero values lift the related limit.
+type Quota struct {
+	Bytes    int64
+	Artworks int
+}
+
+// StorageUsage reports the storage taken up by a user's artworks, along with the quota's limits.
+type StorageUsage struct {
+	Bytes       int64
+	Artworks    int
+	MaxBytes    int64
+	MaxArtworks int
+}
+
+// Artwork images
+
+// maxArtworkImages limits the images of each artwork, its cover included.
+const maxArtworkImages = 20
+
+// ArtworkImage is one of an artwork's images, named after its hash and format. Images are indexed from zero, in their
+// authors' order, and the first one is the artwork's cover.
+type ArtworkImage struct {
+	Id     string
+	Index  int
+	Format ImageFormat
+	Size   int64
+	Added  ntime.NTime
+}
+
+// AddArtworkImageData describes a staged image, to be appended to an artwork's images.
+type AddArtworkImageData struct {
+	Id     string
+	Format ImageFormat
+	Size   int64
+}
+
+// SortImagesData lists the IDs of all an artwork's images, in their new order.
+type SortImagesData struct {
+	Images []string
+}
+
+func (data SortImagesData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Images,
+			validation.Required,
+			validation.Length(1, maxArtworkImages),
+			validation.Each(validation.Required),
+		),
+	)
+}
+
+// Tags
+
+// maxArtworkTags limits the tags of each artwork, whether set by their authors or written as hashtags.
+const maxArtworkTags = 10
+
+// tagPattern matches normalised tags: lowercase letters, digits and underscores, in any script.
+var tagPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}_]{1,30}$`)
+
+// hashtagPattern matches the hashtags of descriptions, provided they don't follow word characters, as in URL anchors.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&/])#([\p{L}\p{N}_]+)`)
+
+var ErrInvalidTag = errors.New("tags must be made of up to 30 letters, digits or underscores")
+
+// NormaliseTag lowercases a tag and strips its leading hash and surrounding spaces, then validates it.
+func NormaliseTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if !tagPattern.MatchString(tag) {
+		return tag, ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// normaliseTags normalises tags, dropping duplicates while preserving their order.
+func normaliseTags(tags []string) ([]string, error) {
+	var normalised = make([]string, 0, len(tags))
+	var seen = make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag, err := NormaliseTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalised = append(normalised, tag)
+		}
+	}
+	return normalised, nil
+}
+
+// extractHashtags returns the normalised hashtags of a text, in order of appearance and without duplicates. Invalid
+// hashtags, such as overly long ones, are ignored.
+func extractHashtags(text string) []string {
+	var hashtags = make([]string, 0)
+	var seen = make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		if tag, err := NormaliseTag(match[1]); err == nil && !seen[tag] {
+			seen[tag] = true
+			hashtags = append(hashtags, tag)
+		}
+	}
+	return hashtags
+}
+
+// UpdateTagsData replaces the tags set by an artwork's author; hashtags are kept.
+type UpdateTagsData struct {
+	Tags []string
+}
+
+func (data UpdateTagsData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Tags, validation.NotNil, validation.Length(0, maxArtworkTags),
+			validation.Each(validation.By(func(value interface{}) error {
+				_, err := NormaliseTag(value.(string))
+				return err
+			})),
+		),
+	)
+}
+
+// UpdateDescriptionData replaces an artwork's description, whose hashtags become tags; nil values clear it.
+type UpdateDescriptionData struct {
+	Description *string
+}
+
+func (data UpdateDescriptionData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Description, validation.NilOrNotEmpty, validation.Length(1, 3000)),
+	)
+}
+
+// TrendingTag is a tag along with the number of artworks it was recently attached to.
+type TrendingTag struct {
+	Tag      string
+	Artworks int
+}
+
+// TrendingOptions determines how trending tags are ranked; the values are sourced from configuration.
+type TrendingOptions struct {
+	Window time.Duration // how far back in time tags' uses are counted
+	Limit  int           // how many tags are ranked
+}
+
+// maxTagPage caps how deep users can browse tags, as pages are fetched by offset.
+const maxTagPage = 100
+
+// TaggedArtworks is a page of the artworks sharing a tag, most recent first.
+type TaggedArtworks struct {
+	Tag      string
+	Page     int
+	Artworks []ArtworkStreamPreview
+}
+
+// Collections
+
+// CollectionVisibility determines who can see a collection, besides its owner.
+type CollectionVisibility string
+
+const (
+	PublicCollection  CollectionVisibility = "public"  // anyone the owner's artworks would be visible to
+	PrivateCollection CollectionVisibility = "private" // the owner alone
+)
+
+// maxCollectionEntries limits the artworks of each collection.
+const maxCollectionEntries = 1000
+
+// CollectionData describes a collection's editable attributes. Visibility defaults to public.
+type CollectionData struct {
+	Name        string
+	Description *string
+	Visibility  CollectionVisibility
+}
+
+func (data CollectionData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&data.Description, validation.NilOrNotEmpty, validation.Length(1, 1000)),
+		validation.Field(&data.Visibility, validation.In(PublicCollection, PrivateCollection)),
+	)
+}
+
+// SortCollectionData lists some of a collection's artworks, which are moved ahead of the remaining ones.
+type SortCollectionData struct {
+	Artworks []string
+}
+
+func (data SortCollectionData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Artworks,
+			validation.Required,
+			validation.Length(1, maxCollectionEntries),
+			validation.Each(validation.Required),
+		),
+	)
+}
+
+// Collection is a named, ordered list of artworks curated by a user. Entries counts the artworks visible to the
+// requester alone.
+type Collection struct {
+	Id          string
+	Name        string
+	Description *string
+	Visibility  CollectionVisibility
+	Entries     int
+	Created     ntime.NTime
+	Updated     ntime.NTime
+}
+
+// CollectionEntry is an artwork listed in a collection, as of the date it was added.
+type CollectionEntry struct {
+	Id     string
+	Title  *string
+	Author ArtworkPreviewAuthor
+	Cover  string
+	Format string
+	Added  ntime.NTime
+}
+
+// CollectionDetails holds a collection's metadata, along with the artworks the requester may see, in order.
+type CollectionDetails struct {
+	Collection
+	Artworks []CollectionEntry
+}
+
+// Resumable uploads
+
+// CreateUploadData announces a resumable upload, whose image is then sent in chunks. Visibility is optional.
+type CreateUploadData struct {
+	Size       int64
+	Visibility Visibility
+}
+
+func (data CreateUploadData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Size, validation.Required, validation.Max(int64(maxFileUploadSize))),
+		validation.Field(&data.Visibility, visibilityRules...),
+	)
+}
+
+// UploadSession reports a resumable upload's progress; Offset is the number of bytes received so far.
+// Sessions expire when left idle, along with their chunks.
+type UploadSession struct {
+	Id         string
+	Size       int64
+	Offset     int64
+	Visibility Visibility
+	Created    ntime.NTime
+	Updated    ntime.NTime
+	Expires    ntime.NTime
+}
+
+// Edit an artwork's visibility
+
+type UpdateVisibilityData struct {
+	Visibility Visibility
+}
+
+func (data UpdateVisibilityData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Visibility, append([]validation.Rule{validation.Required}, visibilityRules...)...),
+	)
 }
 
 // Edit and artwork title
@@ -74,12 +369,51 @@ func (data UpdateArtworkTitleData) Validate() error {
 
 type ReactionType string
 
+// Like and Perplexed are the reaction types seeded in new databases; others may be defined in `reaction_types`.
 const (
 	Like      ReactionType = "Like"
 	Perplexed ReactionType = "Perplexed"
 )
 
-var reactions = []interface{}{Like, Perplexed}
+// ReactionKind describes an available reaction type, along with the path of the icon representing it.
+type ReactionKind struct {
+	Name ReactionType
+	Icon string
+}
+
+// reactionKinds holds the reaction types loaded from the database, so that requests can be validated against them.
+var reactionKinds = reactionRegistry{kinds: make(map[ReactionType]ReactionKind)}
+
+type reactionRegistry struct {
+	mutex sync.RWMutex
+	kinds map[ReactionType]ReactionKind
+}
+
+func (registry *reactionRegistry) set(kinds []ReactionKind) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.kinds = make(map[ReactionType]ReactionKind, len(kinds))
+	for _, kind := range kinds {
+		registry.kinds[kind.Name] = kind
+	}
+}
+
+func (registry *reactionRegistry) has(reaction ReactionType) bool {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	_, found := registry.kinds[reaction]
+	return found
+}
+
+var ErrUnknownReaction = errors.New("unknown reaction type")
+
+// isReactionType is a validation rule ensuring the reaction type was loaded from the database.
+func isReactionType(value interface{}) error {
+	if reaction, ok := value.(ReactionType); !ok || !reactionKinds.has(reaction) {
+		return ErrUnknownReaction
+	}
+	return nil
+}
 
 type AddReactionRequest struct {
 	Reaction ReactionType
@@ -88,10 +422,31 @@ type AddReactionRequest struct {
 func (data AddReactionRequest) Validate() error {
 	return validation.ValidateStruct(&data, validation.Field(&data.Reaction,
 		validation.Required,
-		validation.In(reactions...),
+		validation.By(isReactionType),
 	))
 }
 
+// ReactionsSummary breaks down an artwork's reactions by type and reports the requester's own reaction, if any.
+// It's embedded in artwork responses, along with the total count of reactions.
+type ReactionsSummary struct {
+	ReactionsByType map[ReactionType]int
+	UserReaction    *ReactionType
+}
+
+func newReactionsSummary() ReactionsSummary {
+	return ReactionsSummary{ReactionsByType: make(map[ReactionType]int)}
+}
+
+// UserReactionData describes an artwork a user reacted to, for the user's reactions listing.
+type UserReactionData struct {
+	ArtworkId string
+	Title     *string
+	Format    string
+	Author    ArtworkPreviewAuthor
+	Reaction  ReactionType
+	Date      ntime.NTime
+}
+
 type ReactionResponse struct {
 	AuthorAlias string
 	AuthorName  string
@@ -118,12 +473,73 @@ type CommentResponse struct {
 	AuthorName  string
 	Comment     string
 	Date        ntime.NTime
+	Edited      *ntime.NTime
+	Mentions    []Mention
+}
+
+// mentionPattern matches the `@alias` mentions of comments, provided they don't follow word characters, as in emails.
+// Aliases are made of letters and digits, in any script, and are matched as typed, since they're case-sensitive.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.-])@([\p{L}\p{N}]+)`)
+
+// maxNotifiedMentions limits the users notified by each comment, whose remaining mentions are recorded all the same.
+const maxNotifiedMentions = 10
+
+/*
+Mention is a user addressed by a comment, or an artwork's description, as `@alias`. Offset and Length locate the
+mention's text, at sign included, counting characters rather than bytes. Alias is the user's current one, which
+differs from the mention's text once users change their aliases, so that clients can render links which survive
+alias changes.
+*/
+type Mention struct {
+	UserId string
+	Alias  string
+	Offset int
+	Length int
+}
+
+// parseMentions returns the well-formed mentions of a comment, or description, in order of appearance, whose users
+// are yet to be resolved by alias.
+func parseMentions(comment string) []Mention {
+	var mentions = make([]Mention, 0)
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(comment, -1) {
+		// the mention starts with the at sign preceding the alias' group, excluding the leading separator
+		var start, end = match[2] - 1, match[3]
+		var alias = comment[match[2]:end]
+		if users.ValidateUserAlias(alias) != nil {
+			continue
+		}
+		mentions = append(mentions, Mention{
+			Alias:  alias,
+			Offset: utf8.RuneCountInString(comment[:start]),
+			Length: utf8.RuneCountInString(comment[start:end]),
+		})
+	}
+	return mentions
+}
+
+// Notifications
+
+// maxNotifications limits the number of notifications returned at once, the most recent ones.
+const maxNotifications = 100
+
+// Notification tells a user about another one's action concerning them; only mentions are notified, either in
+// comments or in artworks' descriptions, whose notifications lack comments.
+type Notification struct {
+	Id         string
+	Kind       string
+	ActorAlias string
+	ActorName  string
+	Artwork    string
+	Comment    *string
+	Date       ntime.NTime
 }
 
 // Profile Response DTOs
 
+// ProfileData gathers what's required to render a user's profile page in a single response.
 type ProfileData struct {
-	// TotalArtworks refers to the total number of artworks uploaded by a user, as opposed to the ones sent in the resp.
+	Details users.UserDetails
+	// TotalArtworks counts the user's artworks the requester may see listed, as opposed to the ones sent in the resp.
 	TotalArtworks int
 	Artworks      []ArtworkData
 	Followers     []users.RelationData
@@ -142,21 +558,27 @@ type UserArtworks struct {
 	Deleted   []string
 }
 
-// ArtworkData describes a preview of the metadata related to each artwork, including comment, reactions aggregates.
+// ArtworkData describes a preview of the metadata related to each artwork, including its cover, the number of its
+// images, and comment, reactions aggregates.
 type ArtworkData struct {
 	Id        string
 	Title     *string // the alternative is to use sql.NullString and a custom marshaller
+	Cover     string
 	Format    string
+	Images    int
 	Added     ntime.NTime
 	Comments  int
 	Reactions int
+	ReactionsSummary
 }
 
-// PageData specifies pagination details for various endpoint handlers and store methods
+// PageData specifies pagination details for various endpoint handlers and store methods; the first page, made of the
+// latest items, is requested by setting firstPage, in which case timestamps are ignored
 type PageData struct {
-	pageSize int
-	since    string
-	latest   string
+	pageSize  int
+	since     string
+	latest    string
+	firstPage bool
 }
 
 // I wasted one hour of my life attempting to find out why my custom format wouldn't work
@@ -174,10 +596,13 @@ type ArtworkStreamPreview struct {
 	Id        string
 	Title     *string
 	Author    ArtworkPreviewAuthor
+	Cover     string
 	Format    string
+	Images    int
 	Reactions int
 	Comments  int
 	Added     ntime.NTime
+	ReactionsSummary
 }
 
 type ArtworkPreviewAuthor struct {
@@ -185,9 +610,61 @@ type ArtworkPreviewAuthor struct {
 	Name  string
 }
 
-// ImageMetadata contains data required to correctly display an artwork; it may be later expanded to include dimensions.
-type ImageMetadata struct {
-	Format string
+/* Discovery related data */
+
+// DiscoveryWeights determines how discovery candidates are scored; the values are sourced from configuration.
+type DiscoveryWeights struct {
+	Reaction       float64       // score added by each reaction
+	Comment        float64       // score added by each comment
+	FollowedAuthor float64       // multiplier applied to artworks whose authors are followed by the requester
+	NetworkAuthor  float64       // multiplier applied to authors followed by the requester's followed users
+	HalfLife       time.Duration // the age at which an artwork's score is halved
+	Window         time.Duration // how far back in time candidates are looked for
+}
+
+// DiscoveryCandidate is an artwork eligible for a user's discovery feed, along with its relations to the requester.
+type DiscoveryCandidate struct {
+	Artwork        ArtworkStreamPreview
+	FollowedAuthor bool
+	NetworkAuthor  bool
+}
+
+// maxDiscoveryPage caps how deep users can dig into their discovery feed, as candidates are scored in memory.
+const maxDiscoveryPage = 20
+
+var ErrInvalidPage = errors.New("`page` must be a non-negative integer")
+
+/* Personal data exports */
+
+// AuthoredArtwork holds all the metadata of an artwork, as stored, for its author's consumption.
+type AuthoredArtwork struct {
+	Id          string
+	Title       *string
+	Description *string
+	Type        ArtworkType
+	Visibility  Visibility
+	Format      ImageFormat
+	Location    *string
+	Year        *int
+	Created     ntime.NTime
+	Added       ntime.NTime
+	Updated     ntime.NTime
+	Images      []ArtworkImage
+}
+
+// AuthoredComment is a comment written by a user, on any artwork.
+type AuthoredComment struct {
+	Id        string
+	ArtworkId string
+	Comment   string
+	Date      ntime.NTime
+}
+
+// AuthoredReaction is a reaction left by a user, on any artwork.
+type AuthoredReaction struct {
+	ArtworkId string
+	Reaction  ReactionType
+	Date      ntime.NTime
 }
 
 /* Route parameters validation.
@@ -207,6 +684,18 @@ func getStreamParams(streamParams url.Values) (since string, latest string, err
 	return since, latest, err
 }
 
+// getPage returns the value of the optional `page` query parameter, up to maxPage, defaulting to the first page.
+func getPage(params url.Values, maxPage int) (page int, err error) {
+	var value = params.Get("page")
+	if value == "" {
+		return 0, nil
+	}
+	if page, err = strconv.Atoi(value); err != nil {
+		return page, ErrInvalidPage
+	}
+	return page, validation.Validate(page, validation.Min(0), validation.Max(maxPage))
+}
+
 // validateArtworkIdParam verified that the provided ID it's a valid SHA-256 hash.
 func isValidArtworkId(artworkId string) bool {
 	match, err := regexp.MatchString("^[a-f0-9]{64}$", artworkId)
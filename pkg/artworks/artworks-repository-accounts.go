@@ -0,0 +1,45 @@
+package artworks
+
+import (
+	"github.com/silktrader/kvasari/pkg/ntime"
+)
+
+/*
+PurgeDeletedAccounts permanently removes the accounts deleted before the given date, returning their number.
+
+Artworks are removed first, as their foreign key doesn't cascade, followed by their images' files.
+Followers, bans, comments and reactions are removed by the database's cascading foreign keys.
+*/
+func (ar *Store) PurgeDeletedAccounts(deletedBefore ntime.NTime) (int64, error) {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	files, _, err := deleteArtworks(tx, ar.GetImagesPath(),
+		`author_id IN (SELECT id FROM users WHERE deleted < ?)`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE deleted < ?`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// files are removed once the transaction succeeds
+	return purged, removeFiles(files)
+}
@@ -0,0 +1,315 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+)
+
+var (
+	ErrCollectionFull  = errors.New("collection entries limit reached")
+	ErrCollectionOrder = errors.New("artworks must be listed once each, among the collection's ones")
+)
+
+// visibleCollection selects the collections `@requester` may see: their own ones, and the public ones of owners who
+// didn't ban them, provided the owners' accounts aren't private or are followed by the requester.
+const visibleCollection = `(collections.owner = @requester OR (
+	collections.visibility = 'public'
+	AND collections.owner NOT IN ` + banningUsers + `
+	AND (NOT (SELECT private FROM users WHERE id = collections.owner)
+		OR collections.owner IN (SELECT target FROM followers WHERE follower = @requester))))`
+
+// visibleEntry selects the collections' entries `@requester` may see. Unlisted artworks are included for the
+// collections' owners alone, who could reach them by ID.
+const visibleEntry = `(NOT artworks.deleted AND (` + listedArtwork + ` OR (collections.owner = @requester AND ` +
+	visibleArtwork + `)))`
+
+// GetCollections lists the collections of a user which the requester may see, most recently updated first.
+func (ar *Store) GetCollections(ownerAlias, requesterId string) ([]Collection, error) {
+	rows, err := ar.Connection.Query(`
+		SELECT id, name, description, visibility, created, updated,
+		       (SELECT count(*) FROM collection_entries JOIN artworks ON collection_entries.artwork = artworks.id
+		        WHERE collection = collections.id AND `+visibleEntry+`) as entries
+		FROM collections
+		WHERE owner = (SELECT id FROM users WHERE alias = @alias) AND `+visibleCollection+`
+		ORDER BY updated DESC`,
+		sql.Named("alias", ownerAlias), sql.Named("requester", requesterId),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var collections = make([]Collection, 0)
+	for rows.Next() {
+		var collection Collection
+		if err = rows.Scan(
+			&collection.Id,
+			&collection.Name,
+			&collection.Description,
+			&collection.Visibility,
+			&collection.Created,
+			&collection.Updated,
+			&collection.Entries,
+		); err != nil {
+			return collections, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection fetches a user's collection along with its artworks, in order, provided the requester may see it.
+func (ar *Store) GetCollection(collectionId, ownerAlias, requesterId string) (CollectionDetails, error) {
+	var details = CollectionDetails{Collection: Collection{Id: collectionId}, Artworks: make([]CollectionEntry, 0)}
+	err := ar.Connection.QueryRow(`
+		SELECT name, description, visibility, created, updated FROM collections
+		WHERE id = @collection AND owner = (SELECT id FROM users WHERE alias = @alias) AND `+visibleCollection,
+		sql.Named("collection", collectionId), sql.Named("alias", ownerAlias), sql.Named("requester", requesterId),
+	).Scan(&details.Name, &details.Description, &details.Visibility, &details.Created, &details.Updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return details, ErrNotFound
+	} else if err != nil {
+		return details, err
+	}
+
+	rows, err := ar.Connection.Query(`
+		SELECT artworks.id, artworks.title, users.alias, users.name, artworks.cover, artworks.format,
+		       collection_entries.added
+		FROM collection_entries
+		JOIN collections ON collection_entries.collection = collections.id
+		JOIN artworks ON collection_entries.artwork = artworks.id
+		JOIN users ON artworks.author_id = users.id
+		WHERE collection = @collection AND `+visibleEntry+`
+		ORDER BY position`,
+		sql.Named("collection", collectionId), sql.Named("requester", requesterId),
+	)
+	if err != nil {
+		return details, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var entry CollectionEntry
+		if err = rows.Scan(
+			&entry.Id,
+			&entry.Title,
+			&entry.Author.Alias,
+			&entry.Author.Name,
+			&entry.Cover,
+			&entry.Format,
+			&entry.Added,
+		); err != nil {
+			return details, err
+		}
+		details.Artworks = append(details.Artworks, entry)
+	}
+	details.Entries = len(details.Artworks)
+	return details, rows.Err()
+}
+
+// CreateCollection adds an empty collection owned by the user.
+func (ar *Store) CreateCollection(userId string, data CollectionData) (Collection, error) {
+	var now = ntime.Now()
+	var collection = Collection{
+		Id:          rest.MustGetNewUUID(),
+		Name:        data.Name,
+		Description: data.Description,
+		Visibility:  data.Visibility,
+		Created:     now,
+		Updated:     now,
+	}
+	if collection.Visibility == "" {
+		collection.Visibility = PublicCollection
+	}
+
+	_, err := ar.Connection.Exec(`
+		INSERT INTO collections (id, owner, name, description, visibility, created, updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		collection.Id, userId, collection.Name, collection.Description, collection.Visibility, now, now,
+	)
+	return collection, err
+}
+
+// UpdateCollection edits a user's collection; returns ErrNotFound when the user doesn't own it.
+func (ar *Store) UpdateCollection(collectionId, userId string, data CollectionData) error {
+	if data.Visibility == "" {
+		data.Visibility = PublicCollection
+	}
+	result, err := ar.Connection.Exec(`
+		UPDATE collections SET name = ?, description = ?, visibility = ?, updated = ? WHERE id = ? AND owner = ?`,
+		data.Name, data.Description, data.Visibility, ntime.Now(), collectionId, userId,
+	)
+	return checkAffected(result, err)
+}
+
+// DeleteCollection removes a user's collection along with its entries, but not their artworks.
+func (ar *Store) DeleteCollection(collectionId, userId string) error {
+	result, err := ar.Connection.Exec(`DELETE FROM collections WHERE id = ? AND owner = ?`, collectionId, userId)
+	return checkAffected(result, err)
+}
+
+/*
+AddCollectionEntry appends an artwork to a user's collection, provided the user may see the artwork; adding artworks
+twice has no effect. Returns ErrNotFound when either the collection or the artwork are missing, and ErrCollectionFull
+when the collection can't take more entries.
+*/
+func (ar *Store) AddCollectionEntry(collectionId, userId, artworkId string) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var entries, visible int
+	err = tx.QueryRow(`
+		SELECT (SELECT count(*) FROM collection_entries WHERE collection = @collection),
+		       (SELECT count(*) FROM artworks WHERE id = @artwork AND NOT deleted AND `+visibleArtwork+`)
+		FROM collections WHERE id = @collection AND owner = @requester`,
+		sql.Named("collection", collectionId), sql.Named("artwork", artworkId), sql.Named("requester", userId),
+	).Scan(&entries, &visible)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && visible == 0) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if entries >= maxCollectionEntries {
+		return ErrCollectionFull
+	}
+
+	var now = ntime.Now()
+	result, err := tx.Exec(`
+		INSERT INTO collection_entries (collection, artwork, position, added)
+		SELECT @collection, @artwork, coalesce(max(position) + 1, 0), @now
+		FROM collection_entries WHERE collection = @collection
+		ON CONFLICT DO NOTHING`,
+		sql.Named("collection", collectionId), sql.Named("artwork", artworkId), sql.Named("now", now),
+	)
+	if err != nil {
+		return err
+	}
+	if added, e := result.RowsAffected(); e != nil {
+		return e
+	} else if added == 0 {
+		return nil
+	}
+	if _, err = tx.Exec(`UPDATE collections SET updated = ? WHERE id = ?`, now, collectionId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveCollectionEntry removes an artwork from a user's collection; returns ErrNotFound when either is missing.
+func (ar *Store) RemoveCollectionEntry(collectionId, userId, artworkId string) error {
+	result, err := ar.Connection.Exec(`
+		DELETE FROM collection_entries WHERE collection = (SELECT id FROM collections WHERE id = ? AND owner = ?)
+		AND artwork = ?`,
+		collectionId, userId, artworkId,
+	)
+	if err = checkAffected(result, err); err != nil {
+		return err
+	}
+	_, err = ar.Connection.Exec(`UPDATE collections SET updated = ? WHERE id = ?`, ntime.Now(), collectionId)
+	return err
+}
+
+/*
+SortCollection moves the listed artworks ahead of a user's collection, in the given order, while the remaining ones
+follow in their former order. Hence owners needn't list the entries they can no longer see. Returns ErrNotFound when
+the collection is missing and ErrCollectionOrder when artworks are listed twice, or don't belong to the collection.
+*/
+func (ar *Store) SortCollection(collectionId, userId string, artworkIds []string) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.Query(`
+		SELECT artwork FROM collection_entries
+		WHERE collection = (SELECT id FROM collections WHERE id = ? AND owner = ?)
+		ORDER BY position`,
+		collectionId, userId,
+	)
+	if err != nil {
+		return err
+	}
+	var current = make([]string, 0)
+	var artworkId string
+	for rows.Next() {
+		if err = rows.Scan(&artworkId); err != nil {
+			closeRows(rows)
+			return err
+		}
+		current = append(current, artworkId)
+	}
+	closeRows(rows)
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// empty collections are told apart from missing ones, which aren't owned by the user either
+	if len(current) == 0 {
+		var exists bool
+		if err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM collections WHERE id = ? AND owner = ?)`,
+			collectionId, userId,
+		).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+
+	var listed = make(map[string]bool, len(current))
+	for _, id := range current {
+		listed[id] = false
+	}
+	var order = make([]string, 0, len(current))
+	for _, id := range artworkIds {
+		if moved, found := listed[id]; !found || moved {
+			return ErrCollectionOrder
+		}
+		listed[id] = true
+		order = append(order, id)
+	}
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
+	}
+
+	for position, id := range order {
+		if _, err = tx.Exec(`UPDATE collection_entries SET position = ? WHERE collection = ? AND artwork = ?`,
+			position, collectionId, id,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(`UPDATE collections SET updated = ? WHERE id = ?`, ntime.Now(), collectionId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkAffected maps statements which didn't affect any row to ErrNotFound.
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if affected, e := result.RowsAffected(); e != nil {
+		return e
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
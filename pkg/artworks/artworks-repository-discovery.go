@@ -0,0 +1,64 @@
+package artworks
+
+import (
+	"database/sql"
+	"github.com/silktrader/kvasari/pkg/ntime"
+)
+
+// maxDiscoveryCandidates limits the number of recent artworks fetched to be ranked in memory.
+const maxDiscoveryCandidates = 500
+
+// GetDiscoveryCandidates fetches the most recent artworks added after `since`, which weren't authored by the
+// requester, along with their feedback counts and the author's proximity to the requester.
+// Only the artworks the requester may see listed are included, which excludes bans in both directions: neither
+// authors banning the requester nor those banned by them are included. Muted authors are left out as well.
+func (ar *Store) GetDiscoveryCandidates(userId string, since ntime.NTime) ([]DiscoveryCandidate, error) {
+	var candidates = make([]DiscoveryCandidate, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT artworks.id, title, alias, name, cover, format, added,
+		       (SELECT count(*) FROM artwork_images WHERE artwork = artworks.id) as images,
+		       (SELECT count(*) FROM artwork_comments WHERE artwork = artworks.id) as comments,
+		       (SELECT count(*) FROM artwork_feedback WHERE artwork = artworks.id) as reactions,
+		       author_id IN (SELECT target FROM followers WHERE follower = @requester) as followed,
+		       author_id IN (
+		           SELECT second.target FROM followers as first
+		           JOIN followers as second ON first.target = second.follower
+		           WHERE first.follower = @requester) as network
+		FROM artworks JOIN users ON artworks.author_id = users.id
+		WHERE NOT artworks.deleted AND added > @since AND author_id != @requester
+		AND author_id NOT IN `+mutedUsers+`
+		AND `+listedArtwork+`
+		ORDER BY added DESC LIMIT @limit`,
+		sql.Named("requester", userId),
+		sql.Named("since", since),
+		sql.Named("now", ntime.Now()),
+		sql.Named("limit", maxDiscoveryCandidates),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var candidate DiscoveryCandidate
+		if err = rows.Scan(
+			&candidate.Artwork.Id,
+			&candidate.Artwork.Title,
+			&candidate.Artwork.Author.Alias,
+			&candidate.Artwork.Author.Name,
+			&candidate.Artwork.Cover,
+			&candidate.Artwork.Format,
+			&candidate.Artwork.Added,
+			&candidate.Artwork.Images,
+			&candidate.Artwork.Comments,
+			&candidate.Artwork.Reactions,
+			&candidate.FollowedAuthor,
+			&candidate.NetworkAuthor,
+		); err != nil {
+			return candidates, err
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, rows.Err()
+}
@@ -0,0 +1,118 @@
+package artworks
+
+// GetAuthoredArtworks returns the metadata of all the artworks added by a user, along with their images, soft-deleted
+// ones excluded.
+func (ar *Store) GetAuthoredArtworks(userId string) ([]AuthoredArtwork, error) {
+	var artworks = make([]AuthoredArtwork, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT id, title, description, type, visibility, format, location, year, created, added, updated
+		FROM artworks WHERE author_id = ? AND NOT deleted
+		ORDER BY added DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var artwork AuthoredArtwork
+		if err = rows.Scan(
+			&artwork.Id,
+			&artwork.Title,
+			&artwork.Description,
+			&artwork.Type,
+			&artwork.Visibility,
+			&artwork.Format,
+			&artwork.Location,
+			&artwork.Year,
+			&artwork.Created,
+			&artwork.Added,
+			&artwork.Updated,
+		); err != nil {
+			return artworks, err
+		}
+		artwork.Images = make([]ArtworkImage, 0, 1)
+		artworks = append(artworks, artwork)
+	}
+	if err = rows.Err(); err != nil {
+		return artworks, err
+	}
+	return artworks, ar.addAuthoredImages(userId, artworks)
+}
+
+// addAuthoredImages fills the images of a user's authored artworks, in order.
+func (ar *Store) addAuthoredImages(userId string, artworks []AuthoredArtwork) error {
+	var indexes = make(map[string]int, len(artworks))
+	for index, artwork := range artworks {
+		indexes[artwork.Id] = index
+	}
+
+	rows, err := ar.Connection.Query(`
+		SELECT artwork, id, format, size, added FROM artwork_images
+		WHERE artwork IN (SELECT id FROM artworks WHERE author_id = ? AND NOT deleted)
+		ORDER BY artwork, position`,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	var artworkId string
+	for rows.Next() {
+		var image ArtworkImage
+		if err = rows.Scan(&artworkId, &image.Id, &image.Format, &image.Size, &image.Added); err != nil {
+			return err
+		}
+		if index, found := indexes[artworkId]; found {
+			image.Index = len(artworks[index].Images)
+			artworks[index].Images = append(artworks[index].Images, image)
+		}
+	}
+	return rows.Err()
+}
+
+// GetAuthoredComments returns all the comments written by a user, regardless of the artworks' authors.
+func (ar *Store) GetAuthoredComments(userId string) ([]AuthoredComment, error) {
+	var comments = make([]AuthoredComment, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT id, artwork, comment, date FROM artwork_comments WHERE user = ? ORDER BY date DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var comment AuthoredComment
+		if err = rows.Scan(&comment.Id, &comment.ArtworkId, &comment.Comment, &comment.Date); err != nil {
+			return comments, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// GetAuthoredReactions returns all the reactions left by a user, regardless of the artworks' authors.
+func (ar *Store) GetAuthoredReactions(userId string) ([]AuthoredReaction, error) {
+	var reactions = make([]AuthoredReaction, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT artwork, reaction, date FROM artwork_feedback WHERE user = ? ORDER BY date DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var reaction AuthoredReaction
+		if err = rows.Scan(&reaction.ArtworkId, &reaction.Reaction, &reaction.Date); err != nil {
+			return reactions, err
+		}
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
@@ -0,0 +1,286 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"io/fs"
+	"os"
+)
+
+// querier is satisfied by both connections and transactions.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// mapImageError maps primary key violations to ErrDupArtwork, as images are unique among all artworks.
+func mapImageError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return ErrDupArtwork
+	}
+	return err
+}
+
+/*
+deleteArtworks hard-deletes the artworks matching the condition, returning their number and the paths of their image
+files, which callers remove once transactions are committed. Images, comments and reactions cascade.
+*/
+func deleteArtworks(q querier, imagesPath string, condition string, args ...any) ([]string, int64, error) {
+	rows, err := q.Query(`
+		SELECT id, format FROM artwork_images WHERE artwork IN (SELECT id FROM artworks WHERE `+condition+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var files = make([]string, 0)
+	var id, format string
+	for rows.Next() {
+		if err = rows.Scan(&id, &format); err != nil {
+			closeRows(rows)
+			return nil, 0, err
+		}
+		files = append(files, fmt.Sprintf("%s/%s.%s", imagesPath, id, format))
+	}
+	closeRows(rows)
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	result, err := q.Exec(`DELETE FROM artworks WHERE `+condition, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return files, deleted, err
+}
+
+// removeFiles removes the given files, reporting the last error; missing ones needn't be reported.
+func removeFiles(files []string) (err error) {
+	for _, file := range files {
+		if e := os.Remove(file); e != nil && !errors.Is(e, fs.ErrNotExist) {
+			err = e
+		}
+	}
+	return err
+}
+
+// GetArtworkImages lists an artwork's images in order, provided the artwork is visible to the requester.
+func (ar *Store) GetArtworkImages(artworkId, requesterId string) ([]ArtworkImage, error) {
+	rows, err := ar.Connection.Query(`
+		SELECT id, format, size, added FROM artwork_images
+		WHERE artwork = (SELECT id FROM artworks WHERE id = @artwork AND NOT deleted AND `+visibleArtwork+`)
+		ORDER BY position`,
+		sql.Named("artwork", artworkId), sql.Named("requester", requesterId),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var images = make([]ArtworkImage, 0)
+	for rows.Next() {
+		var image = ArtworkImage{Index: len(images)}
+		if err = rows.Scan(&image.Id, &image.Format, &image.Size, &image.Added); err != nil {
+			return images, err
+		}
+		images = append(images, image)
+	}
+	if err = rows.Err(); err != nil {
+		return images, err
+	}
+
+	// every artwork has an image at least, hence missing ones are either deleted or hidden
+	if len(images) == 0 {
+		return images, ErrNotFound
+	}
+	return images, nil
+}
+
+/*
+AddArtworkImage appends an image to an author's artwork, within the number of images allowed to each artwork and the
+author's storage quota. Returns ErrImagesLimit or ErrBytesQuota otherwise, and ErrDupArtwork when the image belongs to
+any artwork already.
+*/
+func (ar *Store) AddArtworkImage(
+	artworkId, userId string, data AddArtworkImageData, quota Quota,
+) (image ArtworkImage, err error) {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return image, err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var count int
+	if err = tx.QueryRow(`
+		SELECT count(*) FROM artwork_images
+		WHERE artwork = (SELECT id FROM artworks WHERE id = ? AND author_id = ? AND NOT deleted)`,
+		artworkId, userId,
+	).Scan(&count); err != nil {
+		return image, err
+	}
+	if count == 0 {
+		return image, ErrNotFound
+	}
+	if count >= maxArtworkImages {
+		return image, ErrImagesLimit
+	}
+
+	var usage int64
+	err = tx.QueryRow(`SELECT bytes FROM storage_usage WHERE user = ?`, userId).Scan(&usage)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return image, err
+	}
+	if quota.Bytes > 0 && usage+data.Size > quota.Bytes {
+		return image, ErrBytesQuota
+	}
+
+	image = ArtworkImage{Id: data.Id, Index: count, Format: data.Format, Size: data.Size, Added: ntime.Now()}
+	if _, err = tx.Exec(`
+		INSERT INTO artwork_images(id, artwork, position, format, size, added) VALUES(?, ?, ?, ?, ?, ?)`,
+		image.Id, artworkId, image.Index, image.Format, image.Size, image.Added,
+	); err != nil {
+		return image, mapImageError(err)
+	}
+	if _, err = tx.Exec(`UPDATE artworks SET updated = ? WHERE id = ?`, image.Added, artworkId); err != nil {
+		return image, err
+	}
+	return image, tx.Commit()
+}
+
+/*
+RemoveArtworkImage removes an image from an author's artwork, along with its file, and closes the gap in the images'
+order. Returns ErrLastImage when the image is the artwork's only one, as artworks can't lack images, and ErrFirstImage
+when the artwork was uploaded with it: artworks are identified by their first image's hash, which could otherwise be
+uploaded again as a new artwork, clashing with the former.
+*/
+func (ar *Store) RemoveArtworkImage(artworkId, userId, imageId string) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var count int
+	if err = tx.QueryRow(`
+		SELECT count(*) FROM artwork_images
+		WHERE artwork = (SELECT id FROM artworks WHERE id = ? AND author_id = ? AND NOT deleted)`,
+		artworkId, userId,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	if imageId == artworkId {
+		return ErrFirstImage
+	}
+
+	var position int
+	var format string
+	err = tx.QueryRow(`DELETE FROM artwork_images WHERE id = ? AND artwork = ? RETURNING position, format`,
+		imageId, artworkId,
+	).Scan(&position, &format)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if count == 1 {
+		return ErrLastImage
+	}
+
+	if _, err = tx.Exec(`UPDATE artwork_images SET position = position - 1 WHERE artwork = ? AND position > ?`,
+		artworkId, position,
+	); err != nil {
+		return err
+	}
+	if err = updateCover(tx, artworkId); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return removeFiles([]string{fmt.Sprintf("%s/%s.%s", ar.GetImagesPath(), imageId, format)})
+}
+
+/*
+SortArtworkImages rearranges an author's artwork images in the given order, which must list all of them once.
+The first one becomes the artwork's cover. Returns ErrImagesOrder otherwise.
+*/
+func (ar *Store) SortArtworkImages(artworkId, userId string, imageIds []string) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var count int
+	if err = tx.QueryRow(`
+		SELECT count(*) FROM artwork_images
+		WHERE artwork = (SELECT id FROM artworks WHERE id = ? AND author_id = ? AND NOT deleted)`,
+		artworkId, userId,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	if count != len(imageIds) {
+		return ErrImagesOrder
+	}
+
+	// duplicates would leave some images unaccounted for, since the counts match
+	var listed = make(map[string]bool, len(imageIds))
+	for position, imageId := range imageIds {
+		if listed[imageId] {
+			return ErrImagesOrder
+		}
+		listed[imageId] = true
+
+		result, e := tx.Exec(`UPDATE artwork_images SET position = ? WHERE id = ? AND artwork = ?`,
+			position, imageId, artworkId,
+		)
+		if e != nil {
+			return e
+		}
+		if affected, e := result.RowsAffected(); e != nil {
+			return e
+		} else if affected == 0 {
+			return ErrImagesOrder
+		}
+	}
+
+	if err = updateCover(tx, artworkId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateCover points an artwork's cover and format to its first image, after the images' order changes.
+func updateCover(tx *sql.Tx, artworkId string) error {
+	_, err := tx.Exec(`
+		UPDATE artworks SET (cover, format, updated) = (
+			SELECT id, format, ? FROM artwork_images WHERE artwork = artworks.id ORDER BY position LIMIT 1
+		) WHERE id = ?`,
+		ntime.Now(), artworkId,
+	)
+	return err
+}
@@ -0,0 +1,271 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+)
+
+// AddComment adds a user's comment to an artwork, along with its mentions, whose users are notified. Returns
+// ErrNotFound when the artwork is missing, deleted, or hidden from the user.
+func (ar *Store) AddComment(
+	userId, artworkId string, data AddCommentData, mentions []Mention,
+) (string, ntime.NTime, error) {
+	var id = rest.MustGetNewUUID()
+	var date = ntime.Now()
+
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return id, date, err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the commenter is the requester for whom the artwork's visibility is checked
+	result, err := tx.Exec(`
+		INSERT INTO artwork_comments (id, artwork, user, comment, date)
+		SELECT @id, @artwork, @requester, @comment, @date
+		WHERE EXISTS (SELECT TRUE FROM artworks WHERE id = @artwork AND NOT deleted AND `+visibleArtwork+`)`,
+		sql.Named("id", id),
+		sql.Named("artwork", artworkId),
+		sql.Named("requester", userId),
+		sql.Named("comment", data.Comment),
+		sql.Named("date", date),
+	)
+	if err = checkAffected(result, err); err != nil {
+		return id, date, err
+	}
+	if err = addMentions(tx, artworkId, &id, userId, mentions, date); err != nil {
+		return id, date, err
+	}
+	return id, date, tx.Commit()
+}
+
+/*
+EditComment replaces the text of a user's comment, along with its mentions. Users mentioned anew are notified, while
+those no longer mentioned have their notifications withdrawn; the ones mentioned before aren't notified twice.
+Returns ErrNotFound when the user didn't author the comment.
+*/
+func (ar *Store) EditComment(
+	userId, artworkId, commentId string, data AddCommentData, mentions []Mention,
+) (ntime.NTime, error) {
+	var date = ntime.Now()
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return date, err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.Exec(`
+		UPDATE artwork_comments SET comment = ?, edited = ? WHERE id = ? AND artwork = ? AND user = ?`,
+		data.Comment, date, commentId, artworkId, userId,
+	)
+	if err = checkAffected(result, err); err != nil {
+		return date, err
+	}
+	if _, err = tx.Exec(`DELETE FROM comment_mentions WHERE comment = ?`, commentId); err != nil {
+		return date, err
+	}
+	if err = addMentions(tx, artworkId, &commentId, userId, mentions, date); err != nil {
+		return date, err
+	}
+	if _, err = tx.Exec(`
+		DELETE FROM notifications WHERE comment = @comment AND kind = 'mention'
+		AND user NOT IN (SELECT user FROM comment_mentions WHERE comment = @comment)`,
+		sql.Named("comment", commentId),
+	); err != nil {
+		return date, err
+	}
+	return date, tx.Commit()
+}
+
+/*
+addMentions resolves mentions by their aliases, records the ones matching existing users, and notifies the first
+maxNotifiedMentions users, provided they can see the artwork and neither they nor the author banned the other.
+Mentions belong to the comment, when given, or to the artwork's description otherwise.
+Mentions of missing users are ignored, as they'd be plain text.
+*/
+func addMentions(
+	tx *sql.Tx, artworkId string, commentId *string, authorId string, mentions []Mention, date ntime.NTime,
+) error {
+	var notified = make(map[string]bool)
+	for _, mention := range mentions {
+		var userId string
+		var err error
+		if commentId != nil {
+			err = tx.QueryRow(`
+				INSERT INTO comment_mentions (comment, user, start, length)
+				SELECT ?, id, ?, ? FROM users WHERE alias = ? AND deleted IS NULL
+				RETURNING user`,
+				*commentId, mention.Offset, mention.Length, mention.Alias,
+			).Scan(&userId)
+		} else {
+			err = tx.QueryRow(`
+				INSERT INTO description_mentions (artwork, user, start, length)
+				SELECT ?, id, ?, ? FROM users WHERE alias = ? AND deleted IS NULL
+				RETURNING user`,
+				artworkId, mention.Offset, mention.Length, mention.Alias,
+			).Scan(&userId)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if notified[userId] || userId == authorId || len(notified) >= maxNotifiedMentions {
+			continue
+		}
+		notified[userId] = true
+
+		// the mentioned user is the requester for whom the artwork's visibility is checked
+		if _, err = tx.Exec(`
+			INSERT INTO notifications (id, user, actor, kind, artwork, comment, date)
+			SELECT @id, @requester, @author, 'mention', @artwork, @comment, @date
+			WHERE NOT EXISTS (SELECT TRUE FROM bans
+				WHERE (source = @author AND target = @requester) OR (source = @requester AND target = @author))
+			AND EXISTS (SELECT TRUE FROM artworks WHERE id = @artwork AND NOT deleted AND `+visibleArtwork+`)
+			ON CONFLICT DO NOTHING`,
+			sql.Named("id", rest.MustGetNewUUID()),
+			sql.Named("requester", userId),
+			sql.Named("author", authorId),
+			sql.Named("artwork", artworkId),
+			sql.Named("comment", commentId),
+			sql.Named("date", date),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+setDescriptionMentions replaces the mentions of an artwork's description. Users mentioned anew are notified, while
+those no longer mentioned have their notifications withdrawn, as with comments' edits.
+*/
+func setDescriptionMentions(tx *sql.Tx, artworkId, authorId string, mentions []Mention, date ntime.NTime) error {
+	if _, err := tx.Exec(`DELETE FROM description_mentions WHERE artwork = ?`, artworkId); err != nil {
+		return err
+	}
+	if err := addMentions(tx, artworkId, nil, authorId, mentions, date); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		DELETE FROM notifications WHERE artwork = @artwork AND comment IS NULL AND kind = 'mention'
+		AND user NOT IN (SELECT user FROM description_mentions WHERE artwork = @artwork)`,
+		sql.Named("artwork", artworkId),
+	)
+	return err
+}
+
+// getDescriptionMentions fetches the mentions of an artwork's description, sorted by offset, along with their users'
+// current aliases. Mentions of deleted accounts are left out.
+func (ar *Store) getDescriptionMentions(artworkId string) ([]Mention, error) {
+	rows, err := ar.Connection.Query(`
+		SELECT users.id, users.alias, description_mentions.start, description_mentions.length
+		FROM description_mentions JOIN users ON description_mentions.user = users.id
+		WHERE description_mentions.artwork = ? AND users.deleted IS NULL
+		ORDER BY description_mentions.start`,
+		artworkId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var mentions = make([]Mention, 0)
+	for rows.Next() {
+		var mention Mention
+		if err = rows.Scan(&mention.UserId, &mention.Alias, &mention.Offset, &mention.Length); err != nil {
+			return mentions, err
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions, rows.Err()
+}
+
+// getArtworkMentions fetches the mentions of an artwork's comments, indexed by comment and sorted by offset, along
+// with their users' current aliases. Mentions of deleted accounts are left out.
+func (ar *Store) getArtworkMentions(artworkId string) (map[string][]Mention, error) {
+	rows, err := ar.Connection.Query(`
+		SELECT comment_mentions.comment, users.id, users.alias, comment_mentions.start, comment_mentions.length
+		FROM comment_mentions
+		JOIN artwork_comments ON comment_mentions.comment = artwork_comments.id
+		JOIN users ON comment_mentions.user = users.id
+		WHERE artwork_comments.artwork = ? AND users.deleted IS NULL
+		ORDER BY comment_mentions.comment, comment_mentions.start`,
+		artworkId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var mentions = make(map[string][]Mention)
+	for rows.Next() {
+		var commentId string
+		var mention Mention
+		if err = rows.Scan(&commentId, &mention.UserId, &mention.Alias, &mention.Offset, &mention.Length); err != nil {
+			return mentions, err
+		}
+		mentions[commentId] = append(mentions[commentId], mention)
+	}
+	return mentions, rows.Err()
+}
+
+/*
+GetNotifications lists a user's most recent notifications. Those whose actors were banned, or banned the user, since
+are left out, as are the ones of muted actors and those concerning artworks the user can no longer see.
+*/
+func (ar *Store) GetNotifications(userId string) ([]Notification, error) {
+	var notifications = make([]Notification, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT notifications.id, notifications.kind, users.alias, users.name, notifications.artwork,
+		       notifications.comment, notifications.date
+		FROM notifications JOIN users ON notifications.actor = users.id
+		WHERE notifications.user = @requester AND users.deleted IS NULL
+		AND actor NOT IN `+bannedUsers+` AND actor NOT IN `+banningUsers+`
+		AND actor NOT IN `+mutedUsers+`
+		AND EXISTS (SELECT TRUE FROM artworks
+			WHERE artworks.id = notifications.artwork AND NOT artworks.deleted AND `+visibleArtwork+`)
+		ORDER BY notifications.date DESC LIMIT @limit`,
+		sql.Named("requester", userId),
+		sql.Named("now", ntime.Now()),
+		sql.Named("limit", maxNotifications),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var notification Notification
+		if err = rows.Scan(
+			&notification.Id,
+			&notification.Kind,
+			&notification.ActorAlias,
+			&notification.ActorName,
+			&notification.Artwork,
+			&notification.Comment,
+			&notification.Date,
+		); err != nil {
+			return notifications, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// DeleteNotification dismisses one of the user's notifications; returns ErrNotFound when missing.
+func (ar *Store) DeleteNotification(userId, notificationId string) error {
+	result, err := ar.Connection.Exec(`DELETE FROM notifications WHERE id = ? AND user = ?`, notificationId, userId)
+	return checkAffected(result, err)
+}
@@ -0,0 +1,148 @@
+package artworks
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// loadReactionKinds reads the available reaction types, in their display order, and registers them for validation.
+func loadReactionKinds(connection *sql.DB) error {
+	kinds, err := queryReactionKinds(connection)
+	if err != nil {
+		return err
+	}
+	reactionKinds.set(kinds)
+	return nil
+}
+
+func queryReactionKinds(connection *sql.DB) ([]ReactionKind, error) {
+	var kinds = make([]ReactionKind, 0)
+	rows, err := connection.Query(`SELECT name, icon FROM reaction_types ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var kind ReactionKind
+		if err = rows.Scan(&kind.Name, &kind.Icon); err != nil {
+			return kinds, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, rows.Err()
+}
+
+// GetReactionKinds returns the reaction types users can choose from, refreshing the ones used for validation.
+func (ar *Store) GetReactionKinds() ([]ReactionKind, error) {
+	kinds, err := queryReactionKinds(ar.Connection)
+	if err != nil {
+		return nil, err
+	}
+	reactionKinds.set(kinds)
+	return kinds, nil
+}
+
+// GetReactionsSummaries breaks down the reactions of the given artworks by type, along with the requester's own.
+// Artworks lacking reactions are mapped to empty summaries.
+func (ar *Store) GetReactionsSummaries(artworkIds []string, requesterId string) (map[string]ReactionsSummary, error) {
+	var summaries = make(map[string]ReactionsSummary, len(artworkIds))
+	if len(artworkIds) == 0 {
+		return summaries, nil
+	}
+
+	var args = make([]interface{}, 0, len(artworkIds)+1)
+	args = append(args, requesterId)
+	for _, id := range artworkIds {
+		summaries[id] = newReactionsSummary()
+		args = append(args, id)
+	}
+
+	rows, err := ar.Connection.Query(`
+		SELECT artwork, reaction, count(*), max(user = ?)
+		FROM artwork_feedback
+		WHERE artwork IN (?`+strings.Repeat(", ?", len(artworkIds)-1)+`)
+		GROUP BY artwork, reaction`,
+		args...,
+	)
+	if err != nil {
+		return summaries, err
+	}
+	defer closeRows(rows)
+
+	var (
+		artworkId string
+		reaction  ReactionType
+		count     int
+		own       bool
+	)
+	for rows.Next() {
+		if err = rows.Scan(&artworkId, &reaction, &count, &own); err != nil {
+			return summaries, err
+		}
+		var summary = summaries[artworkId]
+		summary.ReactionsByType[reaction] = count
+		if own {
+			var userReaction = reaction
+			summary.UserReaction = &userReaction
+		}
+		summaries[artworkId] = summary
+	}
+	return summaries, rows.Err()
+}
+
+// summarisePreviews completes artwork previews with their reactions breakdowns, in place.
+func (ar *Store) summarisePreviews(previews []ArtworkStreamPreview, requesterId string) error {
+	var ids = make([]string, len(previews))
+	for index, preview := range previews {
+		ids[index] = preview.Id
+	}
+	summaries, err := ar.GetReactionsSummaries(ids, requesterId)
+	if err != nil {
+		return err
+	}
+	for index := range previews {
+		previews[index].ReactionsSummary = summaries[previews[index].Id]
+	}
+	return nil
+}
+
+// GetUserReactions lists the artworks a user reacted to, in reverse chronological order.
+// Nothing is returned when the user banned the requester, while only the artworks the requester may see listed are
+// included.
+func (ar *Store) GetUserReactions(userAlias, requesterId string) ([]UserReactionData, error) {
+	var reactions = make([]UserReactionData, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT artworks.id, title, format, authors.alias, authors.name, reaction, date
+		FROM artwork_feedback
+		JOIN artworks ON artwork_feedback.artwork = artworks.id
+		JOIN users as authors ON artworks.author_id = authors.id
+		WHERE artwork_feedback.user = (SELECT id FROM users WHERE alias = @alias)
+		AND NOT artworks.deleted
+		AND artwork_feedback.user NOT IN `+banningUsers+`
+		AND `+listedArtwork+`
+		ORDER BY date DESC`,
+		sql.Named("alias", userAlias), sql.Named("requester", requesterId),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var reaction UserReactionData
+		if err = rows.Scan(
+			&reaction.ArtworkId,
+			&reaction.Title,
+			&reaction.Format,
+			&reaction.Author.Alias,
+			&reaction.Author.Name,
+			&reaction.Reaction,
+			&reaction.Date,
+		); err != nil {
+			return reactions, err
+		}
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
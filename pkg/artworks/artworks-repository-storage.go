@@ -0,0 +1,20 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// GetStorageUsage reports the bytes and number of artworks counted against the user's quota. Deleted artworks aren't
+// counted, even before their images are removed.
+func (ar *Store) GetStorageUsage(userId string, quota Quota) (StorageUsage, error) {
+	var usage = StorageUsage{MaxBytes: quota.Bytes, MaxArtworks: quota.Artworks}
+	err := ar.Connection.QueryRow(`SELECT bytes, artworks FROM storage_usage WHERE user = ?`, userId).Scan(
+		&usage.Bytes,
+		&usage.Artworks,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usage, nil
+	}
+	return usage, err
+}
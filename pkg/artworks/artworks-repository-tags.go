@@ -0,0 +1,277 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+)
+
+var ErrTagsLimit = errors.New("artwork tags limit reached")
+
+// ownsArtwork reports whether an author's artwork exists and wasn't deleted, within a transaction.
+func ownsArtwork(tx *sql.Tx, artworkId, userId string) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM artworks WHERE id = ? AND author_id = ? AND NOT deleted)`,
+		artworkId, userId,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// tagSource names the artwork_tags column flagging the tags attached by either source.
+type tagSource string
+
+const (
+	authorTag tagSource = "by_author"
+	hashtag   tagSource = "in_description"
+)
+
+// attachTag attaches a normalised tag to an artwork on behalf of a source, creating the tag when new, and reports
+// whether the artwork lacked it. Sources are tracked separately, so that tags attached by both are kept until both
+// detach them.
+func attachTag(tx *sql.Tx, artworkId, tag string, source tagSource, date ntime.NTime) (bool, error) {
+	var tagId int64
+	if err := tx.QueryRow(`
+		INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO UPDATE SET name = excluded.name RETURNING id`,
+		tag,
+	).Scan(&tagId); err != nil {
+		return false, err
+	}
+
+	var attached bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM artwork_tags WHERE artwork = ? AND tag = ?)`,
+		artworkId, tagId,
+	).Scan(&attached); err != nil {
+		return false, err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO artwork_tags(artwork, tag, `+string(source)+`, added) VALUES(?, ?, TRUE, ?)
+		ON CONFLICT(artwork, tag) DO UPDATE SET `+string(source)+` = TRUE`,
+		artworkId, tagId, date,
+	)
+	return !attached, err
+}
+
+// detachTags detaches all the tags of an artwork attached by a source, dropping those no other source attached.
+func detachTags(tx *sql.Tx, artworkId string, source tagSource) error {
+	if _, err := tx.Exec(`
+		DELETE FROM artwork_tags WHERE artwork = ? AND `+string(source)+` AND NOT (by_author AND in_description)`,
+		artworkId,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE artwork_tags SET `+string(source)+` = FALSE WHERE artwork = ?`, artworkId)
+	return err
+}
+
+// countTags counts an artwork's tags, regardless of their source.
+func countTags(tx *sql.Tx, artworkId string) (count int, err error) {
+	err = tx.QueryRow(`SELECT count(*) FROM artwork_tags WHERE artwork = ?`, artworkId).Scan(&count)
+	return count, err
+}
+
+/*
+SetArtworkTags replaces the tags set by an artwork's author with the provided normalised ones, while hashtags are kept.
+Returns ErrNotFound when the user doesn't own the artwork, and ErrTagsLimit when the artwork's tags, hashtags included,
+would exceed maxArtworkTags.
+*/
+func (ar *Store) SetArtworkTags(artworkId, userId string, tags []string) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = ownsArtwork(tx, artworkId, userId); err != nil {
+		return err
+	}
+	if err = detachTags(tx, artworkId, authorTag); err != nil {
+		return err
+	}
+
+	var now = ntime.Now()
+	for _, tag := range tags {
+		if _, err = attachTag(tx, artworkId, tag, authorTag, now); err != nil {
+			return err
+		}
+	}
+
+	if count, e := countTags(tx, artworkId); e != nil {
+		return e
+	} else if count > maxArtworkTags {
+		return ErrTagsLimit
+	}
+	if _, err = tx.Exec(`UPDATE artworks SET updated = ? WHERE id = ?`, now, artworkId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+SetArtworkDescription replaces an author's artwork description, along with the tags sourced from its hashtags and
+its mentions, whose users are notified. Hashtags exceeding maxArtworkTags, once the author's own tags are accounted
+for, are silently ignored, since descriptions are free text. Returns ErrNotFound when the user doesn't own the artwork.
+*/
+func (ar *Store) SetArtworkDescription(
+	artworkId, userId string, description *string, hashtags []string, mentions []Mention,
+) error {
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var now = ntime.Now()
+	result, err := tx.Exec(`
+		UPDATE artworks SET description = ?, updated = ? WHERE id = ? AND author_id = ? AND NOT deleted`,
+		description, now, artworkId, userId,
+	)
+	if err = checkAffected(result, err); err != nil {
+		return err
+	}
+	if err = detachTags(tx, artworkId, hashtag); err != nil {
+		return err
+	}
+
+	count, err := countTags(tx, artworkId)
+	if err != nil {
+		return err
+	}
+	for _, tag := range hashtags {
+		if count >= maxArtworkTags {
+			break
+		}
+		if attached, e := attachTag(tx, artworkId, tag, hashtag, now); e != nil {
+			return e
+		} else if attached {
+			count++
+		}
+	}
+	if err = setDescriptionMentions(tx, artworkId, userId, mentions, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getArtworkTags lists an artwork's tags in the order they were attached.
+func (ar *Store) getArtworkTags(artworkId string) ([]string, error) {
+	rows, err := ar.Connection.Query(`
+		SELECT tags.name FROM artwork_tags JOIN tags ON artwork_tags.tag = tags.id
+		WHERE artwork_tags.artwork = ?
+		ORDER BY artwork_tags.added, tags.name`,
+		artworkId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var tags = make([]string, 0)
+	var tag string
+	for rows.Next() {
+		if err = rows.Scan(&tag); err != nil {
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+/*
+GetTaggedArtworks fetches a page of the artworks sharing a tag, most recently added first. As with discoveries, only
+the artworks the requester may see listed are included, while authors banned or muted by the requester are left out.
+*/
+func (ar *Store) GetTaggedArtworks(tag, requesterId string, page int) ([]ArtworkStreamPreview, error) {
+	var artworks = make([]ArtworkStreamPreview, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT artworks.id, artworks.title, users.alias, users.name, artworks.cover, artworks.format, artworks.added,
+		       (SELECT count(*) FROM artwork_images WHERE artwork = artworks.id) as images,
+		       (SELECT count(*) FROM artwork_comments WHERE artwork = artworks.id) as comments,
+		       (SELECT count(*) FROM artwork_feedback WHERE artwork = artworks.id) as reactions
+		FROM artwork_tags
+		JOIN artworks ON artwork_tags.artwork = artworks.id
+		JOIN users ON artworks.author_id = users.id
+		WHERE artwork_tags.tag = (SELECT id FROM tags WHERE name = @tag) AND NOT artworks.deleted
+		AND author_id NOT IN `+mutedUsers+`
+		AND `+listedArtwork+`
+		ORDER BY artworks.added DESC LIMIT @limit OFFSET @offset`,
+		sql.Named("tag", tag),
+		sql.Named("requester", requesterId),
+		sql.Named("now", ntime.Now()),
+		sql.Named("limit", discoveryPageSize),
+		sql.Named("offset", page*discoveryPageSize),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var artwork ArtworkStreamPreview
+		if err = rows.Scan(
+			&artwork.Id,
+			&artwork.Title,
+			&artwork.Author.Alias,
+			&artwork.Author.Name,
+			&artwork.Cover,
+			&artwork.Format,
+			&artwork.Added,
+			&artwork.Images,
+			&artwork.Comments,
+			&artwork.Reactions,
+		); err != nil {
+			return artworks, err
+		}
+		artworks = append(artworks, artwork)
+	}
+	if err = rows.Err(); err != nil {
+		return artworks, err
+	}
+	return artworks, ar.summarisePreviews(artworks, requesterId)
+}
+
+// GetTrendingTags ranks the tags attached to the most artworks since the given date, among the ones the requester may
+// see listed; ties favour the most recently used tags.
+func (ar *Store) GetTrendingTags(requesterId string, since ntime.NTime, limit int) ([]TrendingTag, error) {
+	var trending = make([]TrendingTag, 0)
+	rows, err := ar.Connection.Query(`
+		SELECT tags.name, count(*) as uses
+		FROM artwork_tags
+		JOIN tags ON artwork_tags.tag = tags.id
+		JOIN artworks ON artwork_tags.artwork = artworks.id
+		WHERE artwork_tags.added > @since AND NOT artworks.deleted
+		AND author_id NOT IN `+mutedUsers+`
+		AND `+listedArtwork+`
+		GROUP BY tags.id
+		ORDER BY uses DESC, max(artwork_tags.added) DESC, tags.name LIMIT @limit`,
+		sql.Named("since", since),
+		sql.Named("requester", requesterId),
+		sql.Named("now", ntime.Now()),
+		sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var tag TrendingTag
+		if err = rows.Scan(&tag.Tag, &tag.Artworks); err != nil {
+			return trending, err
+		}
+		trending = append(trending, tag)
+	}
+	return trending, rows.Err()
+}
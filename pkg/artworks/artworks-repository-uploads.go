@@ -0,0 +1,163 @@
+package artworks
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// GetUploadPath returns the path of a resumable upload's staging file, where chunks are appended.
+func (ar *Store) GetUploadPath(uploadId string) string {
+	return filepath.Join(ar.ImageStore.StagingPath, uploadId)
+}
+
+// CreateUpload opens a resumable upload session, along with its empty staging file.
+func (ar *Store) CreateUpload(userId string, data CreateUploadData, expires ntime.NTime) (UploadSession, error) {
+	var now = ntime.Now()
+	var session = UploadSession{
+		Id:         rest.MustGetNewUUID(),
+		Size:       data.Size,
+		Visibility: data.Visibility,
+		Created:    now,
+		Updated:    now,
+		Expires:    expires,
+	}
+	if session.Visibility == "" {
+		session.Visibility = Public
+	}
+
+	if _, err := ar.Connection.Exec(`
+		INSERT INTO upload_sessions (id, user, size, visibility, created, updated, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		session.Id, userId, session.Size, session.Visibility, now, now, expires,
+	); err != nil {
+		return session, err
+	}
+
+	file, err := os.OpenFile(ar.GetUploadPath(session.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		_, _ = ar.Connection.Exec(`DELETE FROM upload_sessions WHERE id = ?`, session.Id)
+	}
+	return session, err
+}
+
+// GetUpload fetches a user's resumable upload session, or returns ErrNotFound.
+func (ar *Store) GetUpload(uploadId, userId string) (UploadSession, error) {
+	var session = UploadSession{Id: uploadId}
+	err := ar.Connection.QueryRow(`
+		SELECT size, offset, visibility, created, updated, expires
+		FROM upload_sessions WHERE id = ? AND user = ?`,
+		uploadId, userId,
+	).Scan(&session.Size, &session.Offset, &session.Visibility, &session.Created, &session.Updated, &session.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, ErrNotFound
+	}
+	return session, err
+}
+
+/*
+AdvanceUpload records the bytes appended to an upload's staging file, moving its offset from one value to another and
+postponing its expiry. ErrNotFound is returned when the session is missing, or its offset differs from the expected one.
+*/
+func (ar *Store) AdvanceUpload(uploadId, userId string, from, to int64, expires ntime.NTime) error {
+	result, err := ar.Connection.Exec(`
+		UPDATE upload_sessions SET offset = ?, updated = ?, expires = ?
+		WHERE id = ? AND user = ? AND offset = ?`,
+		to, ntime.Now(), expires, uploadId, userId, from,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUpload removes a user's resumable upload session and its staging file, or returns ErrNotFound.
+func (ar *Store) DeleteUpload(uploadId, userId string) error {
+	result, err := ar.Connection.Exec(`DELETE FROM upload_sessions WHERE id = ? AND user = ?`, uploadId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	if err = os.Remove(ar.GetUploadPath(uploadId)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+/*
+PurgeExpiredUploads removes the upload sessions which expired before the given date, along with their staging files,
+returning their number. Sessions of deleted and suspended accounts are purged as well, since they can't be completed.
+
+Staging files lacking sessions, left behind by crashes, are removed too.
+*/
+func (ar *Store) PurgeExpiredUploads(now ntime.NTime) (int64, error) {
+	rows, err := ar.Connection.Query(`
+		DELETE FROM upload_sessions WHERE expires < ?
+		OR user IN (SELECT id FROM users WHERE deleted IS NOT NULL OR suspended IS NOT NULL)
+		RETURNING id`,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	var id string
+	for rows.Next() {
+		if err = rows.Scan(&id); err != nil {
+			closeRows(rows)
+			return purged, err
+		}
+		purged++
+		if e := os.Remove(ar.GetUploadPath(id)); e != nil && !errors.Is(e, fs.ErrNotExist) {
+			err = e
+		}
+	}
+	closeRows(rows)
+	if e := rows.Err(); e != nil {
+		return purged, e
+	}
+	if e := ar.removeOrphanedUploads(); e != nil {
+		return purged, e
+	}
+	return purged, err
+}
+
+// removeOrphanedUploads removes the staging files whose sessions don't exist.
+func (ar *Store) removeOrphanedUploads() error {
+	entries, err := os.ReadDir(ar.ImageStore.StagingPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var exists bool
+		if err = ar.Connection.QueryRow(
+			`SELECT EXISTS(SELECT 1 FROM upload_sessions WHERE id = ?)`, entry.Name(),
+		).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			if err = os.Remove(ar.GetUploadPath(entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package artworks
+
+import "github.com/silktrader/kvasari/pkg/ntime"
+
+/*
+visibleArtwork is the SQL predicate granting the `@requester` named parameter access to a row of the `artworks` table:
+
+  - authors always access their own artworks
+  - authors banning the requester hide all of their artworks
+  - private artworks are reserved to their authors, followers-only ones to approved followers
+  - public and unlisted artworks of private accounts are reserved to approved followers as well
+
+Soft deletions aren't considered, since listings report deleted artworks to clients.
+*/
+const visibleArtwork = `(artworks.author_id = @requester OR (
+	artworks.author_id NOT IN ` + banningUsers + `
+	AND CASE artworks.visibility
+		WHEN 'private' THEN FALSE
+		WHEN 'followers' THEN artworks.author_id IN (SELECT target FROM followers WHERE follower = @requester)
+		ELSE NOT (SELECT private FROM users WHERE id = artworks.author_id)
+			OR artworks.author_id IN (SELECT target FROM followers WHERE follower = @requester)
+	END))`
+
+// listedArtwork restricts visibleArtwork to the artworks that can appear in listings, such as streams or profiles;
+// unlisted ones are only reachable by ID, except by their authors, as are the artworks of authors banned by the
+// requester, whose bans hide them both ways.
+const listedArtwork = `(` + visibleArtwork + ` AND artworks.author_id NOT IN ` + bannedUsers + `
+	AND (artworks.author_id = @requester OR artworks.visibility != 'unlisted'))`
+
+// bannedUsers selects the users banned by `@requester`, while banningUsers selects those who banned the requester.
+const (
+	bannedUsers  = `(SELECT target FROM bans WHERE source = @requester)`
+	banningUsers = `(SELECT source FROM bans WHERE target = @requester)`
+)
+
+// mutedUsers selects the users muted by `@requester` whose mutes are still in effect at `@now`. Muted users' artworks
+// and comments are hidden from the requester alone.
+const mutedUsers = `(SELECT target FROM mutes WHERE source = @requester AND (expires IS NULL OR expires > @now))`
+
+// SetArtworkVisibility changes who can access an artwork; returns ErrNotFound when the user doesn't own it.
+func (ar *Store) SetArtworkVisibility(artworkId, userId string, visibility Visibility) error {
+	result, err := ar.Connection.Exec(`
+		UPDATE artworks SET visibility = ?, updated = ? WHERE id = ? AND author_id = ? AND NOT deleted`,
+		visibility, ntime.Now(), artworkId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, e := result.RowsAffected(); e != nil {
+		return e
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -3,36 +3,73 @@ package artworks
 import (
 	"database/sql"
 	"errors"
-	"fmt"
-	"github.com/mattn/go-sqlite3"
 	"github.com/silktrader/kvasari/pkg/ntime"
-	"github.com/silktrader/kvasari/pkg/rest"
 	"github.com/silktrader/kvasari/pkg/storage/images"
 	"github.com/silktrader/kvasari/pkg/users"
-	"os"
 )
 
 type Storer interface {
-	AddArtwork(data AddArtworkData) (ntime.NTime, error)
+	AddArtwork(data AddArtworkData, quota Quota) (ntime.NTime, error)
+	GetStorageUsage(userId string, quota Quota) (StorageUsage, error)
+	CreateUpload(userId string, data CreateUploadData, expires ntime.NTime) (UploadSession, error)
+	GetUpload(uploadId, userId string) (UploadSession, error)
+	AdvanceUpload(uploadId, userId string, from, to int64, expires ntime.NTime) error
+	DeleteUpload(uploadId, userId string) error
+	PurgeExpiredUploads(now ntime.NTime) (int64, error)
 	DeleteArtwork(artworkId, userId string) error
+	OwnsArtwork(artworkId, userId string) (bool, error)
 	CleanArtwork(artworkId, userId string) error
-	CleanDeletedArtwork(artworkId, userId string) (bool, error)
+	CleanDeletedArtwork(imageId, userId string) error
+	GetArtworkImages(artworkId, requesterId string) ([]ArtworkImage, error)
+	AddArtworkImage(artworkId, userId string, data AddArtworkImageData, quota Quota) (ArtworkImage, error)
+	RemoveArtworkImage(artworkId, userId, imageId string) error
+	SortArtworkImages(artworkId, userId string, imageIds []string) error
 	GetArtworkData(artworkId, requesterId string) (*Artwork, error)
-	GetImageMetadata(artworkId, requesterId string) (ImageMetadata, error)
 	SetArtworkTitle(artworkId, requesterId, title string) error
+	SetArtworkVisibility(artworkId, userId string, visibility Visibility) error
+	SetArtworkDescription(artworkId, userId string, description *string, hashtags []string, mentions []Mention) error
 
-	AddComment(userId, artworkId string, data AddCommentData) (string, ntime.NTime, error)
+	SetArtworkTags(artworkId, userId string, tags []string) error
+	GetTaggedArtworks(tag, requesterId string, page int) ([]ArtworkStreamPreview, error)
+	GetTrendingTags(requesterId string, since ntime.NTime, limit int) ([]TrendingTag, error)
+
+	AddComment(userId, artworkId string, data AddCommentData, mentions []Mention) (string, ntime.NTime, error)
+	EditComment(userId, artworkId, commentId string, data AddCommentData, mentions []Mention) (ntime.NTime, error)
 	DeleteComment(userId, commentId string) error
 	GetArtworkComments(artworkId, requesterId string) ([]CommentResponse, error)
+	GetNotifications(userId string) ([]Notification, error)
+	DeleteNotification(userId, notificationId string) error
 
 	SetReaction(userId, artworkId string, date ntime.NTime, feedback AddReactionRequest) error
 	RemoveReaction(userId, artworkId string) error
 	GetArtworkReactions(artworkId, requesterId string) ([]ReactionResponse, error)
+	GetReactionKinds() ([]ReactionKind, error)
+	GetReactionsSummaries(artworkIds []string, requesterId string) (map[string]ReactionsSummary, error)
+	GetUserReactions(userAlias, requesterId string) ([]UserReactionData, error)
+
+	GetCollections(ownerAlias, requesterId string) ([]Collection, error)
+	GetCollection(collectionId, ownerAlias, requesterId string) (CollectionDetails, error)
+	CreateCollection(userId string, data CollectionData) (Collection, error)
+	UpdateCollection(collectionId, userId string, data CollectionData) error
+	DeleteCollection(collectionId, userId string) error
+	AddCollectionEntry(collectionId, userId, artworkId string) error
+	RemoveCollectionEntry(collectionId, userId, artworkId string) error
+	SortCollection(collectionId, userId string, artworkIds []string) error
 
 	GetUserArtworks(userAlias, requesterId string, pageData PageData) (UserArtworks, error)
+	CountUserArtworks(userAlias, requesterId string) (int, error)
 	GetStream(userId, since, latest string) (data StreamData, err error)
+	GetDiscoveryCandidates(userId string, since ntime.NTime) ([]DiscoveryCandidate, error)
+
+	PurgeDeletedAccounts(deletedBefore ntime.NTime) (int64, error)
+	GetAuthoredArtworks(userId string) ([]AuthoredArtwork, error)
+	GetAuthoredComments(userId string) ([]AuthoredComment, error)
+	GetAuthoredReactions(userId string) ([]AuthoredReaction, error)
 
 	GetImagesPath() string
+	GetUploadPath(uploadId string) string
+	GetFreeBytes() (uint64, error)
+	GetUserStore() users.UserRepository
 }
 
 type Store struct {
@@ -45,43 +82,57 @@ var (
 	ErrNotFound    = errors.New("not found")
 	ErrNotModified = errors.New("not modified")
 	ErrDupArtwork  = errors.New("duplicate artwork")
+
+	ErrImagesLimit = errors.New("artwork images limit reached")
+	ErrLastImage   = errors.New("artworks need at least an image")
+	ErrFirstImage  = errors.New("artworks are identified by their first image, which can't be removed")
+	ErrImagesOrder = errors.New("images must be listed once each, all of them")
+
+	ErrBytesQuota    = errors.New("storage quota exceeded")
+	ErrArtworksQuota = errors.New("artworks quota exceeded")
 )
 
 // NewStore returns an artwork repository, or store, which wraps the necessary dependencies
 // and provides relevant interface implementations.
-// Soft-deleted artworks are cleaned up on initialisation.
+// Soft-deleted artworks are cleaned up on initialisation, while reaction types are loaded.
 func NewStore(connection *sql.DB, userStore users.UserRepository, imageStore images.Storage) *Store {
 	if err := cleanRemovedArtworks(connection, imageStore.Path); err != nil {
 		panic(err)
 	}
+	if err := loadReactionKinds(connection); err != nil {
+		panic(err)
+	}
 	return &Store{connection, userStore, imageStore}
 }
 
 // cleanRemovedArtworks ensures that previously soft-deleted artworks are cleaned, on initialisation, for all users.
 // The event is scheduled to occur at every server restart, and regularly through `cron` jobs or alternatives.
 // Errors are safe to be ignored, but it remains debatable to include side effects in a constructor.
+// Artworks of deleted accounts are spared until the accounts themselves are purged, after their grace period.
+// Staged images left behind by interrupted uploads are removed too, as no upload can be in progress yet.
 func cleanRemovedArtworks(connection *sql.DB, imagesPath string) error {
-	rows, err := connection.Query(`
-		DELETE FROM artworks WHERE deleted = TRUE
-		RETURNING id, format
-	`)
+	tx, err := connection.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer closeRows(rows)
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	// delete files thanks to the returned ids and formats
-	var id, format string
-	for rows.Next() {
-		if err = rows.Scan(&id, &format); err != nil {
-			return err
-		}
-		if err = os.Remove(fmt.Sprintf("%s/%s.%s", imagesPath, id, format)); err != nil {
-			return err
-		}
+	files, _, err := deleteArtworks(tx, imagesPath,
+		`deleted = TRUE AND author_id NOT IN (SELECT id FROM users WHERE deleted IS NOT NULL)`)
+	if err != nil {
+		return err
 	}
-	return rows.Err()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if err = removeFiles(files); err != nil {
+		return err
+	}
+	return removeStagedImages(imagesPath)
 }
 
 func closeRows(rows *sql.Rows) {
@@ -93,21 +144,60 @@ func (ar *Store) GetImagesPath() string {
 	return ar.ImageStore.Path
 }
 
-// AddArtwork will create a new artwork metadata, provided the given hash is unique in the whole DB.
-// Please, note that a concomitant image upload is expected.
-func (ar *Store) AddArtwork(data AddArtworkData) (ntime.NTime, error) {
+// GetFreeBytes returns the bytes available on the images' volume.
+func (ar *Store) GetFreeBytes() (uint64, error) {
+	return ar.ImageStore.FreeBytes()
+}
+
+// GetUserStore provides access to the users' repository, for handlers composing users and artworks data.
+func (ar *Store) GetUserStore() users.UserRepository {
+	return ar.UserStore
+}
+
+// AddArtwork will create a new artwork metadata, along with its cover image, provided the given hash is unique among
+// artworks and images in the whole DB and the author's storage quota allows it. Returns ErrBytesQuota or
+// ErrArtworksQuota otherwise.
+func (ar *Store) AddArtwork(data AddArtworkData, quota Quota) (ntime.NTime, error) {
 	var now = ntime.Now()
-	if _, err := ar.Connection.Exec(`
-		INSERT INTO artworks(id, type, format, author_id, added, updated)
-		VALUES(?, ?, ?, ?, ?, ?)`,
-		data.Id, data.Type, data.Format, data.AuthorId, now, now); err != nil {
-		var sqliteErr sqlite3.Error
-		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
-			return now, ErrDupArtwork
-		}
+	tx, err := ar.Connection.Begin()
+	if err != nil {
+		return now, err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// usage is kept up to date by triggers, whenever artworks are added or removed
+	var usage StorageUsage
+	err = tx.QueryRow(`SELECT bytes, artworks FROM storage_usage WHERE user = ?`, data.AuthorId).Scan(
+		&usage.Bytes,
+		&usage.Artworks,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return now, err
 	}
-	return now, nil
+	if quota.Artworks > 0 && usage.Artworks >= quota.Artworks {
+		return now, ErrArtworksQuota
+	}
+	if quota.Bytes > 0 && usage.Bytes+data.Size > quota.Bytes {
+		return now, ErrBytesQuota
+	}
+
+	// the artwork's size is added up by triggers, as its first image is inserted
+	if _, err = tx.Exec(`
+		INSERT INTO artworks(id, type, format, author_id, added, updated, visibility, cover)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		data.Id, data.Type, data.Format, data.AuthorId, now, now, data.Visibility, data.Id); err != nil {
+		return now, mapImageError(err)
+	}
+	if _, err = tx.Exec(`
+		INSERT INTO artwork_images(id, artwork, position, format, size, added) VALUES(?, ?, 0, ?, ?, ?)`,
+		data.Id, data.Id, data.Format, data.Size, now); err != nil {
+		return now, mapImageError(err)
+	}
+	return now, tx.Commit()
 }
 
 func (ar *Store) CleanArtwork(artworkId, userId string) error {
@@ -117,21 +207,32 @@ func (ar *Store) CleanArtwork(artworkId, userId string) error {
 	return err
 }
 
-// CleanDeletedArtwork attempts to clean up a previously soft-deleted artwork,
-// triggering a cascade of comment and reactions deletions.
-func (ar *Store) CleanDeletedArtwork(artworkId, userId string) (bool, error) {
-	result, err := ar.Connection.Exec(`DELETE FROM artworks WHERE id = ? AND author_id = ? AND deleted`,
-		artworkId, userId,
-	)
+// CleanDeletedArtwork attempts to clean up a previously soft-deleted artwork owning the image, along with its image
+// files, triggering a cascade of comment and reactions deletions.
+func (ar *Store) CleanDeletedArtwork(imageId, userId string) error {
+	tx, err := ar.Connection.Begin()
 	if err != nil {
-		return false, err
+		return err
 	}
-	deleted, err := result.RowsAffected()
-	return deleted == 1, err
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	files, _, err := deleteArtworks(tx, ar.GetImagesPath(),
+		`id = (SELECT artwork FROM artwork_images WHERE id = ?) AND author_id = ? AND deleted`, imageId, userId)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return removeFiles(files)
 }
 
 /*
-GetArtworkData fetches artwork metadata, ensuring banned users are denied access.
+GetArtworkData fetches artwork metadata, provided the artwork is visible to the requester.
 
 With traditional relational databases it'd be preferable to update comments and reactions counts by way of triggers.
 SQLite blocks at every write though, so bursts of comments and reactions (writes) aren't ideal.
@@ -143,26 +244,29 @@ func (ar *Store) GetArtworkData(artworkId, requesterId string) (*Artwork, error)
 	if err := ar.Connection.QueryRow(`
 		SELECT
 		    alias, name,
-		    (SELECT EXISTS (SELECT TRUE x FROM followers WHERE follower = users.id AND target = ?) x) as followsUser,
-			(SELECT EXISTS (SELECT TRUE x FROM followers WHERE follower = ? AND target = users.id) x) as followedByUser,
-		    title, type, format, description, year, location,
+		    (SELECT EXISTS (SELECT TRUE x FROM followers WHERE follower = users.id AND target = @requester) x) as followsUser,
+			(SELECT EXISTS (SELECT TRUE x FROM followers WHERE follower = @requester AND target = users.id) x) as followedByUser,
+		    title, type, visibility, cover, format, description, year, location,
+		    (SELECT count(*) x FROM artwork_images WHERE artwork = @artwork) as images,
 		    artworks.created, added, artworks.updated,
-		    (SELECT count(*) x FROM artwork_comments WHERE artwork = ?) as comments,
-		    (SELECT count(*) x FROM artwork_feedback WHERE artwork = ?) as reactions
+		    (SELECT count(*) x FROM artwork_comments WHERE artwork = @artwork) as comments,
+		    (SELECT count(*) x FROM artwork_feedback WHERE artwork = @artwork) as reactions
 		FROM artworks JOIN users ON artworks.author_id = users.id
-		WHERE artworks.id = ? AND NOT deleted
-		AND ? NOT IN (SELECT target FROM bans WHERE source = artworks.author_id)`,
-		requesterId, requesterId, artworkId, artworkId, artworkId, requesterId).Scan(
+		WHERE artworks.id = @artwork AND NOT artworks.deleted AND `+visibleArtwork,
+		sql.Named("requester", requesterId), sql.Named("artwork", artworkId)).Scan(
 		&artwork.Author.Alias,
 		&artwork.Author.Name,
 		&artwork.Author.FollowsUser,
 		&artwork.Author.FollowedByUser,
 		&artwork.Title,
 		&artwork.Type,
+		&artwork.Visibility,
+		&artwork.Cover,
 		&artwork.Format,
 		&artwork.Description,
 		&artwork.Year,
 		&artwork.Location,
+		&artwork.Images,
 		&artwork.Created,
 		&artwork.Added,
 		&artwork.Updated,
@@ -175,18 +279,34 @@ func (ar *Store) GetArtworkData(artworkId, requesterId string) (*Artwork, error)
 		}
 		return nil, err
 	}
+
+	summaries, err := ar.GetReactionsSummaries([]string{artworkId}, requesterId)
+	if err != nil {
+		return nil, err
+	}
+	artwork.ReactionsSummary = summaries[artworkId]
+
+	if artwork.Tags, err = ar.getArtworkTags(artworkId); err != nil {
+		return nil, err
+	}
+	if artwork.DescriptionMentions, err = ar.getDescriptionMentions(artworkId); err != nil {
+		return nil, err
+	}
 	return &artwork, nil
 }
 
+// GetArtworkComments returns the comments of an artwork visible to the requester, most recent first.
+// Comments by users the requester muted are omitted.
 func (ar *Store) GetArtworkComments(artworkId, requesterId string) ([]CommentResponse, error) {
 	var comments = make([]CommentResponse, 0)
 	rows, err := ar.Connection.Query(`
-		SELECT artwork_comments.id, alias, name, comment, date FROM artwork_comments
+		SELECT artwork_comments.id, alias, name, comment, date, edited FROM artwork_comments
 		JOIN users ON artwork_comments.user = users.id
-		WHERE artwork = ?
-		AND ? NOT IN (SELECT target FROM bans WHERE source IN (SELECT author_id FROM artworks WHERE id = artwork_comments.artwork))
-		ORDER BY date DESC
-		`, artworkId, requesterId)
+		WHERE artwork = @artwork AND users.deleted IS NULL
+		AND user NOT IN `+mutedUsers+`
+		AND EXISTS (SELECT TRUE FROM artworks WHERE id = @artwork AND `+visibleArtwork+`)
+		ORDER BY date DESC`,
+		sql.Named("artwork", artworkId), sql.Named("requester", requesterId), sql.Named("now", ntime.Now()))
 
 	if err != nil {
 		return nil, err
@@ -194,18 +314,34 @@ func (ar *Store) GetArtworkComments(artworkId, requesterId string) ([]CommentRes
 	defer closeRows(rows)
 
 	for rows.Next() {
-		var comment CommentResponse
+		var comment = CommentResponse{Mentions: make([]Mention, 0)}
 		if err = rows.Scan(&comment.Id, &comment.AuthorAlias, &comment.AuthorName,
-			&comment.Comment, &comment.Date); err != nil {
+			&comment.Comment, &comment.Date, &comment.Edited); err != nil {
 			return comments, err
 		}
 		comments = append(comments, comment)
 	}
+	if err = rows.Err(); err != nil {
+		return comments, err
+	}
 
 	// always returning a collection, no matter whether the artwork exists or the requester is banned
-	return comments, rows.Err()
+	if len(comments) == 0 {
+		return comments, nil
+	}
+	mentions, err := ar.getArtworkMentions(artworkId)
+	if err != nil {
+		return comments, err
+	}
+	for index := range comments {
+		if found, ok := mentions[comments[index].Id]; ok {
+			comments[index].Mentions = found
+		}
+	}
+	return comments, nil
 }
 
+// GetArtworkReactions returns the reactions to an artwork visible to the requester, most recent first.
 func (ar *Store) GetArtworkReactions(artworkId, requesterId string) ([]ReactionResponse, error) {
 
 	// fetch reactions, beware of package clash with reactions array
@@ -213,10 +349,10 @@ func (ar *Store) GetArtworkReactions(artworkId, requesterId string) ([]ReactionR
 	rows, err := ar.Connection.Query(`
 		SELECT alias, name, reaction, date FROM artwork_feedback
 		JOIN users ON artwork_feedback.user = users.id
-		WHERE artwork = ?
-		AND ? NOT IN (SELECT target FROM bans WHERE source IN (SELECT author_id FROM artworks WHERE artworks.id = ?))
-		ORDER BY date DESC
-		`, artworkId, requesterId, artworkId)
+		WHERE artwork = @artwork AND users.deleted IS NULL
+		AND EXISTS (SELECT TRUE FROM artworks WHERE id = @artwork AND `+visibleArtwork+`)
+		ORDER BY date DESC`,
+		sql.Named("artwork", artworkId), sql.Named("requester", requesterId))
 
 	if err != nil {
 		return nil, err
@@ -239,13 +375,13 @@ func (ar *Store) GetArtworkReactions(artworkId, requesterId string) ([]ReactionR
 }
 
 // OwnsArtwork verifies whether a given artwork exists, wasn't deleted and is owned by the specified user
-func (ar *Store) OwnsArtwork(artworkId, userId string) bool {
+func (ar *Store) OwnsArtwork(artworkId, userId string) (bool, error) {
 	var exists = false
 	var err = ar.Connection.QueryRow(`
-		SELECT TRUE FROM artworks WHERE id = ? AND author_id = ? AND deleted = false`,
+		SELECT EXISTS (SELECT TRUE FROM artworks WHERE id = ? AND author_id = ? AND NOT deleted)`,
 		artworkId, userId,
 	).Scan(&exists)
-	return err != nil && exists
+	return exists, err
 }
 
 // DeleteArtwork will perform a soft delete and return an ErrNotFound in case the artwork doesn't exist,
@@ -284,34 +420,39 @@ func (ar *Store) SetArtworkTitle(artworkId, userId, newTitle string) error {
 	return nil
 }
 
-// GetImageMetadata returns the necessary data to locate and serve binary image files.
-func (ar *Store) GetImageMetadata(artworkId, requesterId string) (metadata ImageMetadata, err error) {
-	return metadata, ar.Connection.QueryRow(`
-		SELECT format
-		FROM   artworks
-		WHERE  id = ?
-		       AND NOT deleted
-		       AND ? NOT IN (SELECT target FROM bans WHERE source = artworks.author_id)`,
-		artworkId, requesterId,
-	).Scan(&metadata.Format)
-}
-
+// SetReaction sets a user's reaction to an artwork, replacing any previous one. Returns ErrNotModified when the
+// reaction is unchanged, and ErrNotFound when the artwork is missing, deleted, or hidden from the user.
 func (ar *Store) SetReaction(userId, artworkId string, date ntime.NTime, data AddReactionRequest) error {
+	var visible = `EXISTS (SELECT TRUE FROM artworks WHERE id = @artwork AND NOT deleted AND ` + visibleArtwork + `)`
+	var args = []any{
+		sql.Named("artwork", artworkId),
+		sql.Named("requester", userId),
+		sql.Named("reaction", data.Reaction),
+		sql.Named("date", date),
+	}
 	res, err := ar.Connection.Exec(`
 		INSERT INTO artwork_feedback(artwork, user, reaction, date)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT (artwork, user) DO UPDATE SET reaction = ?, date = ? WHERE reaction != ?`,
-		artworkId, userId, data.Reaction, date, data.Reaction, date, data.Reaction)
+		SELECT @artwork, @requester, @reaction, @date WHERE `+visible+`
+		ON CONFLICT (artwork, user) DO UPDATE SET reaction = @reaction, date = @date WHERE reaction != @reaction`,
+		args...)
 
 	if err != nil {
 		return err
 	}
 	if changed, e := res.RowsAffected(); e != nil {
 		return e
-	} else if changed == 0 {
-		return ErrNotModified
+	} else if changed != 0 {
+		return nil
 	}
-	return nil
+
+	// no rows change either when the reaction is the same or when the artwork can't be reacted to
+	var found bool
+	if err = ar.Connection.QueryRow(`SELECT `+visible, args...).Scan(&found); err != nil {
+		return err
+	} else if !found {
+		return ErrNotFound
+	}
+	return ErrNotModified
 }
 
 func (ar *Store) RemoveReaction(userId, artworkId string) error {
@@ -332,15 +473,6 @@ func (ar *Store) RemoveReaction(userId, artworkId string) error {
 	return nil
 }
 
-func (ar *Store) AddComment(userId, artworkId string, data AddCommentData) (string, ntime.NTime, error) {
-	var id = rest.MustGetNewUUID()
-	var date = ntime.Now()
-	_, err := ar.Connection.Exec(`
-		INSERT INTO artwork_comments (id, artwork, user, comment, date) VALUES (?, ?, ?, ?, ?)`,
-		id, artworkId, userId, data.Comment, date)
-	return id, date, err
-}
-
 func (ar *Store) DeleteComment(userId, commentId string) error {
 	result, err := ar.Connection.Exec(`DELETE FROM artwork_comments WHERE id = ? AND user = ?`, commentId, userId)
 	if err != nil {
@@ -364,26 +496,32 @@ GetUserArtworks returns paginated artworks uploaded by the target user, in rever
   - artworks added before the 'since' timestamp
   - artworks added after the 'latest' timestamp; those that were uploaded after the latest user request
   - the IDs of artworks deleted before pageData.since but after pageData.latest
+
+The first page only holds the latest artworks, regardless of timestamps. Only the artworks the requester may see listed
+are included.
 */
 func (ar *Store) GetUserArtworks(targetAlias, requesterId string, pageData PageData) (UserArtworks, error) {
 	rows, err := ar.Connection.Query(`
-		SELECT id, title, format, added, new, deleted, coalesce(c, 0) as comments, coalesce(r, 0) as reactions FROM
-			(SELECT id, title, format, added, added > ? as new, deleted FROM artworks
-			WHERE author_id IN (SELECT id FROM users WHERE alias = ?)
-			AND ? NOT IN (SELECT target FROM bans WHERE source = artworks.author_id)
-			AND (deleted = FALSE AND added < ?)
-			OR (deleted = FALSE AND added > ?)
-			OR (deleted = TRUE AND added > ? AND added < ?)) as x
+		SELECT id, title, cover, format, images, added, new, deleted,
+		       coalesce(c, 0) as comments, coalesce(r, 0) as reactions FROM
+			(SELECT id, title, cover, format, added, NOT @first AND added > @latest as new, deleted,
+			        (SELECT count(*) FROM artwork_images WHERE artwork = artworks.id) as images
+			FROM artworks
+			WHERE author_id IN (SELECT id FROM users WHERE alias = @alias)
+			AND `+listedArtwork+`
+			AND ((@first AND deleted = FALSE)
+			     OR (NOT @first AND ((deleted = FALSE AND added < @since)
+			                         OR (deleted = FALSE AND added > @latest)
+			                         OR (deleted = TRUE AND added > @latest AND added < @since))))) as x
 		LEFT JOIN (SELECT artwork as id, count(artwork) as c FROM artwork_comments GROUP BY artwork) USING (id)
 		LEFT JOIN (SELECT artwork as id, count(artwork) as r FROM artwork_feedback GROUP BY artwork) USING (id)
-		ORDER BY added DESC LIMIT ?;`,
-		pageData.latest,
-		targetAlias,
-		requesterId,
-		pageData.since,
-		pageData.latest,
-		pageData.latest, pageData.since,
-		pageData.pageSize,
+		ORDER BY added DESC LIMIT @size;`,
+		sql.Named("latest", pageData.latest),
+		sql.Named("since", pageData.since),
+		sql.Named("alias", targetAlias),
+		sql.Named("requester", requesterId),
+		sql.Named("size", pageData.pageSize),
+		sql.Named("first", pageData.firstPage),
 	)
 	if err != nil {
 		return UserArtworks{}, err
@@ -403,7 +541,9 @@ func (ar *Store) GetUserArtworks(targetAlias, requesterId string, pageData PageD
 		if err = rows.Scan(
 			&artwork.Id,
 			&artwork.Title,
+			&artwork.Cover,
 			&artwork.Format,
+			&artwork.Images,
 			&artwork.Added,
 			&isNew,
 			&wasDeleted,
@@ -420,7 +560,42 @@ func (ar *Store) GetUserArtworks(targetAlias, requesterId string, pageData PageD
 			requested = append(requested, artwork)
 		}
 	}
-	return UserArtworks{requested, newArtworks, deleted}, rows.Err()
+	if err = rows.Err(); err != nil {
+		return UserArtworks{}, err
+	}
+
+	// complete the previews with their reactions breakdowns
+	var ids = make([]string, 0, len(requested)+len(newArtworks))
+	for _, artwork := range requested {
+		ids = append(ids, artwork.Id)
+	}
+	for _, artwork := range newArtworks {
+		ids = append(ids, artwork.Id)
+	}
+	summaries, err := ar.GetReactionsSummaries(ids, requesterId)
+	if err != nil {
+		return UserArtworks{}, err
+	}
+	for index := range requested {
+		requested[index].ReactionsSummary = summaries[requested[index].Id]
+	}
+	for index := range newArtworks {
+		newArtworks[index].ReactionsSummary = summaries[newArtworks[index].Id]
+	}
+
+	return UserArtworks{requested, newArtworks, deleted}, nil
+}
+
+// CountUserArtworks counts the target user's artworks which the requester may see listed, so that hidden ones aren't
+// disclosed by totals.
+func (ar *Store) CountUserArtworks(targetAlias, requesterId string) (count int, err error) {
+	err = ar.Connection.QueryRow(`
+		SELECT count(*) FROM artworks
+		WHERE author_id IN (SELECT id FROM users WHERE alias = @alias) AND NOT deleted AND `+listedArtwork,
+		sql.Named("alias", targetAlias),
+		sql.Named("requester", requesterId),
+	).Scan(&count)
+	return count, err
 }
 
 type StreamData struct {
@@ -429,6 +604,8 @@ type StreamData struct {
 	DeletedIds  []string
 }
 
+// GetStream returns the paginated artworks of followed authors, among those the user may see listed.
+// Muted authors are left out.
 func (ar *Store) GetStream(userId string, since string, latest string) (data StreamData, err error) {
 	// default artworks capacity set to default paginated size
 	const defaultPage = 12
@@ -439,21 +616,28 @@ func (ar *Store) GetStream(userId string, since string, latest string) (data Str
 	)
 
 	rows, err := ar.Connection.Query(`
-		SELECT arts.id, title, alias, name, format, added, new, deleted,
+		SELECT arts.id, title, alias, name, cover, format, added, new, arts.deleted,
+		       (SELECT count(*) FROM artwork_images WHERE artwork = arts.id) as images,
 		       coalesce(comments, 0) as comments_count,
 		       coalesce(feedback, 0) as feedback_count
-		FROM (SELECT *, added > ? as new FROM artworks
-			WHERE author_id IN (SELECT target FROM followers WHERE follower = ?)
-			AND (added < ? AND NOT deleted)
-			OR (added > ? AND NOT deleted)
-			OR (deleted AND added > ? AND added < ?)) as arts
+		FROM (SELECT *, added > @since as new FROM artworks
+			WHERE author_id IN (SELECT target FROM followers WHERE follower = @requester)
+			AND author_id NOT IN `+mutedUsers+`
+			AND `+listedArtwork+`
+			AND ((added < @latest AND NOT deleted)
+			     OR (added > @since AND NOT deleted)
+			     OR (deleted AND added > @since AND added < @latest))) as arts
 		JOIN users ON arts.author_id = users.id
 		LEFT JOIN (SELECT artwork, count(id) as comments FROM artwork_comments GROUP BY artwork) as comments
 		    ON arts.id = comments.artwork
 		LEFT JOIN (SELECT artwork, count(*) as feedback FROM artwork_feedback GROUP BY artwork) as feedback
 		    ON arts.id = feedback.artwork
-		ORDER BY added DESC LIMIT ?;`,
-		since, userId, latest, since, latest, since, defaultPage,
+		ORDER BY added DESC LIMIT @size;`,
+		sql.Named("since", since),
+		sql.Named("latest", latest),
+		sql.Named("requester", userId),
+		sql.Named("now", ntime.Now()),
+		sql.Named("size", defaultPage),
 	)
 	if err != nil {
 		return data, err
@@ -468,10 +652,12 @@ func (ar *Store) GetStream(userId string, since string, latest string) (data Str
 			&artwork.Title,
 			&artwork.Author.Alias,
 			&artwork.Author.Name,
+			&artwork.Cover,
 			&artwork.Format,
 			&artwork.Added,
 			&recent,
 			&deleted,
+			&artwork.Images,
 			&artwork.Comments,
 			&artwork.Reactions,
 		); err != nil {
@@ -487,10 +673,20 @@ func (ar *Store) GetStream(userId string, since string, latest string) (data Str
 			artworks = append(artworks, artwork)
 		}
 	}
+	if err = rows.Err(); err != nil {
+		return data, err
+	}
+
+	if err = ar.summarisePreviews(artworks, userId); err != nil {
+		return data, err
+	}
+	if err = ar.summarisePreviews(newArtworks, userId); err != nil {
+		return data, err
+	}
 
 	return StreamData{
 		Artworks:    artworks,
 		NewArtworks: newArtworks,
 		DeletedIds:  deletedIds,
-	}, rows.Err()
+	}, nil
 }
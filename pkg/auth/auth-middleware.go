@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"github.com/gofrs/uuid"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/rest"
 	"net/http"
 	"strings"
 )
@@ -45,6 +47,67 @@ func Auth(ar IRepository) func(next http.Handler) http.Handler {
 	}
 }
 
+/*
+The following middleware authorise requests and must be preceded by Auth, which stores the requesting user in the
+context. Since routes' middleware are evaluated in the order they're listed, they can be composed as in:
+
+	engine.Put("/users/:alias/name", updateName(ur), auth.Auth(ar), auth.RequireSelf("alias"))
+*/
+
+// RequireSelf restricts routes to the user whose alias matches the given path parameter.
+func RequireSelf(param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			if MustGetUser(request).Alias != rest.GetParam(request, param) {
+				JSON.Forbidden(w)
+				return
+			}
+			next.ServeHTTP(w, request)
+		})
+	}
+}
+
+// RequireRole restricts routes to users having any of the listed roles.
+func RequireRole(roles ...Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			var role = MustGetUser(request).Role
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, request)
+					return
+				}
+			}
+			JSON.Forbidden(w)
+		})
+	}
+}
+
+// ArtworkOwnership is satisfied by stores able to tell whether users authored artworks; it avoids cyclic imports
+// between the `auth` and `artworks` packages.
+type ArtworkOwnership interface {
+	OwnsArtwork(artworkId, userId string) (bool, error)
+}
+
+// RequireArtworkOwner restricts routes to the author of the artwork identified by the given path parameter.
+// Artworks owned by others are reported as missing, so as not to disclose the ones the user can't access.
+func RequireArtworkOwner(store ArtworkOwnership, param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			owns, err := store.OwnsArtwork(rest.GetParam(request, param), MustGetUser(request).Id)
+			if err != nil {
+				JSON.InternalServerError(w, err)
+				return
+			}
+			if !owns {
+				JSON.NotFound(w, "Artwork not found")
+				return
+			}
+			next.ServeHTTP(w, request)
+		})
+	}
+}
+
 // parseBearer extracts the user id from the authorization header.
 func parseBearer(request *http.Request) (string, error) {
 	var header = request.Header.Get("Authorization")
@@ -57,6 +120,12 @@ func parseBearer(request *http.Request) (string, error) {
 	return "", errParsingAuth
 }
 
+// GetUser fetches the authenticated user, if any, for handlers and middleware serving anonymous requests as well.
+func GetUser(request *http.Request) (User, bool) {
+	user, ok := request.Context().Value(keyUser).(User)
+	return user, ok
+}
+
 // MustGetUser fetches the user's ID from the authorisation header, assuming the handler includes auth middleware.
 func MustGetUser(request *http.Request) User {
 	// one could return an error to detect a possibly missing auth middleware
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	ownerId = "6f1c1c7e-2a4b-4c1e-9d2f-1b1e2c3d4e5f"
+	adminId = "0b7a3e2d-5c4f-4e3a-8b1c-2d3e4f5a6b7c"
+)
+
+// fakeUsers serves the users known to tests, reporting others as missing.
+type fakeUsers map[string]User
+
+func (users fakeUsers) GetUserById(id string) (User, error) {
+	if user, found := users[id]; found {
+		return user, nil
+	}
+	return User{}, sql.ErrNoRows
+}
+
+// fakeOwnership maps artworks to their authors; the "broken" artwork fails lookups.
+type fakeOwnership map[string]string
+
+func (artworks fakeOwnership) OwnsArtwork(artworkId, userId string) (bool, error) {
+	if artworkId == "broken" {
+		return false, errors.New("lookup failed")
+	}
+	return artworks[artworkId] == userId, nil
+}
+
+func TestAuthorisation(t *testing.T) {
+	var logger, _ = test.NewNullLogger()
+	engine, err := rest.New(rest.Config{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users = fakeUsers{
+		ownerId: {Id: ownerId, Alias: "owner", Role: RoleUser},
+		adminId: {Id: adminId, Alias: "admin", Role: RoleAdmin},
+	}
+	var authenticated = Auth(users)
+	var ok = func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}
+	engine.Get("/users/:alias/settings", ok, authenticated, RequireSelf("alias"))
+	engine.Get("/moderation", ok, authenticated, RequireRole(RoleAdmin))
+	engine.Put("/artworks/:artworkId", ok, authenticated, RequireArtworkOwner(fakeOwnership{"art": ownerId}, "artworkId"))
+
+	var tests = []struct {
+		name          string
+		method, path  string
+		authorization string
+		status        int
+	}{
+		{"anonymous requests are unauthorised", http.MethodGet, "/users/owner/settings", "", http.StatusUnauthorized},
+		{"malformed tokens are unauthorised", http.MethodGet, "/users/owner/settings", "Bearer owner",
+			http.StatusUnauthorized},
+		{"unknown users are unauthorised", http.MethodGet, "/users/owner/settings",
+			"Bearer 11111111-2222-4333-8444-555555555555", http.StatusUnauthorized},
+		{"users access their own routes", http.MethodGet, "/users/owner/settings", "Bearer " + ownerId, http.StatusOK},
+		{"users can't access others' routes", http.MethodGet, "/users/admin/settings", "Bearer " + ownerId,
+			http.StatusForbidden},
+		{"admins aren't exempted from self checks", http.MethodGet, "/users/owner/settings", "Bearer " + adminId,
+			http.StatusForbidden},
+		{"roles are required", http.MethodGet, "/moderation", "Bearer " + ownerId, http.StatusForbidden},
+		{"roles grant access", http.MethodGet, "/moderation", "Bearer " + adminId, http.StatusOK},
+		{"roles require authentication first", http.MethodGet, "/moderation", "", http.StatusUnauthorized},
+		{"authors access their artworks", http.MethodPut, "/artworks/art", "Bearer " + ownerId, http.StatusOK},
+		{"others' artworks are reported as missing", http.MethodPut, "/artworks/art", "Bearer " + adminId,
+			http.StatusNotFound},
+		{"missing artworks are reported as such", http.MethodPut, "/artworks/missing", "Bearer " + ownerId,
+			http.StatusNotFound},
+		{"ownership failures are internal errors", http.MethodPut, "/artworks/broken", "Bearer " + ownerId,
+			http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request = httptest.NewRequest(test.method, test.path, nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			var recorder = httptest.NewRecorder()
+			engine.Handler().ServeHTTP(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("responded %d rather than %d", recorder.Code, test.status)
+			}
+			if test.status == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("the WWW-Authenticate header is missing")
+			}
+		})
+	}
+}
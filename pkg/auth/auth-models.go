@@ -1,8 +1,17 @@
 package auth
 
+// Role grants users access to privileged routes.
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
 type User struct {
 	Id    string
 	Name  string
 	Alias string
 	Email string
+	Role  Role
 }
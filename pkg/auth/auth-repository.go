@@ -15,8 +15,12 @@ func NewRepository(connection *sql.DB) *Repository {
 }
 
 // GetUserById either returns a user matching the alias, or an error (along with an ignorable empty struct).
+// Deleted and suspended accounts aren't returned, which denies them access to authenticated routes.
 func (ar *Repository) GetUserById(id string) (user User, err error) {
-	err = ar.Connection.QueryRow("SELECT id, name, alias, email FROM users WHERE id = ?", id).Scan(&user.Id, &user.Name, &user.Alias, &user.Email)
+	err = ar.Connection.QueryRow(`
+		SELECT id, name, alias, email, role FROM users WHERE id = ? AND deleted IS NULL AND suspended IS NULL`,
+		id,
+	).Scan(&user.Id, &user.Name, &user.Alias, &user.Email, &user.Role)
 	if err != nil {
 		return User{}, err
 	}
@@ -0,0 +1,81 @@
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+)
+
+func RegisterHandlers(engine rest.Engine, store Storer, ar auth.IRepository) {
+	var authenticated = auth.Auth(ar)
+	var self = auth.RequireSelf("alias")
+
+	engine.Post("/users/:alias/export", requestExport(store), authenticated, self)
+	engine.Get("/users/:alias/export", getExport(store), authenticated, self)
+	engine.Get("/users/:alias/export/archive", getArchive(store), authenticated, self)
+}
+
+// requestExport handles the POST "/users/:alias/export" route, scheduling the creation of a personal data archive.
+func requestExport(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		if export, err := store.RequestExport(user.Id); err == nil {
+			JSON.Accepted(writer, export)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getExport handles the GET "/users/:alias/export" route, which clients poll to learn whether the archive is ready.
+func getExport(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		if export, err := store.GetLatestExport(user.Id); err == nil {
+			JSON.Ok(writer, export)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "no export was requested")
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getArchive handles the GET "/users/:alias/export/archive" route, downloading the latest archive when ready.
+func getArchive(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		export, err := store.GetLatestExport(user.Id)
+		if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "no export was requested")
+			return
+		} else if err != nil {
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		switch export.Status {
+		case Pending:
+			JSON.BadRequestWithMessage(writer, "the export is still pending")
+			return
+		case Failed:
+			JSON.BadRequestWithMessage(writer, "the export failed, a new one must be requested")
+			return
+		}
+
+		// archives expired but not yet purged are no longer offered
+		if export.Expires.Before(ntime.Now()) {
+			JSON.NotFound(writer, "the export expired, a new one must be requested")
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/zip")
+		writer.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"kvasari-%s.zip\"", user.Alias))
+		http.ServeFile(writer, request, store.GetArchivePath(export.Id))
+	}
+}
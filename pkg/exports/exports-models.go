@@ -0,0 +1,45 @@
+package exports
+
+import (
+	"github.com/silktrader/kvasari/pkg/artworks"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/users"
+)
+
+// Status describes the progress of a personal data export.
+type Status string
+
+const (
+	Pending Status = "pending"
+	Ready   Status = "ready"
+	Failed  Status = "failed"
+)
+
+// Export describes the state of a user's personal data archive; Completed and Expires are null while pending.
+type Export struct {
+	Id        string
+	Status    Status
+	Requested ntime.NTime
+	Completed ntime.NTime
+	Expires   ntime.NTime
+}
+
+// accountData is the content of an archive's account document.
+type accountData struct {
+	User    users.User
+	Details users.UserDetails
+}
+
+// relationsData is the content of an archive's relations document.
+type relationsData struct {
+	Followers []users.RelationData
+	Followed  []users.RelationData
+	Bans      []users.BannedUser
+	Mutes     []users.MutedUser
+}
+
+// artworkEntry pairs an artwork's metadata with the archive paths of its images, in order.
+type artworkEntry struct {
+	artworks.AuthoredArtwork
+	Files []string
+}
@@ -0,0 +1,253 @@
+package exports
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/artworks"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Storer interface {
+	RequestExport(userId string) (Export, error)
+	GetLatestExport(userId string) (Export, error)
+	GetArchivePath(exportId string) string
+	PurgeExpiredExports(now ntime.NTime) (int64, error)
+}
+
+type Store struct {
+	Connection *sql.DB
+	Artworks   artworks.Storer
+	Logger     logrus.FieldLogger
+	Path       string
+	Expiry     time.Duration
+}
+
+var ErrNotFound = errors.New("not found")
+
+func closeRows(rows *sql.Rows) {
+	_ = rows.Close()
+}
+
+// NewStore returns an exports store, creating the archives' directory when missing.
+func NewStore(connection *sql.DB, artworksStore artworks.Storer, logger logrus.FieldLogger, path string,
+	expiry time.Duration) (*Store, error) {
+	if err := os.MkdirAll(path, 0750); err != nil {
+		return nil, err
+	}
+	return &Store{connection, artworksStore, logger, path, expiry}, nil
+}
+
+// GetArchivePath returns the location of an export's ZIP file.
+func (s *Store) GetArchivePath(exportId string) string {
+	return filepath.Join(s.Path, exportId+".zip")
+}
+
+/*
+RequestExport schedules the creation of a user's personal data archive, which is built in a separate goroutine.
+
+An export that's still pending is returned as is, rather than starting a concurrent one.
+*/
+func (s *Store) RequestExport(userId string) (Export, error) {
+	if export, err := s.GetLatestExport(userId); err == nil && export.Status == Pending {
+		return export, nil
+	} else if err != nil && !errors.Is(err, ErrNotFound) {
+		return export, err
+	}
+
+	var export = Export{Id: rest.MustGetNewUUID(), Status: Pending, Requested: ntime.Now()}
+	if _, err := s.Connection.Exec(
+		`INSERT INTO data_exports (id, user, status, requested) VALUES (?, ?, ?, ?)`,
+		export.Id, userId, export.Status, export.Requested,
+	); err != nil {
+		return export, err
+	}
+
+	go s.build(export.Id, userId)
+	return export, nil
+}
+
+// GetLatestExport returns the most recently requested export of a user.
+func (s *Store) GetLatestExport(userId string) (export Export, err error) {
+	err = s.Connection.QueryRow(`
+		SELECT id, status, requested, completed, expires FROM data_exports
+		WHERE user = ? ORDER BY requested DESC LIMIT 1`,
+		userId,
+	).Scan(&export.Id, &export.Status, &export.Requested, &export.Completed, &export.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return export, ErrNotFound
+	}
+	return export, err
+}
+
+// build writes the archive and records its outcome; failures are logged, as there's no requester left to notify.
+func (s *Store) build(exportId, userId string) {
+	var status = Ready
+	if err := s.writeArchive(exportId, userId); err != nil {
+		s.Logger.WithError(err).WithField("export", exportId).Error("data export failed")
+		status = Failed
+		_ = os.Remove(s.GetArchivePath(exportId))
+	}
+
+	var completed = time.Now()
+	if _, err := s.Connection.Exec(
+		`UPDATE data_exports SET status = ?, completed = ?, expires = ? WHERE id = ?`,
+		status, ntime.New(completed), ntime.New(completed.Add(s.Expiry)), exportId,
+	); err != nil {
+		s.Logger.WithError(err).WithField("export", exportId).Error("data export status update failed")
+	}
+}
+
+// writeArchive collects everything known about a user into JSON documents and bundles them, along with the
+// original images of their artworks, in a ZIP file. A temporary file is renamed only once complete.
+func (s *Store) writeArchive(exportId, userId string) (err error) {
+	var temporaryPath = s.GetArchivePath(exportId) + ".tmp"
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(temporaryPath)
+		}
+	}()
+
+	var archive = zip.NewWriter(file)
+	if err = s.writeDocuments(archive, userId); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = archive.Close(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, s.GetArchivePath(exportId))
+}
+
+func (s *Store) writeDocuments(archive *zip.Writer, userId string) error {
+	var userStore = s.Artworks.GetUserStore()
+
+	user, err := userStore.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	details, err := userStore.GetDetails(user.Alias, userId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "account.json", accountData{user, details}); err != nil {
+		return err
+	}
+
+	var relations relationsData
+	if relations.Followers, relations.Followed, err = userStore.GetUserRelations(userId); err != nil {
+		return err
+	}
+	if relations.Bans, err = userStore.GetBans(userId); err != nil {
+		return err
+	}
+	if relations.Mutes, err = userStore.GetMutes(userId); err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "relations.json", relations); err != nil {
+		return err
+	}
+
+	comments, err := s.Artworks.GetAuthoredComments(userId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "comments.json", comments); err != nil {
+		return err
+	}
+
+	reactions, err := s.Artworks.GetAuthoredReactions(userId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "reactions.json", reactions); err != nil {
+		return err
+	}
+
+	authored, err := s.Artworks.GetAuthoredArtworks(userId)
+	if err != nil {
+		return err
+	}
+	var entries = make([]artworkEntry, len(authored))
+	for index, artwork := range authored {
+		entries[index] = artworkEntry{artwork, make([]string, len(artwork.Images))}
+		for position, image := range artwork.Images {
+			var name = fmt.Sprintf("%s.%s", image.Id, image.Format)
+			entries[index].Files[position] = "images/" + name
+			if err = copyFile(archive, "images/"+name, filepath.Join(s.Artworks.GetImagesPath(), name)); err != nil {
+				return err
+			}
+		}
+	}
+	return writeJSON(archive, "artworks.json", entries)
+}
+
+func writeJSON(archive *zip.Writer, name string, payload interface{}) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	var encoder = json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(payload)
+}
+
+func copyFile(archive *zip.Writer, name, source string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// images are already compressed, deflating them would only waste cycles
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// PurgeExpiredExports removes the exports expired before `now`, along with their archives, returning their number.
+func (s *Store) PurgeExpiredExports(now ntime.NTime) (int64, error) {
+	rows, err := s.Connection.Query(`DELETE FROM data_exports WHERE expires < ? RETURNING id`, now)
+	if err != nil {
+		return 0, err
+	}
+	defer closeRows(rows)
+
+	var purged int64
+	var id string
+	for rows.Next() {
+		if err = rows.Scan(&id); err != nil {
+			return purged, err
+		}
+		purged++
+		if e := os.Remove(s.GetArchivePath(id)); e != nil && !errors.Is(e, fs.ErrNotExist) {
+			err = e
+		}
+	}
+	if e := rows.Err(); e != nil {
+		return purged, e
+	}
+	return purged, err
+}
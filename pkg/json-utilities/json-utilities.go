@@ -1,10 +1,18 @@
 package json_utilities
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/silktrader/kvasari/pkg/ntime"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var errEncoding = errors.New("error while encoding response")
@@ -27,16 +35,73 @@ func newHttpMessage(message string) *httpMessage {
 	return &httpMessage{message, ntime.Now()}
 }
 
+// httpCodedMessage complements messages with a stable code, for clients to tell apart failures sharing a status.
+type httpCodedMessage struct {
+	Code      string
+	Message   string
+	Timestamp ntime.NTime
+}
+
 // Created encodes a JSON object in a 201 created response.
 func Created(writer http.ResponseWriter, payload interface{}) {
 	encodeJSON(writer, http.StatusCreated, payload)
 }
 
+// Accepted encodes a JSON object in a 202 accepted response, signalling that the request will be processed later.
+func Accepted(writer http.ResponseWriter, payload interface{}) {
+	encodeJSON(writer, http.StatusAccepted, payload)
+}
+
 // Ok encodes a JSON object in a 200 OK response.
 func Ok(writer http.ResponseWriter, payload interface{}) {
 	encodeJSON(writer, http.StatusOK, payload)
 }
 
+// OkWithETag encodes a JSON object in a 200 OK response, tagged with a hash of its contents.
+// A 304 not modified response is sent instead, when the request's `If-None-Match` header matches the hash.
+func OkWithETag(writer http.ResponseWriter, request *http.Request, payload interface{}) {
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(payload); err != nil {
+		InternalServerError(writer, errEncoding)
+		return
+	}
+
+	var checksum = sha256.Sum256(buffer.Bytes())
+	var etag = fmt.Sprintf(`"%s"`, hex.EncodeToString(checksum[:16]))
+
+	// clients are expected to revalidate their cached copies at every request
+	writer.Header().Set("ETag", etag)
+	writer.Header().Set("Cache-Control", "private, no-cache")
+	if matchesETag(request.Header.Get("If-None-Match"), etag) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(buffer.Bytes())
+}
+
+// matchesETag reports whether an `If-None-Match` header lists the given entity tag, ignoring weak validators.
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// TemporaryRedirect encodes a JSON object in a 307 temporary redirect response, pointing clients to the location.
+// Unlike 302, the status code preserves the request's method and body; the response mustn't be cached, since the
+// redirection may cease at any time.
+func TemporaryRedirect(writer http.ResponseWriter, location string, payload interface{}) {
+	writer.Header().Set("Location", location)
+	writer.Header().Set("Cache-Control", "no-store")
+	encodeJSON(writer, http.StatusTemporaryRedirect, payload)
+}
+
 // NoContent sets the appropriate headers of a 204 no content response.
 func NoContent(writer http.ResponseWriter) {
 	writer.WriteHeader(http.StatusNoContent)
@@ -47,6 +112,24 @@ func NotFound(writer http.ResponseWriter, message string) {
 	encodeJSON(writer, http.StatusNotFound, newHttpMessage(message))
 }
 
+// Conflict encodes a JSON object containing a timestamp and a message in a 409 conflict response.
+func Conflict(writer http.ResponseWriter, message string) {
+	encodeJSON(writer, http.StatusConflict, newHttpMessage(message))
+}
+
+// TooManyRequests encodes a JSON object containing a timestamp and a message in a 429 too many requests response,
+// advising clients to retry after the given delay, rounded up to the second.
+func TooManyRequests(writer http.ResponseWriter, retryAfter time.Duration, message string) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	encodeJSON(writer, http.StatusTooManyRequests, newHttpMessage(message))
+}
+
+// InsufficientStorage encodes a JSON object containing a code, a message and a timestamp in a 507 insufficient storage
+// response.
+func InsufficientStorage(writer http.ResponseWriter, code string, message string) {
+	encodeJSON(writer, http.StatusInsufficientStorage, &httpCodedMessage{code, message, ntime.Now()})
+}
+
 // BadRequest sets the appropriate headers of a 400 bad request response.
 func BadRequest(writer http.ResponseWriter) {
 	writer.WriteHeader(http.StatusBadRequest)
@@ -0,0 +1,113 @@
+package moderation
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+)
+
+func RegisterHandlers(engine rest.Engine, store Storer, ar auth.IRepository) {
+	var authenticated = auth.Auth(ar)
+	var admin = auth.RequireRole(auth.RoleAdmin)
+
+	engine.Post("/reports", addReport(store), authenticated)
+
+	// moderation queue, reserved to admins
+	engine.Get("/moderation/reports", getReports(store), authenticated, admin)
+	engine.Post("/moderation/actions", takeAction(store), authenticated, admin)
+	engine.Get("/moderation/actions", getActions(store), authenticated, admin)
+}
+
+// addReport handles the authenticated POST "/reports" route, flagging content for admins to review
+func addReport(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[AddReportData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var user = auth.MustGetUser(request)
+		if data.SubjectType == UserSubject && data.Subject == user.Alias {
+			JSON.BadRequestWithMessage(writer, "Can't report oneself")
+			return
+		}
+
+		var date = ntime.Now()
+		if id, e := store.AddReport(user.Id, data, date); e == nil {
+			JSON.Created(writer, struct {
+				Id      string
+				Created ntime.NTime
+			}{id, date})
+		} else if errors.Is(e, ErrNotFound) {
+			JSON.NotFound(writer, "Reported content not found")
+		} else if errors.Is(e, ErrDupReport) {
+			JSON.BadRequestWithMessage(writer, "You already reported this content")
+		} else {
+			JSON.InternalServerError(writer, e)
+		}
+	}
+}
+
+// getReports handles the admin GET "/moderation/reports?status=open" route; open reports are listed by default
+func getReports(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var status = ReportStatus(request.URL.Query().Get("status"))
+		if status == "" {
+			status = Open
+		} else if err := validation.Validate(status, validation.In(Open, Resolved, Dismissed)); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if reports, err := store.GetReports(status); err == nil {
+			JSON.Ok(writer, reports)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// takeAction handles the admin POST "/moderation/actions" route
+func takeAction(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		data, err := JSON.DecodeValidate[ActionData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var admin = auth.MustGetUser(request)
+		if data.Action == SuspendUser && data.Subject == admin.Alias {
+			JSON.BadRequestWithMessage(writer, "Can't suspend oneself")
+			return
+		}
+
+		var date = ntime.Now()
+		if id, e := store.TakeAction(admin.Id, data, date); e == nil {
+			JSON.Created(writer, struct {
+				Id   string
+				Date ntime.NTime
+			}{id, date})
+		} else if errors.Is(e, ErrNotFound) {
+			JSON.NotFound(writer, "Subject not found, or already in the requested state")
+		} else {
+			JSON.InternalServerError(writer, e)
+		}
+	}
+}
+
+// getActions handles the admin GET "/moderation/actions" route, returning the audit trail
+func getActions(store Storer) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if actions, err := store.GetActions(); err == nil {
+			JSON.Ok(writer, actions)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
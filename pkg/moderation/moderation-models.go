@@ -0,0 +1,113 @@
+package moderation
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/silktrader/kvasari/pkg/ntime"
+)
+
+// SubjectType describes what kind of content is reported.
+type SubjectType string
+
+const (
+	ArtworkSubject SubjectType = "artwork"
+	CommentSubject SubjectType = "comment"
+	UserSubject    SubjectType = "user"
+)
+
+// Reason categorises reports, so that admins can prioritise them.
+type Reason string
+
+const (
+	Spam          Reason = "spam"
+	Harassment    Reason = "harassment"
+	Nudity        Reason = "nudity"
+	Violence      Reason = "violence"
+	Copyright     Reason = "copyright"
+	Impersonation Reason = "impersonation"
+	Other         Reason = "other"
+)
+
+// ReportStatus tracks whether reports still require attention.
+type ReportStatus string
+
+const (
+	Open      ReportStatus = "open"
+	Resolved  ReportStatus = "resolved"
+	Dismissed ReportStatus = "dismissed"
+)
+
+// Action enumerates what admins can do about reports and the content they point to.
+type Action string
+
+const (
+	TakeDownArtwork Action = "take-down-artwork"
+	TakeDownComment Action = "take-down-comment"
+	SuspendUser     Action = "suspend-user"
+	ReinstateUser   Action = "reinstate-user"
+	DismissReport   Action = "dismiss-report"
+)
+
+// AddReportData describes a report; users are referred to by alias, artworks and comments by ID.
+type AddReportData struct {
+	SubjectType SubjectType
+	Subject     string
+	Reason      Reason
+	Details     *string
+}
+
+func (data AddReportData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.SubjectType, validation.Required,
+			validation.In(ArtworkSubject, CommentSubject, UserSubject)),
+		validation.Field(&data.Subject, validation.Required, validation.Length(1, 64)),
+		validation.Field(&data.Reason, validation.Required,
+			validation.In(Spam, Harassment, Nudity, Violence, Copyright, Impersonation, Other)),
+		validation.Field(&data.Details, validation.Length(0, 1000)),
+	)
+}
+
+// Report is a user's complaint about some content, as listed to admins. SubjectAlias is only set for users.
+type Report struct {
+	Id            string
+	ReporterAlias *string
+	SubjectType   SubjectType
+	Subject       string
+	SubjectAlias  *string
+	Reason        Reason
+	Details       *string
+	Status        ReportStatus
+	Created       ntime.NTime
+	Closed        ntime.NTime
+}
+
+// ActionData describes a moderation action; users are referred to by alias, reports, artworks and comments by ID.
+// Dismissals require ReportId alone, while other actions close all the open reports on their subjects.
+type ActionData struct {
+	Action   Action
+	Subject  string
+	ReportId *string
+	Note     *string
+}
+
+func (data ActionData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.Action, validation.Required,
+			validation.In(TakeDownArtwork, TakeDownComment, SuspendUser, ReinstateUser, DismissReport)),
+		validation.Field(&data.Subject, validation.When(data.Action != DismissReport,
+			validation.Required, validation.Length(1, 64))),
+		validation.Field(&data.ReportId, validation.When(data.Action == DismissReport, validation.Required)),
+		validation.Field(&data.Note, validation.Length(0, 1000)),
+	)
+}
+
+// ModerationAction is an entry of the audit trail. Snapshot preserves the text of removed comments.
+type ModerationAction struct {
+	Id         string
+	AdminAlias *string
+	Action     Action
+	Subject    string
+	ReportId   *string
+	Note       *string
+	Snapshot   *string
+	Date       ntime.NTime
+}
@@ -0,0 +1,219 @@
+package moderation
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/rest"
+)
+
+type Storer interface {
+	AddReport(reporterId string, data AddReportData, date ntime.NTime) (string, error)
+	GetReports(status ReportStatus) ([]Report, error)
+	TakeAction(adminId string, data ActionData, date ntime.NTime) (string, error)
+	GetActions() ([]ModerationAction, error)
+}
+
+type Store struct {
+	Connection *sql.DB
+}
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDupReport = errors.New("subject already reported")
+)
+
+func closeRows(rows *sql.Rows) {
+	_ = rows.Close()
+}
+
+func NewStore(connection *sql.DB) *Store {
+	return &Store{connection}
+}
+
+// AddReport files a report, once its subject is found; users can't file more than one open report on any subject.
+func (s *Store) AddReport(reporterId string, data AddReportData, date ntime.NTime) (string, error) {
+	var subjectQuery string
+	switch data.SubjectType {
+	case ArtworkSubject:
+		subjectQuery = `SELECT id FROM artworks WHERE id = ? AND NOT deleted`
+	case CommentSubject:
+		subjectQuery = `SELECT id FROM artwork_comments WHERE id = ?`
+	default:
+		subjectQuery = `SELECT id FROM users WHERE alias = ? AND deleted IS NULL`
+	}
+
+	var subject string
+	if err := s.Connection.QueryRow(subjectQuery, data.Subject).Scan(&subject); errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	var id = rest.MustGetNewUUID()
+	if _, err := s.Connection.Exec(`
+		INSERT INTO reports (id, reporter, subject_type, subject, reason, details, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, reporterId, data.SubjectType, subject, data.Reason, data.Details, date,
+	); err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return "", ErrDupReport
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+// GetReports lists the reports matching the status, oldest first, so that admins can handle them in order.
+func (s *Store) GetReports(status ReportStatus) ([]Report, error) {
+	var reports = make([]Report, 0)
+	rows, err := s.Connection.Query(`
+		SELECT reports.id, reporters.alias, subject_type, subject, subjects.alias,
+		       reason, details, status, reports.created, closed
+		FROM reports
+		LEFT JOIN users as reporters ON reports.reporter = reporters.id
+		LEFT JOIN users as subjects ON subject_type = 'user' AND subject = subjects.id
+		WHERE status = ?
+		ORDER BY reports.created`,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var report Report
+		if err = rows.Scan(
+			&report.Id,
+			&report.ReporterAlias,
+			&report.SubjectType,
+			&report.Subject,
+			&report.SubjectAlias,
+			&report.Reason,
+			&report.Details,
+			&report.Status,
+			&report.Created,
+			&report.Closed,
+		); err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+/*
+TakeAction applies a moderation action and records it in the audit trail, returning the entry's ID. An empty admin ID
+marks actions taken by scripts, rather than through the API.
+
+Artworks are taken down through their soft deletion, while comments are removed, their text kept in the trail.
+Suspended users are denied access to authenticated routes until reinstated. All the open reports on the action's
+subject are resolved, whereas dismissals only close the report they refer to.
+Returns ErrNotFound when the subject doesn't exist, or is already in the requested state.
+*/
+func (s *Store) TakeAction(adminId string, data ActionData, date ntime.NTime) (string, error) {
+	tx, err := s.Connection.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var (
+		subjectType SubjectType
+		subject     = data.Subject
+		snapshot    *string
+		result      sql.Result
+	)
+	switch data.Action {
+	case TakeDownArtwork:
+		subjectType = ArtworkSubject
+		result, err = tx.Exec(`UPDATE artworks SET deleted = TRUE WHERE id = ? AND NOT deleted`, subject)
+	case TakeDownComment:
+		subjectType = CommentSubject
+		err = tx.QueryRow(`DELETE FROM artwork_comments WHERE id = ? RETURNING comment`, subject).Scan(&snapshot)
+	case SuspendUser, ReinstateUser:
+		subjectType = UserSubject
+		var query = `UPDATE users SET suspended = ? WHERE alias = ? AND suspended IS NULL AND deleted IS NULL RETURNING id`
+		var suspended interface{} = date
+		if data.Action == ReinstateUser {
+			query = `UPDATE users SET suspended = ? WHERE alias = ? AND suspended IS NOT NULL RETURNING id`
+			suspended = nil
+		}
+		err = tx.QueryRow(query, suspended, subject).Scan(&subject)
+	case DismissReport:
+		subject = *data.ReportId
+		result, err = tx.Exec(`UPDATE reports SET status = ?, closed = ? WHERE id = ? AND status = ?`,
+			Dismissed, date, subject, Open)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if result != nil {
+		if affected, e := result.RowsAffected(); e != nil {
+			return "", e
+		} else if affected == 0 {
+			return "", ErrNotFound
+		}
+	}
+
+	if subjectType != "" {
+		if _, err = tx.Exec(`
+			UPDATE reports SET status = ?, closed = ? WHERE subject_type = ? AND subject = ? AND status = ?`,
+			Resolved, date, subjectType, subject, Open,
+		); err != nil {
+			return "", err
+		}
+	}
+
+	var id = rest.MustGetNewUUID()
+	var admin = sql.NullString{String: adminId, Valid: adminId != ""}
+	if _, err = tx.Exec(`
+		INSERT INTO moderation_actions (id, admin, action, subject, report, note, snapshot, date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, admin, data.Action, subject, data.ReportId, data.Note, snapshot, date,
+	); err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit()
+}
+
+// GetActions returns the audit trail of moderation actions, most recent first.
+func (s *Store) GetActions() ([]ModerationAction, error) {
+	var actions = make([]ModerationAction, 0)
+	rows, err := s.Connection.Query(`
+		SELECT moderation_actions.id, alias, action, subject, report, note, snapshot, date
+		FROM moderation_actions LEFT JOIN users ON moderation_actions.admin = users.id
+		ORDER BY date DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var action ModerationAction
+		if err = rows.Scan(
+			&action.Id,
+			&action.AdminAlias,
+			&action.Action,
+			&action.Subject,
+			&action.ReportId,
+			&action.Note,
+			&action.Snapshot,
+			&action.Date,
+		); err != nil {
+			return actions, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
@@ -55,3 +55,18 @@ func Now() NTime {
 func (nt *NTime) Before(compared NTime) bool {
 	return nt.time.Before(compared.time)
 }
+
+// New wraps a valid time.Time, normalised to UTC.
+func New(t time.Time) NTime {
+	return NTime{time: t.UTC(), isValid: true}
+}
+
+// Time returns the underlying time.Time, or its zero value when NTime is null.
+func (nt NTime) Time() time.Time {
+	return nt.time
+}
+
+// Valid reports whether the time isn't null.
+func (nt NTime) Valid() bool {
+	return nt.isValid
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Sample builds a value conforming to a schema, for operations lacking examples. Examples, defaults and enumerations are
+preferred, then values are made up from the schema's type, format and length constraints; objects are given all their
+properties. Patterns aren't accounted for, so that made up strings may still be rejected.
+*/
+func (spec Spec) Sample(schema Node) any {
+	return spec.sample(schema, 0)
+}
+
+func (spec Spec) sample(schema Node, depth int) any {
+	schema = spec.Resolve(schema)
+	if example, ok := spec.Example(Node{"schema": schema}); ok {
+		return example
+	}
+
+	// recursive schemas are cut short
+	if depth > 8 {
+		return nil
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		var merged = make(Node)
+		for _, item := range all {
+			if sub, isNode := item.(Node); isNode {
+				if object, isObject := spec.sample(sub, depth+1).(Node); isObject {
+					for key, value := range object {
+						merged[key] = value
+					}
+				}
+			}
+		}
+		return merged
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if options, ok := schema[keyword].([]any); ok && len(options) > 0 {
+			if sub, isNode := options[0].(Node); isNode {
+				return spec.sample(sub, depth+1)
+			}
+		}
+	}
+
+	switch kind, _ := schema["type"].(string); kind {
+	case "object":
+		var properties, _ = schema["properties"].(Node)
+		var keys = make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var object = make(Node, len(properties))
+		for _, key := range keys {
+			if property, ok := properties[key].(Node); ok {
+				object[key] = spec.sample(property, depth+1)
+			}
+		}
+		return object
+	case "array":
+		var items, _ = schema["items"].(Node)
+		var size, _ = number(schema["minItems"])
+		var array = make([]any, int(size))
+		for index := range array {
+			array[index] = spec.sample(items, depth+1)
+		}
+		return array
+	case "integer", "number":
+		if minimum, ok := number(schema["minimum"]); ok {
+			return minimum
+		}
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		return sampleString(schema)
+	default:
+		return nil
+	}
+}
+
+// sampleString makes up a string matching a schema's format, or length constraints.
+func sampleString(schema Node) string {
+	switch schema["format"] {
+	case "uuid":
+		return "00000000-0000-4000-8000-000000000000"
+	case "date-time":
+		return time.Now().UTC().Format(time.RFC3339)
+	case "email":
+		return "sample@example.com"
+	}
+	var length, _ = number(schema["minLength"])
+	if length < 1 {
+		length = 1
+	}
+	return strings.Repeat("x", int(length))
+}
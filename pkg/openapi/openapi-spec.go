@@ -0,0 +1,263 @@
+/*
+Package openapi loads the API's OpenAPI 3 specification, so that its operations can be matched against the registered
+routes and its schemas can validate the responses of handlers.
+
+Documents are decoded loosely, as generic maps, since only paths, operations, parameters, examples and schemas are
+inspected; references are limited to the document's own components.
+*/
+package openapi
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// methods lists the operations' methods a path item can hold, in the order they're reported.
+var methods = [...]string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+	http.MethodOptions,
+}
+
+// Node is a decoded object of the specification.
+type Node = map[string]any
+
+// Spec is a decoded OpenAPI document.
+type Spec struct {
+	document Node
+}
+
+// Operation is a method on a path of the specification, as in "GET /users/{alias}".
+type Operation struct {
+	Method string
+	Path   string
+	Id     string
+
+	// Parameters merges the path item's parameters with the operation's ones, which take precedence
+	Parameters []Node
+
+	spec Spec
+	node Node
+}
+
+// Load reads and decodes a specification written in YAML, or JSON.
+func Load(path string) (Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	var raw any
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return Spec{}, fmt.Errorf("can't decode the specification: %w", err)
+	}
+	document, ok := normalise(raw).(Node)
+	if !ok {
+		return Spec{}, fmt.Errorf("the specification isn't an object")
+	}
+	if _, ok = document["paths"].(Node); !ok {
+		return Spec{}, fmt.Errorf("the specification lacks paths")
+	}
+	return Spec{document}, nil
+}
+
+// normalise converts the maps decoded by yaml.v2, which are keyed by any value, to string keyed ones, as JSON's.
+func normalise(value any) any {
+	switch typed := value.(type) {
+	case map[any]any:
+		var node = make(Node, len(typed))
+		for key, item := range typed {
+			node[fmt.Sprint(key)] = normalise(item)
+		}
+		return node
+	case []any:
+		for index, item := range typed {
+			typed[index] = normalise(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+// Operations lists the specification's operations, sorted by path and method.
+func (spec Spec) Operations() []Operation {
+	var paths = spec.document["paths"].(Node)
+	var sorted = make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var operations = make([]Operation, 0)
+	for _, path := range sorted {
+		item, ok := paths[path].(Node)
+		if !ok {
+			continue
+		}
+		for _, method := range methods {
+			node, found := item[strings.ToLower(method)].(Node)
+			if !found {
+				continue
+			}
+			var id, _ = node["operationId"].(string)
+			operations = append(operations, Operation{
+				Method:     method,
+				Path:       path,
+				Id:         id,
+				Parameters: spec.mergeParameters(item["parameters"], node["parameters"]),
+				spec:       spec,
+				node:       node,
+			})
+		}
+	}
+	return operations
+}
+
+// mergeParameters resolves the parameters of path items and operations, letting the latter override the former.
+func (spec Spec) mergeParameters(lists ...any) []Node {
+	var merged = make([]Node, 0)
+	var positions = make(map[string]int)
+	for _, list := range lists {
+		items, _ := list.([]any)
+		for _, item := range items {
+			parameter, ok := item.(Node)
+			if !ok {
+				continue
+			}
+			parameter = spec.Resolve(parameter)
+			var key = fmt.Sprintf("%v:%v", parameter["in"], parameter["name"])
+			if position, found := positions[key]; found {
+				merged[position] = parameter
+				continue
+			}
+			positions[key] = len(merged)
+			merged = append(merged, parameter)
+		}
+	}
+	return merged
+}
+
+/*
+Resolve follows a node's `$ref`, if any, within the document, as in "#/components/schemas/Artwork". Unresolvable
+references yield empty nodes, which validate anything.
+*/
+func (spec Spec) Resolve(node Node) Node {
+	for depth := 0; depth < 32; depth++ {
+		reference, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var target any = spec.document
+		for _, segment := range strings.Split(strings.TrimPrefix(reference, "#/"), "/") {
+			parent, isNode := target.(Node)
+			if !isNode {
+				return Node{}
+			}
+			target = parent[strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")]
+		}
+		if node, ok = target.(Node); !ok {
+			return Node{}
+		}
+	}
+	return Node{}
+}
+
+// RoutePattern converts a specification's path template to the router's syntax, as in "/users/:alias".
+func RoutePattern(path string) string {
+	var replacer = strings.NewReplacer("{", ":", "}", "")
+	return replacer.Replace(path)
+}
+
+// String describes the operation as its method and path.
+func (operation Operation) String() string {
+	return operation.Method + " " + operation.Path
+}
+
+// Response returns the operation's documented response for a status code, falling back to the "default" one.
+func (operation Operation) Response(status int) (Node, bool) {
+	responses, _ := operation.node["responses"].(Node)
+	for _, key := range []string{strconv.Itoa(status), fmt.Sprintf("%dXX", status/100), "default"} {
+		if response, ok := responses[key].(Node); ok {
+			return operation.spec.Resolve(response), true
+		}
+	}
+	return nil, false
+}
+
+// ResponseSchema returns the schema of a documented response's JSON content, if any.
+func (operation Operation) ResponseSchema(status int) (Node, bool) {
+	response, found := operation.Response(status)
+	if !found {
+		return nil, false
+	}
+	content, _ := response["content"].(Node)
+	media, ok := content["application/json"].(Node)
+	if !ok {
+		return nil, false
+	}
+	schema, ok := media["schema"].(Node)
+	return schema, ok
+}
+
+// RequestBody returns the media type of the operation's request body along with its definition; JSON is preferred
+// when several media types are accepted.
+func (operation Operation) RequestBody() (string, Node, bool) {
+	body, ok := operation.node["requestBody"].(Node)
+	if !ok {
+		return "", nil, false
+	}
+	content, _ := operation.spec.Resolve(body)["content"].(Node)
+	if media, found := content["application/json"].(Node); found {
+		return "application/json", media, true
+	}
+	var types = make([]string, 0, len(content))
+	for key := range content {
+		types = append(types, key)
+	}
+	if len(types) == 0 {
+		return "", nil, false
+	}
+	sort.Strings(types)
+	media, _ := content[types[0]].(Node)
+	return types[0], media, true
+}
+
+// Example returns the example of a media type or parameter, either its own or its schema's.
+func (spec Spec) Example(node Node) (any, bool) {
+	if example, ok := node["example"]; ok {
+		return example, true
+	}
+	if examples, ok := node["examples"].(Node); ok {
+		for _, item := range examples {
+			if example, isNode := item.(Node); isNode {
+				if value, found := spec.Resolve(example)["value"]; found {
+					return value, true
+				}
+			}
+		}
+	}
+	if schema, ok := node["schema"].(Node); ok {
+		schema = spec.Resolve(schema)
+		if example, found := schema["example"]; found {
+			return example, true
+		}
+		if defaults, found := schema["default"]; found {
+			return defaults, true
+		}
+		if enum, found := schema["enum"].([]any); found && len(enum) > 0 {
+			return enum[0], true
+		}
+	}
+	return nil, false
+}
+
+// Schema resolves the schema of a media type or parameter.
+func (spec Spec) Schema(node Node) Node {
+	schema, _ := node["schema"].(Node)
+	return spec.Resolve(schema)
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+/*
+Validate checks a decoded JSON value against a schema, returning the violations found, each prefixed by the value's
+location, as in "$.Author.Alias". The subset of keywords used by the specification is supported:
+
+  - `$ref`, `allOf`, `anyOf` and `oneOf`, the latter being as lenient as `anyOf`
+  - `type` and `nullable`, `enum`
+  - `properties`, `required` and `additionalProperties`, `items`
+  - `minimum`, `maximum`, `minLength`, `maxLength`, `minItems`, `maxItems` and `pattern`
+
+Formats are ignored, as are patterns Go can't compile.
+*/
+func (spec Spec) Validate(schema Node, value any) []error {
+	return spec.validate(schema, value, "$")
+}
+
+func (spec Spec) validate(schema Node, value any, location string) []error {
+	schema = spec.Resolve(schema)
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || len(schema) == 0 {
+			return nil
+		}
+	}
+
+	var violations = make([]error, 0)
+	var fail = func(format string, args ...any) {
+		violations = append(violations, fmt.Errorf("%s: %s", location, fmt.Sprintf(format, args...)))
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, item := range all {
+			if sub, isNode := item.(Node); isNode {
+				violations = append(violations, spec.validate(sub, value, location)...)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if options, ok := schema[keyword].([]any); ok && len(options) > 0 {
+			var matched bool
+			for _, item := range options {
+				if sub, isNode := item.(Node); isNode && len(spec.validate(sub, value, location)) == 0 {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				fail("matches none of the %s schemas", keyword)
+			}
+		}
+	}
+
+	if kind, ok := schema["type"].(string); ok && !hasType(value, kind) {
+		if value == nil {
+			fail("is null, while it isn't nullable")
+		} else {
+			fail("is %s rather than %s", typeOf(value), kind)
+		}
+		return violations
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !contains(enum, value) {
+		fail("%v isn't among %v", value, enum)
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		violations = append(violations, spec.validateObject(schema, typed, location)...)
+	case []any:
+		if size, ok := number(schema["minItems"]); ok && float64(len(typed)) < size {
+			fail("has %d items, fewer than %v", len(typed), size)
+		}
+		if size, ok := number(schema["maxItems"]); ok && float64(len(typed)) > size {
+			fail("has %d items, more than %v", len(typed), size)
+		}
+		if items, ok := schema["items"].(Node); ok {
+			for index, item := range typed {
+				violations = append(violations, spec.validate(items, item, fmt.Sprintf("%s[%d]", location, index))...)
+			}
+		}
+	case string:
+		var length = float64(utf8.RuneCountInString(typed))
+		if size, ok := number(schema["minLength"]); ok && length < size {
+			fail("is shorter than %v characters", size)
+		}
+		if size, ok := number(schema["maxLength"]); ok && length > size {
+			fail("is longer than %v characters", size)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if expression, err := regexp.Compile(pattern); err == nil && !expression.MatchString(typed) {
+				fail("%q doesn't match %s", typed, pattern)
+			}
+		}
+	case float64:
+		if limit, ok := number(schema["minimum"]); ok && typed < limit {
+			fail("%v is lower than %v", typed, limit)
+		}
+		if limit, ok := number(schema["maximum"]); ok && typed > limit {
+			fail("%v is greater than %v", typed, limit)
+		}
+	}
+	return violations
+}
+
+// validateObject checks an object's properties, reporting missing required ones and, when forbidden, additional ones.
+func (spec Spec) validateObject(schema Node, object map[string]any, location string) []error {
+	var violations = make([]error, 0)
+	var properties, _ = schema["properties"].(Node)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if _, found := object[fmt.Sprint(name)]; !found {
+				violations = append(violations, fmt.Errorf("%s: lacks the required %v property", location, name))
+			}
+		}
+	}
+
+	// sorting keys keeps reports stable across runs
+	var keys = make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var nested = location + "." + key
+		if property, ok := properties[key].(Node); ok {
+			violations = append(violations, spec.validate(property, object[key], nested)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				violations = append(violations, fmt.Errorf("%s: isn't an allowed property", nested))
+			}
+		case Node:
+			violations = append(violations, spec.validate(additional, object[key], nested)...)
+		}
+	}
+	return violations
+}
+
+// hasType reports whether a decoded JSON value is of the given schema type.
+func hasType(value any, kind string) bool {
+	switch typed := value.(type) {
+	case nil:
+		return false
+	case map[string]any:
+		return kind == "object"
+	case []any:
+		return kind == "array"
+	case string:
+		return kind == "string"
+	case bool:
+		return kind == "boolean"
+	case float64:
+		return kind == "number" || (kind == "integer" && typed == math.Trunc(typed))
+	default:
+		return false
+	}
+}
+
+// typeOf names the schema type of a decoded JSON value.
+func typeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// contains reports whether an enumeration lists a value; numbers are compared by value, whatever their decoded type.
+func contains(enum []any, value any) bool {
+	for _, item := range enum {
+		if fmt.Sprint(item) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// number converts the numeric keywords decoded from YAML, which may be integers, to floats.
+func number(value any) (float64, bool) {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	default:
+		return 0, false
+	}
+}
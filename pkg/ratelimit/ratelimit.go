@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/silktrader/kvasari/pkg/auth"
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Budget allows a number of requests per period, which can be spent in bursts and replenish steadily.
+// Zero requests disable limiting.
+type Budget struct {
+	Requests int
+	Period   time.Duration
+}
+
+// refillTime returns how long it takes to replenish the given number of tokens.
+func (budget Budget) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens * float64(budget.Period) / float64(budget.Requests))
+}
+
+// Clock provides the current time; fake clocks make limits reproducible.
+type Clock func() time.Time
+
+// bucket holds the tokens left to a client, as of the last time it was updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	budget  Budget
+}
+
+// Decision describes the outcome of a request's evaluation.
+type Decision struct {
+	Allowed bool
+
+	// Remaining is the number of whole tokens left after the request
+	Remaining int
+
+	// RetryAfter is the time until a request would be allowed, zero for allowed requests
+	RetryAfter time.Duration
+
+	// Reset is the time until the budget is fully replenished
+	Reset time.Duration
+}
+
+// Limiter tracks token buckets in memory, keyed by route and client. Buckets are lost at restarts.
+type Limiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	clock   Clock
+}
+
+func New(clock Clock) *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), clock: clock}
+}
+
+// Allow spends a token from the key's bucket, when available, creating full buckets for unknown keys.
+func (limiter *Limiter) Allow(key string, budget Budget) Decision {
+	var now = limiter.clock()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	var b, found = limiter.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(budget.Requests), updated: now, budget: budget}
+		limiter.buckets[key] = b
+	}
+
+	// refill the tokens accrued since the last update, up to the budget's capacity; clock skews are ignored
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		var refilled = elapsed.Seconds() * float64(budget.Requests) / budget.Period.Seconds()
+		b.tokens = math.Min(float64(budget.Requests), b.tokens+refilled)
+		b.updated = now
+	}
+
+	var decision = Decision{Allowed: b.tokens >= 1}
+	if decision.Allowed {
+		b.tokens--
+	} else {
+		decision.RetryAfter = budget.refillTime(1 - b.tokens)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = budget.refillTime(float64(budget.Requests) - b.tokens)
+	return decision
+}
+
+// Evict drops the buckets which have been replenished, as they're indistinguishable from new ones, returning
+// their number.
+func (limiter *Limiter) Evict() int {
+	var now = limiter.clock()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	var evicted int
+	for key, b := range limiter.buckets {
+		if now.Sub(b.updated) >= b.budget.refillTime(float64(b.budget.Requests)-b.tokens) {
+			delete(limiter.buckets, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+/*
+Limit returns a middleware limiting the requests to the named route, or group of routes sharing the name, within the
+budget. Clients are identified by their authenticated user's ID when available, by their IP otherwise, so the middleware
+should follow the authentication one. Zero budgets disable limiting.
+
+Responses include the `RateLimit-Limit`, `RateLimit-Remaining` and `RateLimit-Reset` headers, while rejected requests
+receive a 429 status along with a `Retry-After` header.
+*/
+func (limiter *Limiter) Limit(name string, budget Budget) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if budget.Requests == 0 {
+			return next
+		}
+
+		var policy = fmt.Sprintf("%d;w=%d", budget.Requests, int(budget.Period.Seconds()))
+		return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			var client = "ip:" + rest.ClientIP(request)
+			if user, ok := auth.GetUser(request); ok {
+				client = "user:" + user.Id
+			}
+
+			var decision = limiter.Allow(name+"|"+client, budget)
+			var header = w.Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(budget.Requests))
+			header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(decision.Reset.Seconds()))))
+
+			if !decision.Allowed {
+				JSON.TooManyRequests(w, decision.RetryAfter, "Rate limit exceeded, retry later.")
+				return
+			}
+			next.ServeHTTP(w, request)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"github.com/silktrader/kvasari/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is moved forward by tests, rather than by the passing of time.
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// budget replenishes one token every ten seconds.
+var budget = Budget{Requests: 3, Period: 30 * time.Second}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		advance  time.Duration
+		expected Decision
+	}
+	var tests = []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "new clients start with a full budget",
+			steps: []step{
+				{0, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+			},
+		},
+		{
+			name: "bursts spend the whole budget",
+			steps: []step{
+				{0, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+				{0, Decision{Allowed: true, Remaining: 1, Reset: 20 * time.Second}},
+				{0, Decision{Allowed: true, Remaining: 0, Reset: 30 * time.Second}},
+				{0, Decision{Allowed: false, Remaining: 0, RetryAfter: 10 * time.Second, Reset: 30 * time.Second}},
+			},
+		},
+		{
+			name: "tokens refill steadily",
+			steps: []step{
+				{0, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+				{0, Decision{Allowed: true, Remaining: 1, Reset: 20 * time.Second}},
+				{0, Decision{Allowed: true, Remaining: 0, Reset: 30 * time.Second}},
+				{5 * time.Second, Decision{Allowed: false, RetryAfter: 5 * time.Second, Reset: 25 * time.Second}},
+				{5 * time.Second, Decision{Allowed: true, Remaining: 0, Reset: 30 * time.Second}},
+			},
+		},
+		{
+			name: "refills are capped by the budget",
+			steps: []step{
+				{0, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+				{time.Hour, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+				{0, Decision{Allowed: true, Remaining: 1, Reset: 20 * time.Second}},
+			},
+		},
+		{
+			name: "clocks going backwards don't refill",
+			steps: []step{
+				{0, Decision{Allowed: true, Remaining: 2, Reset: 10 * time.Second}},
+				{-time.Hour, Decision{Allowed: true, Remaining: 1, Reset: 20 * time.Second}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var clock = newFakeClock()
+			var limiter = New(clock.Now)
+			for index, step := range test.steps {
+				clock.now = clock.now.Add(step.advance)
+				if decision := limiter.Allow("key", budget); decision != step.expected {
+					t.Errorf("step %d decided %+v, rather than %+v", index, decision, step.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowSeparatesKeys(t *testing.T) {
+	var limiter = New(newFakeClock().Now)
+	for i := 0; i < budget.Requests; i++ {
+		limiter.Allow("spent", budget)
+	}
+	if limiter.Allow("spent", budget).Allowed {
+		t.Error("allowed a request beyond the budget")
+	}
+	if !limiter.Allow("other", budget).Allowed {
+		t.Error("refused a request of another key")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	var clock = newFakeClock()
+	var limiter = New(clock.Now)
+	limiter.Allow("once", budget)
+	for i := 0; i < budget.Requests; i++ {
+		limiter.Allow("spent", budget)
+	}
+
+	var tests = []struct {
+		advance time.Duration
+		evicted int
+	}{
+		{9 * time.Second, 0},
+		// one token refills in ten seconds
+		{time.Second, 1},
+		{19 * time.Second, 0},
+		{time.Second, 1},
+		{time.Hour, 0},
+	}
+	for index, test := range tests {
+		clock.now = clock.now.Add(test.advance)
+		if evicted := limiter.Evict(); evicted != test.evicted {
+			t.Errorf("step %d evicted %d buckets, rather than %d", index, evicted, test.evicted)
+		}
+	}
+
+	// evicted buckets start over, full
+	if decision := limiter.Allow("spent", budget); decision.Remaining != budget.Requests-1 {
+		t.Errorf("an evicted bucket was recreated with %d tokens left", decision.Remaining)
+	}
+}
+
+// fakeUsers authenticates any bearer token as the user with the same ID.
+type fakeUsers struct{}
+
+func (fakeUsers) GetUserById(id string) (auth.User, error) {
+	return auth.User{Id: id}, nil
+}
+
+func TestLimit(t *testing.T) {
+	var clock = newFakeClock()
+	var limiter = New(clock.Now)
+	var handler = auth.Auth(fakeUsers{})(limiter.Limit("comments", Budget{Requests: 2, Period: 10 * time.Second})(
+		http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}),
+	))
+	var serve = func(userId string) *httptest.ResponseRecorder {
+		var request = httptest.NewRequest(http.MethodPost, "/comments", nil)
+		request.Header.Set("Authorization", "Bearer "+userId)
+		var recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+	const (
+		firstUser  = "6f1c1c7e-2a4b-4c1e-9d2f-1b1e2c3d4e5f"
+		secondUser = "0b7a3e2d-5c4f-4e3a-8b1c-2d3e4f5a6b7c"
+	)
+
+	var tests = []struct {
+		name       string
+		userId     string
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{"the first request is allowed", firstUser, http.StatusOK, "1", "5", ""},
+		{"the budget is spent", firstUser, http.StatusOK, "0", "10", ""},
+		{"requests beyond the budget are refused", firstUser, http.StatusTooManyRequests, "0", "10", "5"},
+		{"users are limited separately, despite sharing an IP", secondUser, http.StatusOK, "1", "5", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var recorder = serve(test.userId)
+			if recorder.Code != test.status {
+				t.Fatalf("responded %d rather than %d", recorder.Code, test.status)
+			}
+			for header, expected := range map[string]string{
+				"RateLimit-Policy":    "2;w=10",
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": test.remaining,
+				"RateLimit-Reset":     test.reset,
+				"Retry-After":         test.retryAfter,
+			} {
+				if value := recorder.Header().Get(header); value != expected {
+					t.Errorf("the %s header is %q, rather than %q", header, value, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitDisabled(t *testing.T) {
+	var next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	var limited = New(newFakeClock().Now).Limit("uploads", Budget{})(next)
+	var recorder = httptest.NewRecorder()
+	limited.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/artworks", nil))
+	if recorder.Header().Get("RateLimit-Limit") != "" {
+		t.Error("a disabled limit set headers")
+	}
+}
@@ -0,0 +1,201 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy determines which cross-origin requests browsers may send, and which of their responses' headers scripts
+// may read.
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins, as in "https://example.com", wildcard subdomains, as in
+	// "https://*.example.com", which doesn't match the bare domain, or "*", matching any origin
+	AllowedOrigins []string
+
+	// AllowedMethods restricts the methods allowed on routes; when empty, the methods routed on each path are allowed
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed besides the CORS-safelisted ones; "*" allows any
+	AllowedHeaders []string
+
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
+
+var ErrCORSWildcardCredentials = errors.New("the `*` origin can't be allowed along with credentials")
+
+// Validate ensures origins are well formed, and that credentials aren't allowed for any origin, which browsers refuse.
+func (policy CORSPolicy) Validate() error {
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			if policy.AllowCredentials {
+				return ErrCORSWildcardCredentials
+			}
+			continue
+		}
+
+		// wildcards are only allowed as the leftmost label of the host
+		var host = strings.Replace(origin, "://*.", "://wildcard.", 1)
+		parsed, err := url.Parse(host)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || strings.Contains(host, "*") ||
+			strings.TrimSuffix(parsed.Path, "/") != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("the %q origin isn't a scheme and host, as in https://example.com", origin)
+		}
+	}
+	if policy.MaxAge < 0 {
+		return errors.New("the CORS preflight max age can't be negative")
+	}
+	return nil
+}
+
+// cors applies a validated CORS policy, normalised for matching.
+type cors struct {
+	engine  *Engine
+	next    http.Handler
+	policy  CORSPolicy
+	origins []string
+
+	// anyOrigin is set when the policy allows the `*` origin
+	anyOrigin bool
+}
+
+/*
+CORS wraps a handler, usually the engine's own, with a CORS policy, failing when the policy is invalid.
+
+Preflight requests are answered with the methods routed on their path, as listed in the route table, restricted to the
+policy's methods when given. Requests from origins the policy doesn't allow are passed along untouched, so that
+browsers block their responses. Plain OPTIONS requests are left to the engine, which answers them with the methods
+allowed on their path.
+*/
+func (e *Engine) CORS(policy CORSPolicy, next http.Handler) (http.Handler, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS policy: %w", err)
+	}
+
+	var handler = cors{engine: e, next: next, policy: policy}
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			handler.anyOrigin = true
+		} else {
+			handler.origins = append(handler.origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+		}
+	}
+	handler.policy.AllowedHeaders = make([]string, len(policy.AllowedHeaders))
+	for index, header := range policy.AllowedHeaders {
+		handler.policy.AllowedHeaders[index] = http.CanonicalHeaderKey(header)
+	}
+	return handler, nil
+}
+
+// allows reports whether an origin matches the policy, either exactly or as a subdomain of a wildcard origin.
+func (handler cors) allows(origin string) bool {
+	if handler.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range handler.origins {
+		if origin == allowed {
+			return true
+		}
+		if scheme, domain, found := strings.Cut(allowed, "://*."); found {
+			var subdomain = strings.TrimPrefix(origin, scheme+"://")
+			if subdomain != origin && strings.HasSuffix(subdomain, "."+domain) &&
+				len(subdomain) > len(domain)+1 && !strings.Contains(subdomain, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (handler cors) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var origin = request.Header.Get("Origin")
+	var preflight = request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != ""
+
+	// responses vary by origin, unless any is allowed without credentials, when the wildcard is returned
+	var header = writer.Header()
+	var wildcard = handler.anyOrigin && !handler.policy.AllowCredentials
+	if !wildcard {
+		header.Add("Vary", "Origin")
+	}
+	if origin == "" || !handler.allows(origin) {
+		handler.next.ServeHTTP(writer, request)
+		return
+	}
+
+	if wildcard {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if handler.policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(handler.policy.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(handler.policy.ExposedHeaders, ", "))
+		}
+		handler.next.ServeHTTP(writer, request)
+		return
+	}
+
+	// paths lacking routes are left to the engine
+	var methods = handler.methods(request.URL.Path)
+	if len(methods) == 0 {
+		handler.next.ServeHTTP(writer, request)
+		return
+	}
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if headers := handler.headers(request.Header.Get("Access-Control-Request-Headers")); headers != "" {
+		header.Set("Access-Control-Allow-Headers", headers)
+	}
+	if handler.policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(handler.policy.MaxAge.Seconds())))
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// methods lists the methods routed on a path, which the policy allows.
+func (handler cors) methods(path string) []string {
+	var routed = handler.engine.Allowed(path)
+	if len(handler.policy.AllowedMethods) == 0 {
+		return routed
+	}
+	var methods = make([]string, 0, len(routed))
+	for _, method := range routed {
+		for _, allowed := range handler.policy.AllowedMethods {
+			if strings.EqualFold(method, allowed) {
+				methods = append(methods, method)
+				break
+			}
+		}
+	}
+	return methods
+}
+
+// headers lists the requested headers the policy allows; browsers fail preflight requests lacking any of them.
+func (handler cors) headers(requested string) string {
+	var allowed = make([]string, 0)
+	for _, name := range strings.Split(requested, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		for _, header := range handler.policy.AllowedHeaders {
+			if header == "*" || header == name {
+				allowed = append(allowed, name)
+				break
+			}
+		}
+	}
+	return strings.Join(allowed, ", ")
+}
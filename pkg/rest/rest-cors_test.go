@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCORSHandler routes a few methods on artworks, behind the CORS policy, counting the requests reaching handlers.
+func newCORSHandler(t *testing.T, policy CORSPolicy) (*Engine, http.Handler, *int) {
+	t.Helper()
+	var engine = newTestEngine(t)
+	var served int
+	var handler = func(writer http.ResponseWriter, _ *http.Request) {
+		served++
+		writer.WriteHeader(http.StatusOK)
+	}
+	engine.Get("/artworks/:id", handler)
+	engine.Put("/artworks/:id", handler)
+	engine.Delete("/artworks/:id", handler)
+
+	cors, err := engine.CORS(policy, engine.Handler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &engine, cors, &served
+}
+
+// corsHeaders lists the Access-Control headers of a response.
+func corsHeaders(header http.Header) []string {
+	var names = make([]string, 0)
+	for name := range header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestCORSOrigins(t *testing.T) {
+	_, handler, _ := newCORSHandler(t, CORSPolicy{
+		AllowedOrigins: []string{"https://example.com", "https://*.example.org"},
+	})
+
+	var tests = []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"exact origins match", "https://example.com", true},
+		{"exact origins match regardless of case", "https://Example.COM", true},
+		{"exact origins need the same scheme", "http://example.com", false},
+		{"exact origins don't match subdomains", "https://www.example.com", false},
+		{"wildcards match subdomains", "https://cdn.example.org", true},
+		{"wildcards match nested subdomains", "https://eu.cdn.example.org", true},
+		{"wildcards don't match the bare apex", "https://example.org", false},
+		{"wildcards don't match domains sharing a suffix", "https://evil-example.org", false},
+		{"wildcards need the same scheme", "http://cdn.example.org", false},
+		{"origins can't extend allowed ones", "https://example.com.evil.net", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request = httptest.NewRequest(http.MethodGet, "/artworks/42", nil)
+			request.Header.Set("Origin", test.origin)
+			var recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusOK {
+				t.Errorf("responded %d, rather than passing the request along", recorder.Code)
+			}
+			if recorder.Header().Get("Vary") != "Origin" {
+				t.Errorf("responses vary by %q, rather than by origin", recorder.Header().Get("Vary"))
+			}
+			if test.allowed {
+				if allowed := recorder.Header().Get("Access-Control-Allow-Origin"); allowed != test.origin {
+					t.Errorf("allowed the %q origin, rather than %q", allowed, test.origin)
+				}
+			} else if headers := corsHeaders(recorder.Header()); len(headers) > 0 {
+				t.Errorf("set the %v headers for a disallowed origin", headers)
+			}
+		})
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy CORSPolicy
+		valid  bool
+	}{
+		{"exact and wildcard origins", CORSPolicy{AllowedOrigins: []string{"https://a.com", "https://*.b.com"}}, true},
+		{"any origin without credentials", CORSPolicy{AllowedOrigins: []string{"*"}}, true},
+		{"any origin with credentials", CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"origins lacking schemes", CORSPolicy{AllowedOrigins: []string{"example.com"}}, false},
+		{"origins with paths", CORSPolicy{AllowedOrigins: []string{"https://example.com/app"}}, false},
+		{"wildcards beyond the leftmost label", CORSPolicy{AllowedOrigins: []string{"https://a.*.example.com"}}, false},
+		{"partial wildcards", CORSPolicy{AllowedOrigins: []string{"https://*example.com"}}, false},
+		{"negative max ages", CORSPolicy{MaxAge: -time.Second}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var engine = newTestEngine(t)
+			_, err := engine.CORS(test.policy, engine.Handler())
+			if (err == nil) != test.valid {
+				t.Errorf("validation returned %v", err)
+			}
+		})
+	}
+
+	var err = CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate()
+	if !errors.Is(err, ErrCORSWildcardCredentials) {
+		t.Errorf("validation returned %v, rather than ErrCORSWildcardCredentials", err)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	const origin = "https://example.com"
+	var preflight = func(handler http.Handler, origin, headers string) *httptest.ResponseRecorder {
+		var request = httptest.NewRequest(http.MethodOptions, "/artworks/42", nil)
+		request.Header.Set("Origin", origin)
+		request.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		request.Header.Set("Access-Control-Request-Headers", headers)
+		var recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("methods are taken from the route table", func(t *testing.T) {
+		engine, handler, served := newCORSHandler(t, CORSPolicy{
+			AllowedOrigins:   []string{origin},
+			AllowedHeaders:   []string{"authorization", "Content-Type"},
+			MaxAge:           10 * time.Minute,
+			AllowCredentials: true,
+		})
+		var recorder = preflight(handler, origin, "Authorization, X-Custom")
+		if recorder.Code != http.StatusNoContent || *served != 0 {
+			t.Errorf("responded %d and served %d requests, rather than answering with 204", recorder.Code, *served)
+		}
+
+		var routed = strings.Join(engine.Allowed("/artworks/42"), ", ")
+		if routed != "DELETE, GET, OPTIONS, PUT" {
+			t.Errorf("the engine allows %s", routed)
+		}
+		for header, expected := range map[string]string{
+			"Access-Control-Allow-Origin":      origin,
+			"Access-Control-Allow-Methods":     routed,
+			"Access-Control-Allow-Headers":     "Authorization",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+		} {
+			if value := recorder.Header().Get(header); value != expected {
+				t.Errorf("the %s header is %q, rather than %q", header, value, expected)
+			}
+		}
+	})
+
+	t.Run("methods are restricted by the policy", func(t *testing.T) {
+		_, handler, _ := newCORSHandler(t, CORSPolicy{
+			AllowedOrigins: []string{origin},
+			AllowedMethods: []string{"get", "DELETE"},
+		})
+		var recorder = preflight(handler, origin, "")
+		if methods := recorder.Header().Get("Access-Control-Allow-Methods"); methods != "DELETE, GET" {
+			t.Errorf("allowed the %s methods", methods)
+		}
+	})
+
+	t.Run("disallowed origins get no CORS headers", func(t *testing.T) {
+		_, handler, _ := newCORSHandler(t, CORSPolicy{AllowedOrigins: []string{origin}})
+		var recorder = preflight(handler, "https://evil.example", "Authorization")
+		if headers := corsHeaders(recorder.Header()); len(headers) > 0 {
+			t.Errorf("set the %v headers for a disallowed origin", headers)
+		}
+	})
+
+	t.Run("any origin is allowed with the wildcard", func(t *testing.T) {
+		_, handler, _ := newCORSHandler(t, CORSPolicy{AllowedOrigins: []string{"*"}})
+		var recorder = preflight(handler, origin, "")
+		if allowed := recorder.Header().Get("Access-Control-Allow-Origin"); allowed != "*" {
+			t.Errorf("allowed the %q origin, rather than any", allowed)
+		}
+		if vary := recorder.Header().Values("Vary"); len(vary) > 0 && vary[0] == "Origin" {
+			t.Error("responses vary by origin, despite allowing any")
+		}
+	})
+}
+
+func TestCORSExposedHeaders(t *testing.T) {
+	_, handler, served := newCORSHandler(t, CORSPolicy{
+		AllowedOrigins: []string{"https://example.com"},
+		ExposedHeaders: []string{"RateLimit-Remaining", "Retry-After"},
+	})
+	var request = httptest.NewRequest(http.MethodGet, "/artworks/42", nil)
+	request.Header.Set("Origin", "https://example.com")
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if *served != 1 {
+		t.Errorf("served %d requests, rather than 1", *served)
+	}
+	var exposed = recorder.Header().Get("Access-Control-Expose-Headers")
+	if exposed != "RateLimit-Remaining, Retry-After" {
+		t.Errorf("exposed the %q headers", exposed)
+	}
+	if methods := recorder.Header().Get("Access-Control-Allow-Methods"); methods != "" {
+		t.Errorf("listed the %q methods outside preflight requests", methods)
+	}
+}
@@ -1,11 +1,18 @@
 package rest
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/gofrs/uuid"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
+	"net"
 	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
 )
 
 // Config is used to provide dependencies and configuration to the New function.
@@ -22,6 +29,7 @@ func New(cfg Config) (engine Engine, err error) { // assign a logger or fail
 	engine.baseLogger = cfg.Logger
 
 	engine.router = httprouter.New()
+	engine.routes = &[]Route{}
 
 	// disables redirections such as `/foo/` to `/foo`
 	engine.router.RedirectTrailingSlash = false
@@ -29,6 +37,11 @@ func New(cfg Config) (engine Engine, err error) { // assign a logger or fail
 	// disables attempts to fix common path issues and redirects them, i.e. `/FoO` redirects to `/foo`
 	engine.router.RedirectFixedPath = false
 
+	// OPTIONS and 405 responses are derived from the route table by the engine, rather than the router
+	engine.router.HandleOPTIONS = false
+	engine.router.HandleMethodNotAllowed = false
+	engine.router.NotFound = http.HandlerFunc(engine.unrouted)
+
 	return engine, nil
 }
 
@@ -42,6 +55,17 @@ type Engine struct {
 
 	// baseLogger is a logger for non-requests contexts, like goroutines or background tasks not started by a request
 	baseLogger logrus.FieldLogger
+
+	// routes records the registered routes, in order; it's shared by the engine's copies, handed to packages by value
+	routes *[]Route
+}
+
+// Route describes a registered route by its method and path pattern, as in "GET /users/:alias", along with the names
+// of the middleware evaluated before its handler, in order, the engine's global ones included.
+type Route struct {
+	Method     string
+	Path       string
+	Middleware []string
 }
 
 // Handler returns an instance of httprouter.Router that handle APIs registered here
@@ -52,18 +76,98 @@ func (e *Engine) Handler() http.Handler {
 
 // Handle registers the path and method to the given handler. Also applies the middleware to the Handler
 // Handle calls the base router, to register the method, path and handler.
-func (e *Engine) Handle(method string, path string, handler http.Handler, middleware ...func(http.Handler) http.Handler) { // first apply the router's globally defined middleware
-	for _, mw := range e.middleware {
-		handler = mw(handler)
+// Middleware are evaluated in the order they're listed, after the globally defined ones, so that authorisation
+// middleware can rely on preceding authentication ones.
+func (e *Engine) Handle(method string, path string, handler http.Handler, middleware ...func(http.Handler) http.Handler) {
+	// wrap handlers from the innermost middleware outwards, starting with the per-route specific ones
+	for index := len(middleware) - 1; index >= 0; index-- {
+		handler = middleware[index](handler)
 	}
 
-	// then apply the per-route specific middleware
-	for _, mw := range middleware {
-		handler = mw(handler)
+	// then apply the router's globally defined middleware
+	for index := len(e.middleware) - 1; index >= 0; index-- {
+		handler = e.middleware[index](handler)
 	}
 
 	// associate the final composed handler to the selected path and method pair
 	e.router.Handler(method, path, handler)
+
+	var names = make([]string, 0, len(e.middleware)+len(middleware))
+	for _, mw := range append(append([]func(http.Handler) http.Handler{}, e.middleware...), middleware...) {
+		names = append(names, middlewareName(mw))
+	}
+	*e.routes = append(*e.routes, Route{Method: method, Path: path, Middleware: names})
+}
+
+// closureSuffix matches the suffixes the runtime gives to closures' names, as in "RequireRole.func1.2".
+var closureSuffix = regexp.MustCompile(`(\.func\d+)(\.\d+)*$`)
+
+// middlewareName names a middleware after the function returning it, as in "auth.RequireRole", since middleware are
+// mostly closures.
+func middlewareName(middleware func(http.Handler) http.Handler) string {
+	var function = runtime.FuncForPC(reflect.ValueOf(middleware).Pointer())
+	if function == nil {
+		return "unknown"
+	}
+	var name = function.Name()
+	return closureSuffix.ReplaceAllString(name[strings.LastIndex(name, "/")+1:], "")
+}
+
+// Routes lists the routes registered so far, in registration order, static files served by ServeFiles included.
+func (e *Engine) Routes() []Route {
+	var routes = make([]Route, len(*e.routes))
+	copy(routes, *e.routes)
+	return routes
+}
+
+// Allowed lists the methods whose routes match a request path, along with OPTIONS, sorted; it's empty when none do.
+func (e *Engine) Allowed(path string) []string {
+	var methods = make(map[string]bool)
+	for _, route := range *e.routes {
+		if methods[route.Method] {
+			continue
+		}
+		if handle, _, _ := e.router.Lookup(route.Method, path); handle != nil {
+			methods[route.Method] = true
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	methods[http.MethodOptions] = true
+	return sortedMethods(methods)
+}
+
+func sortedMethods(methods map[string]bool) []string {
+	var sorted = make([]string, 0, len(methods))
+	for method := range methods {
+		sorted = append(sorted, method)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// unrouted handles the requests lacking a route for their method: OPTIONS ones are answered with the methods allowed
+// on their path, others with 405 when the path is routed for other methods, or 404.
+func (e *Engine) unrouted(writer http.ResponseWriter, request *http.Request) {
+	var allowed = e.Allowed(request.URL.Path)
+	if len(allowed) == 0 {
+		http.NotFound(writer, request)
+		return
+	}
+
+	writer.Header().Set("Allow", strings.Join(allowed, ", "))
+	if request.Method == http.MethodOptions {
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Error(writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// ServeRoutes lists the registered routes in a JSON response, for debugging purposes.
+func (e *Engine) ServeRoutes(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(e.Routes())
 }
 
 // Use specifies one or multiple new handlers that will be evaluated for every specified route (ie. logger).
@@ -89,9 +193,18 @@ func (e *Engine) Delete(path string, handlerFunc http.HandlerFunc, middleware ..
 	e.Handle(http.MethodDelete, path, handlerFunc, middleware...)
 }
 
-// ServeFiles is a mere wrapper around Router's ServeFiles()
+func (e *Engine) Patch(path string, handlerFunc http.HandlerFunc, middleware ...func(http.Handler) http.Handler) {
+	e.Handle(http.MethodPatch, path, handlerFunc, middleware...)
+}
+
+func (e *Engine) Head(path string, handlerFunc http.HandlerFunc, middleware ...func(http.Handler) http.Handler) {
+	e.Handle(http.MethodHead, path, handlerFunc, middleware...)
+}
+
+// ServeFiles is a mere wrapper around Router's ServeFiles(), recording the GET route it registers.
 func (e *Engine) ServeFiles(path string, root http.FileSystem) {
 	e.router.ServeFiles(path, root)
+	*e.routes = append(*e.routes, Route{Method: http.MethodGet, Path: path, Middleware: []string{}})
 }
 
 // helper functions
@@ -100,6 +213,15 @@ func GetParam(request *http.Request, key string) string {
 	return httprouter.ParamsFromContext(request.Context()).ByName(key)
 }
 
+// ClientIP returns the host of the request's remote address. Forwarding headers are ignored, as they can be forged
+// unless set by a trusted proxy.
+func ClientIP(request *http.Request) string {
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		return host
+	}
+	return request.RemoteAddr
+}
+
 // MustGetNewUUID generates a new unique ID string, to be used as a DB key.
 // SQLite has limited ability in this regard, while Postgresql and others have adequate features or extensions.
 func MustGetNewUUID() string {
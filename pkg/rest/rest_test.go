@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"context"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newTestEngine builds an engine whose logs are discarded.
+func newTestEngine(t *testing.T) Engine {
+	t.Helper()
+	var logger, _ = test.NewNullLogger()
+	engine, err := New(Config{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// tracing returns a middleware appending its name to the trace, before passing requests along.
+func tracing(trace *[]string, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			*trace = append(*trace, name)
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func TestHandleMiddlewareOrder(t *testing.T) {
+	var engine = newTestEngine(t)
+	var trace []string
+	engine.Use(tracing(&trace, "global 1"), tracing(&trace, "global 2"))
+	engine.Get("/ordered", func(writer http.ResponseWriter, request *http.Request) {
+		trace = append(trace, "handler")
+	}, tracing(&trace, "route 1"), tracing(&trace, "route 2"))
+
+	engine.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ordered", nil))
+	var expected = []string{"global 1", "global 2", "route 1", "route 2", "handler"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("evaluated %v, rather than %v", trace, expected)
+	}
+
+	// the route table lists the middleware in the same order
+	var routes = engine.Routes()
+	if len(routes) != 1 || len(routes[0].Middleware) != 4 {
+		t.Fatalf("recorded routes %+v", routes)
+	}
+	for _, name := range routes[0].Middleware {
+		if name != "rest.tracing" {
+			t.Errorf("recorded the %q middleware, rather than rest.tracing", name)
+		}
+	}
+}
+
+func TestHandleStopsAtRejectingMiddleware(t *testing.T) {
+	var engine = newTestEngine(t)
+	var trace []string
+	var reject = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			trace = append(trace, "reject")
+			writer.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	engine.Use(tracing(&trace, "global"))
+	engine.Get("/rejected", func(writer http.ResponseWriter, request *http.Request) {
+		trace = append(trace, "handler")
+	}, reject, tracing(&trace, "route"))
+
+	var recorder = httptest.NewRecorder()
+	engine.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/rejected", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("responded %d rather than 401", recorder.Code)
+	}
+	if expected := []string{"global", "reject"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("evaluated %v, rather than %v", trace, expected)
+	}
+}
+
+type contextKey struct{}
+
+// authenticating stores a user in requests' contexts, as authentication middleware do.
+func authenticating(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		next.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), contextKey{}, "user")))
+	})
+}
+
+// authorising refuses requests whose contexts lack a user, as authorisation middleware do.
+func authorising(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Context().Value(contextKey{}) == nil {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func TestRouteMiddlewareFollowGlobalOnes(t *testing.T) {
+	var engine = newTestEngine(t)
+	engine.Use(authenticating)
+	engine.Get("/authorised", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	}, authorising)
+
+	// route middleware wrapping the global ones would find no user
+	var recorder = httptest.NewRecorder()
+	engine.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/authorised", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("responded %d, rather than 204", recorder.Code)
+	}
+}
+
+// newRoutedEngine routes "/items/:id" for GET, DELETE and PATCH, and "/items" for HEAD, each handler writing its
+// method in the X-Method header.
+func newRoutedEngine(t *testing.T) Engine {
+	t.Helper()
+	var engine = newTestEngine(t)
+	var handler = func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("X-Method", request.Method)
+		writer.WriteHeader(http.StatusOK)
+	}
+	engine.Use(authenticating)
+	engine.Get("/items/:id", handler, authorising)
+	engine.Delete("/items/:id", handler)
+	engine.Patch("/items/:id", handler)
+	engine.Head("/items", handler)
+	return engine
+}
+
+func TestUnroutedMethods(t *testing.T) {
+	var engine = newRoutedEngine(t)
+	var tests = []struct {
+		name    string
+		method  string
+		path    string
+		status  int
+		allowed string
+		handled string
+	}{
+		{"routed methods are handled", http.MethodPatch, "/items/1", http.StatusOK, "", http.MethodPatch},
+		{"HEAD routes are handled", http.MethodHead, "/items", http.StatusOK, "", http.MethodHead},
+		{"other methods aren't allowed", http.MethodPost, "/items/1", http.StatusMethodNotAllowed,
+			"DELETE, GET, OPTIONS, PATCH", ""},
+		{"OPTIONS lists the allowed methods", http.MethodOptions, "/items/1", http.StatusNoContent,
+			"DELETE, GET, OPTIONS, PATCH", ""},
+		{"OPTIONS lists HEAD routes", http.MethodOptions, "/items", http.StatusNoContent, "HEAD, OPTIONS", ""},
+		{"unrouted paths aren't found", http.MethodGet, "/missing", http.StatusNotFound, "", ""},
+		{"OPTIONS of unrouted paths aren't found", http.MethodOptions, "/missing", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var recorder = httptest.NewRecorder()
+			engine.Handler().ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+			if recorder.Code != test.status {
+				t.Errorf("responded %d, rather than %d", recorder.Code, test.status)
+			}
+			if allowed := recorder.Header().Get("Allow"); allowed != test.allowed {
+				t.Errorf("allowed %q, rather than %q", allowed, test.allowed)
+			}
+			if handled := recorder.Header().Get("X-Method"); handled != test.handled {
+				t.Errorf("handled %q, rather than %q", handled, test.handled)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	var engine = newRoutedEngine(t)
+	engine.ServeFiles("/static/*filepath", http.Dir(t.TempDir()))
+
+	// packages receive copies of the engine, whose routes are recorded all the same
+	var copied = engine
+	copied.Post("/items", func(writer http.ResponseWriter, request *http.Request) {})
+
+	var expected = []Route{
+		{Method: http.MethodGet, Path: "/items/:id", Middleware: []string{"rest.authenticating", "rest.authorising"}},
+		{Method: http.MethodDelete, Path: "/items/:id", Middleware: []string{"rest.authenticating"}},
+		{Method: http.MethodPatch, Path: "/items/:id", Middleware: []string{"rest.authenticating"}},
+		{Method: http.MethodHead, Path: "/items", Middleware: []string{"rest.authenticating"}},
+		{Method: http.MethodGet, Path: "/static/*filepath", Middleware: []string{}},
+		{Method: http.MethodPost, Path: "/items", Middleware: []string{"rest.authenticating"}},
+	}
+	var routes = engine.Routes()
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("listed %+v, rather than %+v", routes, expected)
+	}
+
+	// listed routes are copies
+	routes[0].Path = "/changed"
+	if engine.Routes()[0].Path != "/items/:id" {
+		t.Error("changed the routes' table through its listing")
+	}
+
+	if allowed := engine.Allowed("/items"); !reflect.DeepEqual(allowed, []string{"HEAD", "OPTIONS", "POST"}) {
+		t.Errorf("allowed %v on /items", allowed)
+	}
+	if allowed := engine.Allowed("/missing"); allowed != nil {
+		t.Errorf("allowed %v on an unrouted path", allowed)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package images
+
+import "math"
+
+// FreeBytes can't inspect volumes on non-UNIX systems, hence free space is reported as unlimited.
+func (storage Storage) FreeBytes() (uint64, error) {
+	return math.MaxUint64, nil
+}
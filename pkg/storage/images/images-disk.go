@@ -0,0 +1,14 @@
+//go:build unix
+
+package images
+
+import "syscall"
+
+// FreeBytes returns the bytes available to unprivileged users on the images' volume.
+func (storage Storage) FreeBytes() (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(storage.Path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -3,11 +3,15 @@ package images
 import (
 	"github.com/sirupsen/logrus"
 	"os"
+	"path/filepath"
 )
 
 type Storage struct {
 	Logger *logrus.Logger
 	Path   string
+
+	// StagingPath holds the chunks of resumable uploads, until they're complete
+	StagingPath string
 }
 
 func New(logger *logrus.Logger, path string) (storage Storage, err error) {
@@ -22,5 +26,10 @@ func New(logger *logrus.Logger, path string) (storage Storage, err error) {
 	// the path has been validated; remember to check for permissions tk
 	storage.Path = path
 
+	storage.StagingPath = filepath.Join(path, "staging")
+	if err = os.MkdirAll(storage.StagingPath, 0750); err != nil {
+		return storage, err
+	}
+
 	return storage, nil
 }
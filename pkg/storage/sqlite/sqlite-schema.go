@@ -11,6 +11,10 @@ CREATE TABLE
 		salt TEXT,
 		created datetime NOT NULL,
 		updated datetime NOT NULL,
+		deleted datetime,
+		private INTEGER NOT NULL DEFAULT 0 CHECK (private IN (0, 1)),
+		role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+		suspended datetime,
 		PRIMARY KEY ("id")
 	);
 
@@ -30,9 +34,36 @@ CREATE TABLE
 		added datetime NOT NULL,
 		updated datetime NOT NULL,
 		deleted INTEGER DEFAULT 0 CHECK (deleted in (0, 1)),
+		visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'followers', 'unlisted', 'private')),
+		size INTEGER NOT NULL DEFAULT 0,
+		cover TEXT NOT NULL,
 		FOREIGN KEY (author_id) REFERENCES users (id)
 	);
 
+-- artworks own an ordered list of images, named after their hashes, whose first one is the artworks' cover
+CREATE TABLE
+	IF NOT EXISTS artwork_images (
+		id TEXT NOT NULL PRIMARY KEY,
+		artwork TEXT NOT NULL,
+		position INTEGER NOT NULL CHECK (position >= 0),
+		format TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		added datetime NOT NULL,
+		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE
+	);
+
+CREATE INDEX IF NOT EXISTS idx_artwork_images_artwork ON artwork_images (artwork, position);
+
+-- released aliases stay reserved to their former owners for a cooldown, during which they redirect to current ones
+CREATE TABLE
+	IF NOT EXISTS alias_history (
+		alias TEXT NOT NULL,
+		user TEXT NOT NULL,
+		released datetime NOT NULL,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT alias_user_pk PRIMARY KEY (alias, user)
+	);
+
 CREATE TABLE
 	IF NOT EXISTS followers (
 		follower TEXT NOT NULL,
@@ -43,6 +74,17 @@ CREATE TABLE
 		CONSTRAINT follower_target_pk PRIMARY KEY (follower, target)
 	);
 
+-- follows of private accounts are pending until their targets approve them
+CREATE TABLE
+	IF NOT EXISTS follow_requests (
+		requester TEXT NOT NULL,
+		target TEXT NOT NULL,
+		date	datetime NOT NULL,
+		CONSTRAINT requester_fk FOREIGN KEY (requester) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT target_fk FOREIGN KEY (target) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT requester_target_pk PRIMARY KEY (requester, target)
+	);
+
 CREATE TABLE
 	IF NOT EXISTS bans (
 		source TEXT NOT NULL,
@@ -53,6 +95,30 @@ CREATE TABLE
 		CONSTRAINT source_target_pk PRIMARY KEY (source, target)
 	);
 
+-- mutes only affect what their sources see; a null expiry makes them last until lifted
+CREATE TABLE
+	IF NOT EXISTS mutes (
+		source TEXT NOT NULL,
+		target TEXT NOT NULL,
+		date	datetime NOT NULL,
+		expires datetime,
+		CONSTRAINT source_fk FOREIGN KEY (source) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT target_fk FOREIGN KEY (target) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT source_target_pk PRIMARY KEY (source, target)
+	);
+
+-- reaction kinds can be extended by inserting rows; icons are paths to files served under /static
+CREATE TABLE
+	IF NOT EXISTS reaction_types (
+		name TEXT NOT NULL PRIMARY KEY,
+		icon TEXT NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0
+	);
+
+INSERT OR IGNORE INTO reaction_types (name, icon, position) VALUES
+	('Like', '/static/thumb_up.png', 0),
+	('Perplexed', '/static/monocle.png', 1);
+
 CREATE TABLE
 	IF NOT EXISTS artwork_feedback (
 		artwork TEXT NOT NULL,
@@ -61,6 +127,7 @@ CREATE TABLE
 		date	datetime NOT NULL,
 		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
 		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT reaction_fk FOREIGN KEY (reaction) REFERENCES reaction_types (name) ON UPDATE CASCADE,
 		CONSTRAINT artwork_user_pk PRIMARY KEY (artwork, user)
 	);
 
@@ -71,12 +138,236 @@ CREATE TABLE
 		user TEXT NOT NULL,
 		comment TEXT NOT NULL,
 		date	datetime NOT NULL,
+		edited datetime,
+		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+-- mentions locate the users addressed by comments as '@alias', counting characters rather than bytes; they refer to
+-- users rather than aliases, so that they survive alias changes
+CREATE TABLE
+	IF NOT EXISTS comment_mentions (
+		comment TEXT NOT NULL,
+		user TEXT NOT NULL,
+		start INTEGER NOT NULL CHECK (start >= 0),
+		length INTEGER NOT NULL CHECK (length > 0),
+		CONSTRAINT comment_fk FOREIGN KEY (comment) REFERENCES artwork_comments (id) ON DELETE CASCADE,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT comment_start_pk PRIMARY KEY (comment, start)
+	);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user ON comment_mentions (user);
+
+CREATE TABLE
+	IF NOT EXISTS description_mentions (
+		artwork TEXT NOT NULL,
+		user TEXT NOT NULL,
+		start INTEGER NOT NULL CHECK (start >= 0),
+		length INTEGER NOT NULL CHECK (length > 0),
+		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT artwork_start_pk PRIMARY KEY (artwork, start)
+	);
+
+CREATE INDEX IF NOT EXISTS idx_description_mentions_user ON description_mentions (user);
+
+-- notifications tell users about others' actions concerning them; mentions are notified once per comment, or per
+-- artwork description when comments are null, edits notwithstanding
+CREATE TABLE
+	IF NOT EXISTS notifications (
+		id TEXT NOT NULL PRIMARY KEY,
+		user TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		kind TEXT NOT NULL CHECK (kind IN ('mention')),
+		artwork TEXT NOT NULL,
+		comment TEXT,
+		date datetime NOT NULL,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT actor_fk FOREIGN KEY (actor) REFERENCES users (id) ON DELETE CASCADE,
+		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
+		CONSTRAINT comment_fk FOREIGN KEY (comment) REFERENCES artwork_comments (id) ON DELETE CASCADE,
+		CONSTRAINT user_kind_comment_unique UNIQUE (user, kind, comment)
+	);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user, date);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_description ON notifications (user, kind, artwork)
+WHERE comment IS NULL;
+
+CREATE TABLE
+	IF NOT EXISTS data_exports (
+		id TEXT NOT NULL PRIMARY KEY,
+		user TEXT NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('pending', 'ready', 'failed')),
+		requested datetime NOT NULL,
+		completed datetime,
+		expires datetime,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+-- reports point to artworks, comments or users, hence the lack of a foreign key on their subjects
+CREATE TABLE
+	IF NOT EXISTS reports (
+		id TEXT NOT NULL PRIMARY KEY,
+		reporter TEXT NOT NULL,
+		subject_type TEXT NOT NULL CHECK (subject_type IN ('artwork', 'comment', 'user')),
+		subject TEXT NOT NULL,
+		reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'nudity', 'violence', 'copyright', 'impersonation', 'other')),
+		details TEXT,
+		status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+		created datetime NOT NULL,
+		closed datetime,
+		CONSTRAINT reporter_fk FOREIGN KEY (reporter) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+-- a reporter can't file more than one open report on the same subject
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open ON reports (reporter, subject_type, subject) WHERE status = 'open';
+
+-- the audit trail of moderation actions outlives the administrators who took them
+CREATE TABLE
+	IF NOT EXISTS moderation_actions (
+		id TEXT NOT NULL PRIMARY KEY,
+		admin TEXT,
+		action TEXT NOT NULL CHECK (action IN ('take-down-artwork', 'take-down-comment', 'suspend-user', 'reinstate-user', 'dismiss-report')),
+		subject TEXT NOT NULL,
+		report TEXT,
+		note TEXT,
+		snapshot TEXT,
+		date datetime NOT NULL,
+		CONSTRAINT admin_fk FOREIGN KEY (admin) REFERENCES users (id) ON DELETE SET NULL,
+		CONSTRAINT report_fk FOREIGN KEY (report) REFERENCES reports (id) ON DELETE SET NULL
+	);
+
+-- users' storage usage, counting the bytes and number of the artworks that weren't deleted
+CREATE TABLE
+	IF NOT EXISTS storage_usage (
+		user TEXT NOT NULL PRIMARY KEY,
+		bytes INTEGER NOT NULL DEFAULT 0,
+		artworks INTEGER NOT NULL DEFAULT 0,
+		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+-- failed logins are tracked per alias and per client IP, whether or not the alias exists
+CREATE TABLE
+	IF NOT EXISTS login_failures (
+		kind TEXT NOT NULL CHECK (kind IN ('alias', 'ip')),
+		subject TEXT NOT NULL,
+		failures INTEGER NOT NULL,
+		last_failure datetime NOT NULL,
+		locked_until datetime,
+		PRIMARY KEY (kind, subject)
+	);
+
+-- tags are normalised names, shared by the artworks they're attached to either by their authors or by hashtags
+-- written in their descriptions; both sources are tracked, so that tags are kept until neither attaches them
+CREATE TABLE
+	IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE
+	);
+
+CREATE TABLE
+	IF NOT EXISTS artwork_tags (
+		artwork TEXT NOT NULL,
+		tag INTEGER NOT NULL,
+		by_author BOOLEAN NOT NULL DEFAULT FALSE,
+		in_description BOOLEAN NOT NULL DEFAULT FALSE,
+		added datetime NOT NULL,
+		CHECK (by_author OR in_description),
 		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
+		CONSTRAINT tag_fk FOREIGN KEY (tag) REFERENCES tags (id) ON DELETE CASCADE,
+		CONSTRAINT artwork_tag_pk PRIMARY KEY (artwork, tag)
+	);
+
+CREATE INDEX IF NOT EXISTS idx_artwork_tags_tag ON artwork_tags (tag, added);
+
+-- collections are ordered lists of artworks, curated by users, whose entries are dropped along with their artworks
+CREATE TABLE
+	IF NOT EXISTS collections (
+		id TEXT NOT NULL PRIMARY KEY,
+		owner TEXT NOT NULL,
+		name TEXT NOT NULL CHECK (length (name) BETWEEN 1 AND 100),
+		description TEXT,
+		visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'private')),
+		created datetime NOT NULL,
+		updated datetime NOT NULL,
+		CONSTRAINT owner_fk FOREIGN KEY (owner) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+CREATE INDEX IF NOT EXISTS idx_collections_owner ON collections (owner);
+
+CREATE TABLE
+	IF NOT EXISTS collection_entries (
+		collection TEXT NOT NULL,
+		artwork TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		added datetime NOT NULL,
+		CONSTRAINT collection_fk FOREIGN KEY (collection) REFERENCES collections (id) ON DELETE CASCADE,
+		CONSTRAINT artwork_fk FOREIGN KEY (artwork) REFERENCES artworks (id) ON DELETE CASCADE,
+		CONSTRAINT collection_artwork_pk PRIMARY KEY (collection, artwork)
+	);
+
+-- resumable uploads track the bytes received so far, while their chunks are appended to a staging file
+CREATE TABLE
+	IF NOT EXISTS upload_sessions (
+		id TEXT NOT NULL PRIMARY KEY,
+		user TEXT NOT NULL,
+		size INTEGER NOT NULL CHECK (size > 0),
+		offset INTEGER NOT NULL DEFAULT 0 CHECK (offset >= 0 AND offset <= size),
+		visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'followers', 'unlisted', 'private')),
+		created datetime NOT NULL,
+		updated datetime NOT NULL,
+		expires datetime NOT NULL,
 		CONSTRAINT user_fk FOREIGN KEY (user) REFERENCES users (id) ON DELETE CASCADE
 	);
 
 CREATE VIEW IF NOT EXISTS deleted_artworks AS SELECT id FROM artworks WHERE deleted;
 
+-- storage usage follows artworks' uploads, soft deletions and purges, whatever the code path
+CREATE TRIGGER IF NOT EXISTS add_storage_usage
+AFTER INSERT ON artworks
+FOR EACH ROW WHEN NOT NEW.deleted
+BEGIN
+  INSERT INTO storage_usage (user, bytes, artworks) VALUES (NEW.author_id, NEW.size, 1)
+  ON CONFLICT (user) DO UPDATE SET bytes = bytes + excluded.bytes, artworks = artworks + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS remove_storage_usage
+AFTER DELETE ON artworks
+FOR EACH ROW WHEN NOT OLD.deleted
+BEGIN
+  UPDATE storage_usage SET bytes = bytes - OLD.size, artworks = artworks - 1 WHERE user = OLD.author_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_storage_usage
+AFTER UPDATE OF deleted ON artworks
+FOR EACH ROW WHEN OLD.deleted != NEW.deleted
+BEGIN
+  INSERT INTO storage_usage (user, bytes, artworks)
+  VALUES (NEW.author_id, iif(NEW.deleted, -NEW.size, NEW.size), iif(NEW.deleted, -1, 1))
+  ON CONFLICT (user) DO UPDATE SET bytes = bytes + excluded.bytes, artworks = artworks + excluded.artworks;
+END;
+
+-- artworks' sizes add up their images' ones, and in turn update their authors' storage usage
+CREATE TRIGGER IF NOT EXISTS add_artwork_image_size
+AFTER INSERT ON artwork_images
+BEGIN
+  UPDATE artworks SET size = size + NEW.size WHERE id = NEW.artwork;
+END;
+
+CREATE TRIGGER IF NOT EXISTS remove_artwork_image_size
+AFTER DELETE ON artwork_images
+BEGIN
+  UPDATE artworks SET size = size - OLD.size WHERE id = OLD.artwork;
+END;
+
+CREATE TRIGGER IF NOT EXISTS resize_storage_usage
+AFTER UPDATE OF size ON artworks
+FOR EACH ROW WHEN NOT NEW.deleted
+BEGIN
+  UPDATE storage_usage SET bytes = bytes + NEW.size - OLD.size WHERE user = NEW.author_id;
+END;
+
 -- the BEFORE clause should prevent recursive triggers
 -- there's a possible issue with date time formatting
 CREATE TRIGGER IF NOT EXISTS set_user_timestamp
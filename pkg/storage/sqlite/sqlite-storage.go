@@ -47,6 +47,25 @@ func New(logger *logrus.Logger, path string) (storage Storage, err error) {
 	return storage, storage.Connection.Ping()
 }
 
+// NewInMemory sets up a transient database with the designed schema, for checks which needn't persist data.
+// Each connection to ":memory:" opens a distinct database, hence the pool is limited to a single one.
+func NewInMemory(logger *logrus.Logger) (storage Storage, err error) {
+	storage.Logger = logger
+	if storage.Connection, err = sql.Open("sqlite3", getConnectionString(":memory:")); err != nil {
+		return storage, err
+	}
+	storage.Connection.SetMaxOpenConns(1)
+
+	// the only connection must outlive idle periods, or the database would be lost along with it
+	storage.Connection.SetConnMaxLifetime(0)
+	storage.Connection.SetConnMaxIdleTime(0)
+
+	if _, err = storage.Connection.Exec(schema); err != nil {
+		return storage, err
+	}
+	return storage, nil
+}
+
 func getValidConnection(path string) (connection *sql.DB, err error) {
 	if connection, err = sql.Open("sqlite3", getConnectionString(path)); err != nil {
 		return nil, err
@@ -33,13 +33,7 @@ func getFollowers(ur UserRepository) http.HandlerFunc {
 // followUser handles the POST "/users/:alias/followed" route
 func followUser(ur UserRepository) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// ensure that the follower's alias matches the authenticated user's
 		var follower = auth.MustGetUser(request)
-		if follower.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// validate target's alias
 		data, err := JSON.DecodeValidate[FollowUserData](request)
 		if err != nil {
@@ -58,16 +52,26 @@ func followUser(ur UserRepository) http.HandlerFunc {
 		// - the follower already follows the target (ErrDupFollower)
 		// - no user matches the target alias (ErrNotFound)
 		// - the target is banning the requester (a debatable ErrNotFound)
-		if err = ur.Follow(follower.Id, data.TargetAlias, date); err == nil {
+		// private targets receive a follow request instead, pending their approval
+		pending, err := ur.Follow(follower.Id, data.TargetAlias, date)
+		switch {
+		case err == nil && pending:
+			JSON.Accepted(writer, struct {
+				Alias     string
+				Requested ntime.NTime
+			}{data.TargetAlias, date})
+		case err == nil:
 			JSON.Created(writer, struct {
 				Alias    string
 				Followed ntime.NTime
 			}{data.TargetAlias, date})
-		} else if errors.Is(err, ErrDupFollower) {
+		case errors.Is(err, ErrDupFollower):
 			JSON.BadRequestWithMessage(writer, fmt.Sprintf("You are already following user %s", data.TargetAlias))
-		} else if errors.Is(err, ErrNotFound) {
+		case errors.Is(err, ErrDupRequest):
+			JSON.BadRequestWithMessage(writer, fmt.Sprintf("You already requested to follow user %s", data.TargetAlias))
+		case errors.Is(err, ErrNotFound):
 			JSON.NotFound(writer, fmt.Sprintf("User %s not found", data.TargetAlias))
-		} else {
+		default:
 			JSON.InternalServerError(writer, err)
 		}
 	}
@@ -76,13 +80,7 @@ func followUser(ur UserRepository) http.HandlerFunc {
 // unfollowUser handles the DELETE "/users/:alias/followed/:target" route
 func unfollowUser(ur UserRepository) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// ensure that the follower's alias matches the authenticated user's
 		var follower = auth.MustGetUser(request)
-		if follower.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// attempt to sanitise target alias before queries
 		var targetAlias = rest.GetParam(request, "target")
 		if err := ValidateUserAlias(targetAlias); err != nil {
@@ -109,13 +107,7 @@ func unfollowUser(ur UserRepository) http.HandlerFunc {
 // banUser handles the POST "/users/:alias/bans" route
 func banUser(ur UserRepository) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// ensure that the banning user matches the authenticated one
 		var source = auth.MustGetUser(request)
-		if source.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// validate target user alias
 		data, err := JSON.DecodeValidate[BanUserData](request)
 		if err != nil {
@@ -147,13 +139,7 @@ func banUser(ur UserRepository) http.HandlerFunc {
 // unbanUser handles the DELETE "/users/:alias/bans/:target" route
 func unbanUser(ur UserRepository) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// ensure that the user taking action is authorised
 		var source = auth.MustGetUser(request)
-		if source.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		// attempt to sanitise target alias before queries
 		var targetAlias = rest.GetParam(request, "target")
 		if err := ValidateUserAlias(targetAlias); err != nil {
@@ -180,18 +166,149 @@ func unbanUser(ur UserRepository) http.HandlerFunc {
 // getBans handles the GET "/users/:alias/bans" route
 func getBans(ur UserRepository) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		// check whether the user has legitimate access to the route
 		var user = auth.MustGetUser(request)
-		if user.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
+		if banned, err := ur.GetBans(user.Id); err == nil {
+			JSON.Ok(writer, banned)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+
+	}
+}
+
+// muteUser handles the POST "/users/:alias/mutes" route
+func muteUser(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var source = auth.MustGetUser(request)
+		data, err := JSON.DecodeValidate[MuteUserData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
 			return
 		}
 
-		if banned, err := ur.GetBans(user.Id); err == nil {
-			JSON.Ok(writer, banned)
+		if source.Alias == data.TargetAlias {
+			JSON.BadRequestWithMessage(writer, "Can't mute oneself")
+			return
+		}
+
+		// mutes without expiry last until lifted
+		var date, expires = ntime.Now(), ntime.NTime{}
+		if data.Expires != nil {
+			expires = *data.Expires
+		}
+
+		if err = ur.Mute(source.Id, data.TargetAlias, date, expires); err == nil {
+			JSON.Created(writer, struct {
+				Alias   string
+				Muted   ntime.NTime
+				Expires ntime.NTime
+			}{data.TargetAlias, date, expires})
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, fmt.Sprintf("User %s not found", data.TargetAlias))
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// unmuteUser handles the DELETE "/users/:alias/mutes/:target" route
+func unmuteUser(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var source = auth.MustGetUser(request)
+		var targetAlias = rest.GetParam(request, "target")
+		if err := ValidateUserAlias(targetAlias); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if err := ur.Unmute(source.Id, targetAlias); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, fmt.Sprintf("User %s isn't muted", targetAlias))
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getMutes handles the GET "/users/:alias/mutes" route
+func getMutes(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		if muted, err := ur.GetMutes(user.Id); err == nil {
+			JSON.Ok(writer, muted)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// setPrivate handles the PUT "/users/:alias/private" route, toggling whether follows require the user's approval
+func setPrivate(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		data, err := JSON.DecodeValidate[UpdatePrivacyData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if err = ur.SetPrivate(user.Id, *data.Private, ntime.Now()); err == nil {
+			JSON.NoContent(writer)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// getFollowRequests handles the GET "/users/:alias/follow-requests" route, listing pending follows
+func getFollowRequests(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		if requests, err := ur.GetFollowRequests(user.Id); err == nil {
+			JSON.Ok(writer, requests)
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// approveFollowRequest handles the PUT "/users/:alias/follow-requests/:requester" route
+func approveFollowRequest(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		var requesterAlias = rest.GetParam(request, "requester")
+		if err := ValidateUserAlias(requesterAlias); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		if err := ur.ApproveFollowRequest(user.Id, requesterAlias, ntime.Now()); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, fmt.Sprintf("User %s didn't request to follow you", requesterAlias))
 		} else {
 			JSON.InternalServerError(writer, err)
 		}
+	}
+}
+
+// rejectFollowRequest handles the DELETE "/users/:alias/follow-requests/:requester" route
+func rejectFollowRequest(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		var requesterAlias = rest.GetParam(request, "requester")
+		if err := ValidateUserAlias(requesterAlias); err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
 
+		if err := ur.RejectFollowRequest(user.Id, requesterAlias); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, fmt.Sprintf("User %s didn't request to follow you", requesterAlias))
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
 	}
 }
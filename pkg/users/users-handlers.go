@@ -4,32 +4,63 @@ import (
 	"errors"
 	"github.com/silktrader/kvasari/pkg/auth"
 	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"github.com/silktrader/kvasari/pkg/ratelimit"
 	"github.com/silktrader/kvasari/pkg/rest"
 	"net/http"
+	"time"
 )
 
-func RegisterHandlers(engine rest.Engine, ur UserRepository, ar auth.IRepository) {
+// Options carries the users' handlers settings, sourced from the web API configuration.
+type Options struct {
+	AliasLockout LockoutPolicy
+	IPLockout    LockoutPolicy
+
+	// Limiter enforces the registrations' budget, per client IP
+	Limiter       *ratelimit.Limiter
+	Registrations ratelimit.Budget
+}
+
+func RegisterHandlers(engine rest.Engine, ur UserRepository, ar auth.IRepository, options Options) {
 
 	var authenticated = auth.Auth(ar)
+	var self = auth.RequireSelf("alias")
 
-	engine.Post("/sessions", login(ur))
+	// routes addressing other users by alias redirect former aliases to the current ones
+	var canonical = CanonicalAlias(ur)
+
+	engine.Post("/sessions", login(ur, options))
 	engine.Get("/users", getUsers(ur), authenticated)
-	engine.Post("/users", registerUser(ur))
+	engine.Post("/users", registerUser(ur), options.Limiter.Limit("registrations", options.Registrations))
 
 	// followers
-	engine.Get("/users/:alias/followers", getFollowers(ur))
-	engine.Post("/users/:alias/followed", followUser(ur), authenticated)
-	engine.Delete("/users/:alias/followed/:target", unfollowUser(ur), authenticated)
+	engine.Get("/users/:alias/followers", getFollowers(ur), canonical)
+	engine.Post("/users/:alias/followed", followUser(ur), authenticated, self)
+	engine.Delete("/users/:alias/followed/:target", unfollowUser(ur), authenticated, self)
+
+	// private accounts and follow requests
+	engine.Put("/users/:alias/private", setPrivate(ur), authenticated, self)
+	engine.Get("/users/:alias/follow-requests", getFollowRequests(ur), authenticated, self)
+	engine.Put("/users/:alias/follow-requests/:requester", approveFollowRequest(ur), authenticated, self)
+	engine.Delete("/users/:alias/follow-requests/:requester", rejectFollowRequest(ur), authenticated, self)
 
 	// bans
-	engine.Get("/users/:alias/bans", getBans(ur), authenticated)
-	engine.Post("/users/:alias/bans", banUser(ur), authenticated)
-	engine.Delete("/users/:alias/bans/:target", unbanUser(ur), authenticated)
+	engine.Get("/users/:alias/bans", getBans(ur), authenticated, self)
+	engine.Post("/users/:alias/bans", banUser(ur), authenticated, self)
+	engine.Delete("/users/:alias/bans/:target", unbanUser(ur), authenticated, self)
+
+	// mutes
+	engine.Get("/users/:alias/mutes", getMutes(ur), authenticated, self)
+	engine.Post("/users/:alias/mutes", muteUser(ur), authenticated, self)
+	engine.Delete("/users/:alias/mutes/:target", unmuteUser(ur), authenticated, self)
 
 	// user details
-	engine.Get("/users/:alias", getDetails(ur), authenticated)
-	engine.Put("/users/:alias/name", updateName(ur), authenticated)
-	engine.Put("/users/:alias/alias", updateAlias(ur), authenticated)
+	engine.Get("/users/:alias", getDetails(ur), authenticated, canonical)
+	engine.Put("/users/:alias/name", updateName(ur), authenticated, self)
+	engine.Put("/users/:alias/alias", updateAlias(ur), authenticated, self)
+	engine.Put("/users/:alias/email", updateEmail(ur), authenticated, self)
+	engine.Put("/users/:alias/password", updatePassword(ur), authenticated, self)
+	engine.Delete("/users/:alias", deleteAccount(ur), authenticated, self)
 
 	// doesn't return a handler, as it's already present in the original scope
 }
@@ -90,13 +121,7 @@ func updateName(ur UserRepository) http.HandlerFunc {
 			return
 		}
 
-		// can't change others names
 		var user = auth.MustGetUser(request)
-		if user.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		if err = ur.UpdateName(user.Id, data.Name); err == nil {
 			JSON.NoContent(writer)
 		} else {
@@ -116,13 +141,7 @@ func updateAlias(ur UserRepository) http.HandlerFunc {
 			return
 		}
 
-		// check authorisation
 		var user = auth.MustGetUser(request)
-		if user.Alias != rest.GetParam(request, "alias") {
-			JSON.Forbidden(writer)
-			return
-		}
-
 		if err = ur.UpdateAlias(user.Id, data.Alias); err == nil {
 			JSON.NoContent(writer)
 		} else if errors.Is(err, ErrAliasTaken) {
@@ -133,9 +152,72 @@ func updateAlias(ur UserRepository) http.HandlerFunc {
 	}
 }
 
-// login performs a simplistic authentication attempt, returning the user ID and status on success.
-// No passwords are checked, only mere user existence.
-func login(ur UserRepository) http.HandlerFunc {
+// updateEmail handles the PUT "/users/:alias/email" route, allowing users to change their email, given their password
+func updateEmail(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		// validate data first
+		data, err := JSON.DecodeValidate[UpdateEmailData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var user = auth.MustGetUser(request)
+		if err = ur.UpdateEmail(user.Id, data.CurrentPassword, data.Email); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrWrongPass) {
+			JSON.BadRequestWithMessage(writer, "The current password is wrong")
+		} else if errors.Is(err, ErrEmailTaken) {
+			JSON.BadRequestWithMessage(writer, "Email already registered")
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// updatePassword handles the PUT "/users/:alias/password" route, allowing users to replace their current password
+func updatePassword(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		// validate data first
+		data, err := JSON.DecodeValidate[UpdatePasswordData](request)
+		if err != nil {
+			JSON.ValidationError(writer, err)
+			return
+		}
+
+		var user = auth.MustGetUser(request)
+		if err = ur.UpdatePassword(user.Id, data.CurrentPassword, data.NewPassword); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrWrongPass) {
+			JSON.BadRequestWithMessage(writer, "The current password is wrong")
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// deleteAccount handles the DELETE "/users/:alias" route, soft-deleting the account along with its artworks.
+// Relations, comments and reactions are removed when the account is purged, after a grace period.
+func deleteAccount(ur UserRepository) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var user = auth.MustGetUser(request)
+		if err := ur.DeleteAccount(user.Id, ntime.Now()); err == nil {
+			JSON.NoContent(writer)
+		} else if errors.Is(err, ErrNotFound) {
+			JSON.NotFound(writer, "Account not found, or already deleted")
+		} else {
+			JSON.InternalServerError(writer, err)
+		}
+	}
+}
+
+// login authenticates users by alias and password, returning the user ID and status on success.
+// Failures are tracked per alias and per client IP, both of which are temporarily locked out after repeated ones.
+// Suspended accounts are refused as if their credentials were wrong, lest their passwords be confirmed or probed
+// without consequences.
+func login(ur UserRepository, options Options) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		const message = "Authentication failed due to wrong credentials."
 		sessionData, err := JSON.DecodeValidate[SessionData](request)
@@ -145,11 +227,34 @@ func login(ur UserRepository) http.HandlerFunc {
 			return
 		}
 
-		// a mere user existence check
-		user, err := ur.GetUserByAlias(sessionData.Alias)
-		if err != nil {
+		// locked out attempts aren't verified, nor counted, lest locks be extended indefinitely
+		var now = time.Now()
+		var ip = rest.ClientIP(request)
+		if lock, e := ur.GetLoginLock(sessionData.Alias, ip, ntime.New(now)); e != nil {
+			JSON.InternalServerError(writer, e)
+			return
+		} else if lock.Valid() {
+			JSON.TooManyRequests(writer, lock.Time().Sub(now), "Too many failed login attempts, retry later.")
+			return
+		}
+
+		user, err := ur.Authenticate(sessionData.Alias, sessionData.Password)
+		switch {
+		case errors.Is(err, ErrWrongPass), errors.Is(err, ErrSuspended):
+			if e := ur.RecordLoginFailure(sessionData.Alias, ip, now, options.AliasLockout, options.IPLockout); e != nil {
+				JSON.InternalServerError(writer, e)
+				return
+			}
 			JSON.BadRequestWithMessage(writer, message)
 			return
+		case err != nil:
+			JSON.InternalServerError(writer, err)
+			return
+		}
+
+		if err = ur.ClearLoginFailures(sessionData.Alias, ip); err != nil {
+			JSON.InternalServerError(writer, err)
+			return
 		}
 
 		// one would set refresh and access tokens in the response but for the moment a status suffices
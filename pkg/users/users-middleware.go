@@ -0,0 +1,35 @@
+package users
+
+import (
+	JSON "github.com/silktrader/kvasari/pkg/json-utilities"
+	"github.com/silktrader/kvasari/pkg/rest"
+	"net/http"
+	"strings"
+)
+
+// CanonicalAliasResponse points clients requesting a former alias to the user's current one.
+type CanonicalAliasResponse struct {
+	Alias          string
+	CanonicalAlias string
+}
+
+// CanonicalAlias redirects requests addressing users by their recently released aliases to the same route, bearing the
+// current alias. Unknown aliases are left to the handlers, which report them as they see fit. Redirects are temporary
+// and uncached, since released aliases can be claimed by others once their cooldown expires.
+func CanonicalAlias(ur UserRepository) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			var alias = rest.GetParam(request, "alias")
+			user, err := ur.GetUserByAlias(alias)
+			if err != nil || user.Alias == alias {
+				next.ServeHTTP(writer, request)
+				return
+			}
+
+			var location = *request.URL
+			location.Path = strings.Replace(location.Path, "/users/"+alias, "/users/"+user.Alias, 1)
+			location.RawPath = ""
+			JSON.TemporaryRedirect(writer, location.RequestURI(), CanonicalAliasResponse{alias, user.Alias})
+		})
+	}
+}
@@ -23,7 +23,10 @@ var (
 	passwordRules = []validation.Rule{validation.Required, validation.Length(8, 50)}
 )
 
-var ErrMissingAlias = errors.New("missing `alias` parameter")
+var (
+	ErrMissingAlias = errors.New("missing `alias` parameter")
+	errPastDate     = errors.New("must be a future date")
+)
 
 type User struct {
 	Id      string
@@ -46,6 +49,37 @@ func (data SessionData) Validate() error {
 		validation.Field(&data.Password, passwordRules...))
 }
 
+// LockoutPolicy regulates how failed logins lock out further attempts.
+type LockoutPolicy struct {
+	// Threshold is the number of failures tolerated before locking
+	Threshold int
+
+	// Window is the time after which failures are forgotten, unless followed by others
+	Window time.Duration
+
+	// BaseLock is the first lock's duration, doubled at each further failure up to MaxLock
+	BaseLock time.Duration
+	MaxLock  time.Duration
+}
+
+// LockDuration returns how long logins are locked after the given number of consecutive failures, growing
+// exponentially once the threshold is exceeded.
+func (policy LockoutPolicy) LockDuration(failures int) time.Duration {
+	var excess = failures - policy.Threshold
+	if excess <= 0 {
+		return 0
+	}
+
+	var lock = policy.BaseLock
+	for ; excess > 1 && lock < policy.MaxLock; excess-- {
+		lock *= 2
+	}
+	if lock > policy.MaxLock {
+		return policy.MaxLock
+	}
+	return lock
+}
+
 type AddUserData struct {
 	Alias    string
 	Name     string
@@ -70,6 +104,30 @@ func (data UpdateNameData) Validate() error {
 	return validation.ValidateStruct(&data, validation.Field(&data.Name, nameRules...))
 }
 
+type UpdateEmailData struct {
+	CurrentPassword string
+	Email           string
+}
+
+func (data UpdateEmailData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.CurrentPassword, passwordRules...),
+		validation.Field(&data.Email, validation.Required, is.Email),
+	)
+}
+
+type UpdatePasswordData struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+func (data UpdatePasswordData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.CurrentPassword, passwordRules...),
+		validation.Field(&data.NewPassword, passwordRules...),
+	)
+}
+
 type UpdateAliasData struct {
 	Alias string
 }
@@ -95,18 +153,21 @@ func getValidateAlias(request *http.Request) (alias string, err error) {
 // UserDetails describes data returned by the getDetails() handler and repository method.
 // Note that Comments and Reactions refer to feedback received, rather than emitted.
 type UserDetails struct {
-	Name           string
-	Email          string
-	Followers      int
-	Following      int
-	ArtworksAdded  int
-	Comments       int
-	Reactions      int
-	FollowedByUser bool
-	FollowsUser    bool
-	BlockedByUser  bool
-	Created        ntime.NTime
-	Updated        ntime.NTime
+	Name            string
+	Email           string
+	Followers       int
+	Following       int
+	ArtworksAdded   int
+	Comments        int
+	Reactions       int
+	FollowedByUser  bool
+	FollowsUser     bool
+	BlockedByUser   bool
+	MutedByUser     bool
+	Private         bool
+	RequestedByUser bool
+	Created         ntime.NTime
+	Updated         ntime.NTime
 }
 
 // filtered users GET query parameters validation
@@ -144,6 +205,37 @@ func (data BanUserData) Validate() error {
 	return validation.ValidateStruct(&data, validation.Field(&data.TargetAlias, aliasRules...))
 }
 
+// Mutes
+
+type MutedUser struct {
+	Id      string
+	Alias   string
+	Name    string
+	Muted   ntime.NTime
+	Expires ntime.NTime
+}
+
+// MuteUserData describes a mute, lasting until lifted when Expires is omitted.
+type MuteUserData struct {
+	TargetAlias string
+	Expires     *ntime.NTime
+}
+
+func (data MuteUserData) Validate() error {
+	return validation.ValidateStruct(&data,
+		validation.Field(&data.TargetAlias, aliasRules...),
+		validation.Field(&data.Expires, validation.By(isFutureDate)),
+	)
+}
+
+// isFutureDate validates optional dates, which must follow the current time when provided.
+func isFutureDate(value interface{}) error {
+	if date, ok := value.(*ntime.NTime); ok && date != nil && !date.Time().After(time.Now()) {
+		return errPastDate
+	}
+	return nil
+}
+
 // Followers
 
 type Follower struct {
@@ -162,6 +254,23 @@ func (data FollowUserData) Validate() error {
 	return validation.ValidateStruct(&data, validation.Field(&data.TargetAlias, aliasRules...))
 }
 
+// FollowRequest describes a pending follow of a private account.
+type FollowRequest struct {
+	Id        string
+	Alias     string
+	Name      string
+	Requested ntime.NTime
+}
+
+// UpdatePrivacyData toggles whether follows require the user's approval.
+type UpdatePrivacyData struct {
+	Private *bool
+}
+
+func (data UpdatePrivacyData) Validate() error {
+	return validation.ValidateStruct(&data, validation.Field(&data.Private, validation.NotNil))
+}
+
 type RelationData struct {
 	Id    string // debatable inclusion
 	Alias string
@@ -0,0 +1,140 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/silktrader/kvasari/pkg/ntime"
+	"time"
+)
+
+// lockoutKind distinguishes the failures counted against an alias from those counted against a client's IP.
+type lockoutKind string
+
+const (
+	aliasLockout lockoutKind = "alias"
+	ipLockout    lockoutKind = "ip"
+)
+
+/*
+Authenticate returns the user matching both the alias and the password.
+
+Returns ErrWrongPass when either doesn't match, without distinguishing unknown aliases, and ErrSuspended when the
+credentials are right but the account was suspended.
+*/
+func (ur *userRepository) Authenticate(alias string, password string) (user User, err error) {
+	var suspended ntime.NTime
+	err = ur.Connection.QueryRow(`
+		SELECT id, name, alias, created, updated, suspended FROM users
+		WHERE alias = ? AND password = ? AND deleted IS NULL`,
+		alias, password,
+	).Scan(&user.Id, &user.Name, &user.Alias, &user.Created, &user.Updated, &suspended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrWrongPass
+	} else if err != nil {
+		return user, err
+	}
+	if suspended.Valid() {
+		return user, ErrSuspended
+	}
+	return user, nil
+}
+
+// GetLoginLock returns the date until which logins are locked, for either the alias or the client's IP, or a null
+// date when neither is locked as of `now`.
+func (ur *userRepository) GetLoginLock(alias string, ip string, now ntime.NTime) (ntime.NTime, error) {
+	var lock ntime.NTime
+	rows, err := ur.Connection.Query(`
+		SELECT locked_until FROM login_failures
+		WHERE ((kind = ? AND subject = ?) OR (kind = ? AND subject = ?)) AND locked_until > ?`,
+		aliasLockout, alias, ipLockout, ip, now,
+	)
+	if err != nil {
+		return lock, err
+	}
+	defer closeRows(rows)
+
+	// the latest lock prevails
+	for rows.Next() {
+		var until ntime.NTime
+		if err = rows.Scan(&until); err != nil {
+			return lock, err
+		}
+		if !lock.Valid() || lock.Before(until) {
+			lock = until
+		}
+	}
+	return lock, rows.Err()
+}
+
+// RecordLoginFailure counts a failed login against both the alias and the client's IP, locking either out once their
+// policies' thresholds are exceeded. Failures older than the policies' windows are forgotten.
+func (ur *userRepository) RecordLoginFailure(
+	alias string, ip string, now time.Time, aliasPolicy, ipPolicy LockoutPolicy,
+) error {
+	tx, err := ur.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, entry := range []struct {
+		kind    lockoutKind
+		subject string
+		policy  LockoutPolicy
+	}{{aliasLockout, alias, aliasPolicy}, {ipLockout, ip, ipPolicy}} {
+		var failures int
+		var last ntime.NTime
+		err = tx.QueryRow(`SELECT failures, last_failure FROM login_failures WHERE kind = ? AND subject = ?`,
+			entry.kind, entry.subject).Scan(&failures, &last)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if now.Sub(last.Time()) > entry.policy.Window {
+			failures = 0
+		}
+		failures++
+
+		var lockedUntil ntime.NTime
+		if lock := entry.policy.LockDuration(failures); lock > 0 {
+			lockedUntil = ntime.New(now.Add(lock))
+		}
+
+		if _, err = tx.Exec(`
+			INSERT INTO login_failures (kind, subject, failures, last_failure, locked_until) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (kind, subject) DO UPDATE
+			SET failures = excluded.failures, last_failure = excluded.last_failure, locked_until = excluded.locked_until`,
+			entry.kind, entry.subject, failures, ntime.New(now), lockedUntil,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClearLoginFailures forgets the failed logins of both the alias and the client's IP, after a successful one.
+// Locked out clients can't clear their failures, since their attempts aren't verified until locks expire.
+func (ur *userRepository) ClearLoginFailures(alias string, ip string) error {
+	_, err := ur.Connection.Exec(`
+		DELETE FROM login_failures WHERE (kind = ? AND subject = ?) OR (kind = ? AND subject = ?)`,
+		aliasLockout, alias, ipLockout, ip,
+	)
+	return err
+}
+
+// PurgeLoginFailures removes the failures which can no longer cause locks, as they fell outside the window and any
+// lock they caused expired.
+func (ur *userRepository) PurgeLoginFailures(lastBefore ntime.NTime, now ntime.NTime) (int64, error) {
+	result, err := ur.Connection.Exec(`
+		DELETE FROM login_failures WHERE last_failure < ? AND (locked_until IS NULL OR locked_until <= ?)`,
+		lastBefore, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
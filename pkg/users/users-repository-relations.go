@@ -1,61 +1,81 @@
 package users
 
 import (
+	"database/sql"
 	"errors"
 	"github.com/mattn/go-sqlite3"
 	"github.com/silktrader/kvasari/pkg/ntime"
 )
 
-func (ur *userRepository) Follow(followerId string, targetAlias string, date ntime.NTime) error {
-	res, err := ur.Connection.Exec(`
-		INSERT INTO followers (follower, target, date)
-		SELECT ?, id as targetId, ?
-		FROM users WHERE alias = ? AND ? NOT IN (SELECT target FROM bans WHERE source = targetId)`,
-		followerId, date, targetAlias, followerId,
-	)
+/*
+Follow makes the follower follow the target, or requests to, when the target's account is private; in the latter case
+`pending` is true and the follow takes effect once the target approves it.
 
-	// detects whether the requester is already among the target's followers
-	var sqliteErr sqlite3.Error
-	if errors.As(err, &sqliteErr) {
-		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
-			return ErrDupFollower
-		}
+Fails with ErrNotFound when the target doesn't exist or bans the follower.
+*/
+func (ur *userRepository) Follow(followerId string, targetAlias string, date ntime.NTime) (pending bool, err error) {
+	var targetId string
+	if err = ur.Connection.QueryRow(`
+		SELECT id, private FROM users
+		WHERE alias = ? AND deleted IS NULL AND ? NOT IN (SELECT target FROM bans WHERE source = users.id)`,
+		targetAlias, followerId,
+	).Scan(&targetId, &pending); errors.Is(err, sql.ErrNoRows) {
+		return pending, ErrNotFound
+	} else if err != nil {
+		return pending, err
 	}
 
-	// unspecified error occurred, should be handled as 50x
-	if err != nil {
-		return err
-	}
+	var duplicate = ErrDupFollower
+	if pending {
+		// approved followers shouldn't request again
+		var follows bool
+		if err = ur.Connection.QueryRow(
+			`SELECT EXISTS (SELECT TRUE FROM followers WHERE follower = ? AND target = ?)`, followerId, targetId,
+		).Scan(&follows); err != nil {
+			return pending, err
+		} else if follows {
+			return pending, ErrDupFollower
+		}
 
-	// when no rows are affected the requester was either banned or the target user doesn't exist
-	if followed, e := res.RowsAffected(); e != nil {
-		return e
-	} else if followed == 0 {
-		return ErrNotFound
+		duplicate = ErrDupRequest
+		_, err = ur.Connection.Exec(`INSERT INTO follow_requests (requester, target, date) VALUES (?, ?, ?)`,
+			followerId, targetId, date)
+	} else {
+		_, err = ur.Connection.Exec(`INSERT INTO followers (follower, target, date) VALUES (?, ?, ?)`,
+			followerId, targetId, date)
 	}
 
-	return err
+	// detects whether the requester is already among the target's followers, or requests
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return pending, duplicate
+	}
+	return pending, err
 }
 
 // Unfollow returns nil for successful operations, or adequate errors on failure,
-// such as when a target user isn't followed.
+// such as when a target user isn't followed. Pending follow requests are withdrawn alike.
 func (ur *userRepository) Unfollow(followerId string, targetAlias string) error {
-	result, err := ur.Connection.Exec(
+	var removed int64
+	for _, statement := range []string{
 		`DELETE FROM followers WHERE follower = ? AND target IN (SELECT id FROM users WHERE alias = ?)`,
-		followerId,
-		targetAlias,
-	)
-	if err != nil {
-		return err
+		`DELETE FROM follow_requests WHERE requester = ? AND target IN (SELECT id FROM users WHERE alias = ?)`,
+	} {
+		result, err := ur.Connection.Exec(statement, followerId, targetAlias)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		removed += affected
 	}
 
-	if unfollowed, e := result.RowsAffected(); e != nil {
-		return e
-	} else if unfollowed == 0 {
+	if removed == 0 {
 		return ErrNotFound
 	}
-
-	return err
+	return nil
 }
 
 // Ban returns nil for successful operations, or adequate errors on failure,
@@ -74,8 +94,9 @@ func (ur *userRepository) Ban(sourceId string, targetAlias string, date ntime.NT
 	// the INSERT must follow the DELETE statement, so to return a relevant `RowsAffected` count
 	res, err := tx.Exec(`
 		DELETE FROM followers WHERE follower IN (SELECT id FROM users WHERE alias = ?) AND target = ?;
+		DELETE FROM follow_requests WHERE requester IN (SELECT id FROM users WHERE alias = ?) AND target = ?;
 		INSERT INTO bans (source, date, target) SELECT ?, ?, id FROM users WHERE alias = ?;
-	`, targetAlias, sourceId, sourceId, date, targetAlias)
+	`, targetAlias, sourceId, targetAlias, sourceId, sourceId, date, targetAlias)
 
 	// detects whether the requester is already among the target's bans
 	var sqliteErr sqlite3.Error
@@ -151,3 +172,185 @@ func (ur *userRepository) GetBans(id string) ([]BannedUser, error) {
 
 	return banned, rows.Err()
 }
+
+/*
+Mute hides the target's artworks, comments and notifications from the source only, until the expiry date, if valid.
+Muting an already muted user replaces the previous mute's dates. Returns ErrNotFound when the target doesn't exist.
+*/
+func (ur *userRepository) Mute(sourceId string, targetAlias string, date ntime.NTime, expires ntime.NTime) error {
+	result, err := ur.Connection.Exec(`
+		INSERT INTO mutes (source, target, date, expires)
+		SELECT ?, id, ?, ? FROM users WHERE alias = ? AND deleted IS NULL AND id != ?
+		ON CONFLICT (source, target) DO UPDATE SET date = excluded.date, expires = excluded.expires`,
+		sourceId, date, expires, targetAlias, sourceId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if muted, e := result.RowsAffected(); e != nil {
+		return e
+	} else if muted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Unmute lifts a mute; returns ErrNotFound when the source didn't mute the target, or the mute expired.
+func (ur *userRepository) Unmute(sourceId string, targetAlias string) error {
+	result, err := ur.Connection.Exec(`
+		DELETE FROM mutes WHERE source = ? AND target IN (SELECT id FROM users WHERE alias = ?)
+		AND (expires IS NULL OR expires > ?)`,
+		sourceId, targetAlias, ntime.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if unmuted, e := result.RowsAffected(); e != nil {
+		return e
+	} else if unmuted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetMutes fetches the users muted by the source, whose mutes haven't expired yet.
+func (ur *userRepository) GetMutes(sourceId string) ([]MutedUser, error) {
+	var muted = make([]MutedUser, 0)
+	rows, err := ur.Connection.Query(`
+		SELECT id, alias, name, date, expires
+		FROM mutes JOIN users ON mutes.target = users.id
+		WHERE source = ? AND deleted IS NULL AND (expires IS NULL OR expires > ?)
+		ORDER BY date DESC`,
+		sourceId, ntime.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var mutedUser MutedUser
+		if err = rows.Scan(&mutedUser.Id, &mutedUser.Alias, &mutedUser.Name, &mutedUser.Muted, &mutedUser.Expires); err != nil {
+			return muted, err
+		}
+		muted = append(muted, mutedUser)
+	}
+
+	return muted, rows.Err()
+}
+
+// SetPrivate toggles whether following the user requires approval; going public approves all pending requests.
+func (ur *userRepository) SetPrivate(userId string, private bool, date ntime.NTime) error {
+	tx, err := ur.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.Exec(`UPDATE users SET private = ?, updated = ? WHERE id = ?`, private, date, userId); err != nil {
+		return err
+	}
+
+	if !private {
+		if _, err = tx.Exec(`
+			INSERT OR IGNORE INTO followers (follower, target, date)
+			SELECT requester, target, ? FROM follow_requests WHERE target = ?;
+			DELETE FROM follow_requests WHERE target = ?;`,
+			date, userId, userId,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFollowRequests lists the pending requests to follow the target, in reverse chronological order.
+func (ur *userRepository) GetFollowRequests(targetId string) ([]FollowRequest, error) {
+	var requests = make([]FollowRequest, 0)
+	rows, err := ur.Connection.Query(`
+		SELECT id, alias, name, date
+		FROM follow_requests JOIN users ON follow_requests.requester = users.id
+		WHERE target = ? AND deleted IS NULL
+		ORDER BY date DESC`,
+		targetId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var request FollowRequest
+		if err = rows.Scan(&request.Id, &request.Alias, &request.Name, &request.Requested); err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveFollowRequest turns a pending request into a follow, dated on approval; returns ErrNotFound when no request
+// matches the requester's alias.
+func (ur *userRepository) ApproveFollowRequest(targetId string, requesterAlias string, date ntime.NTime) error {
+	tx, err := ur.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.Exec(`
+		DELETE FROM follow_requests
+		WHERE target = ? AND requester IN (SELECT id FROM users WHERE alias = ?)`,
+		targetId, requesterAlias,
+	)
+	if err != nil {
+		return err
+	}
+	if approved, e := result.RowsAffected(); e != nil {
+		return e
+	} else if approved == 0 {
+		return ErrNotFound
+	}
+
+	if _, err = tx.Exec(`
+		INSERT OR IGNORE INTO followers (follower, target, date)
+		SELECT id, ?, ? FROM users WHERE alias = ?`,
+		targetId, date, requesterAlias,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RejectFollowRequest discards a pending request; returns ErrNotFound when no request matches the requester's alias.
+func (ur *userRepository) RejectFollowRequest(targetId string, requesterAlias string) error {
+	result, err := ur.Connection.Exec(`
+		DELETE FROM follow_requests WHERE target = ? AND requester IN (SELECT id FROM users WHERE alias = ?)`,
+		targetId, requesterAlias,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rejected, e := result.RowsAffected(); e != nil {
+		return e
+	} else if rejected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -15,39 +15,67 @@ type UserRepository interface {
 	Register(data AddUserData) (*User, error)
 	GetUserById(id string) (user User, err error)
 	GetUserByAlias(alias string) (user User, err error)
+	Authenticate(alias string, password string) (user User, err error)
 	UpdateName(userId string, newName string) error
 	UpdateAlias(userId string, newAlias string) error
+	UpdateEmail(userId string, currentPassword string, newEmail string) error
+	UpdatePassword(userId string, currentPassword string, newPassword string) error
+	DeleteAccount(userId string, date ntime.NTime) error
 
-	Follow(followerId string, targetAlias string, date ntime.NTime) error
+	Follow(followerId string, targetAlias string, date ntime.NTime) (pending bool, err error)
 	Unfollow(followerId string, targetAlias string) error
 	GetFollowers(userAlias string) ([]Follower, error)
+	SetPrivate(userId string, private bool, date ntime.NTime) error
+	GetFollowRequests(targetId string) ([]FollowRequest, error)
+	ApproveFollowRequest(targetId string, requesterAlias string, date ntime.NTime) error
+	RejectFollowRequest(targetId string, requesterAlias string) error
 
 	Ban(sourceId string, targetAlias string, date ntime.NTime) error
 	Unban(sourceId string, targetAlias string) error
 	GetBans(sourceId string) ([]BannedUser, error)
+	Mute(sourceId string, targetAlias string, date ntime.NTime, expires ntime.NTime) error
+	Unmute(sourceId string, targetAlias string) error
+	GetMutes(sourceId string) ([]MutedUser, error)
 	GetUserRelations(userId string) ([]RelationData, []RelationData, error)
 
 	GetDetails(alias string, requesterId string) (details UserDetails, err error)
+
+	GetLoginLock(alias string, ip string, now ntime.NTime) (ntime.NTime, error)
+	RecordLoginFailure(alias string, ip string, now time.Time, aliasPolicy, ipPolicy LockoutPolicy) error
+	ClearLoginFailures(alias string, ip string) error
+	PurgeLoginFailures(lastBefore ntime.NTime, now ntime.NTime) (int64, error)
 }
 
 type userRepository struct {
 	Connection *sql.DB
+
+	// AliasCooldown is the time during which released aliases can't be claimed by others and redirect to new ones
+	AliasCooldown time.Duration
 }
 
 var (
 	ErrDupFollower = errors.New("user already follows target")
+	ErrDupRequest  = errors.New("user already requested to follow target")
 	ErrNotFound    = errors.New("not found")
 	ErrDupBan      = errors.New("user is already banned")
 	ErrAliasTaken  = errors.New("alias is already taken")
 	ErrDupUser     = errors.New("email or alias is already registered")
+	ErrEmailTaken  = errors.New("email is already registered")
+	ErrWrongPass   = errors.New("wrong password")
+	ErrSuspended   = errors.New("account is suspended")
 )
 
 func closeRows(rows *sql.Rows) {
 	_ = rows.Close()
 }
 
-func NewRepository(connection *sql.DB) UserRepository {
-	return &userRepository{connection}
+func NewRepository(connection *sql.DB, aliasCooldown time.Duration) UserRepository {
+	return &userRepository{connection, aliasCooldown}
+}
+
+// reservedSince returns the earliest release date of aliases that are still reserved to their former owners.
+func (ur *userRepository) reservedSince() ntime.NTime {
+	return ntime.New(time.Now().Add(-ur.AliasCooldown))
 }
 
 func (ur *userRepository) GetFilteredUsers(filter string, requesterId string) ([]User, error) {
@@ -55,7 +83,7 @@ func (ur *userRepository) GetFilteredUsers(filter string, requesterId string) ([
 	var filterPattern = fmt.Sprintf("%%%s%%", filter)
 	rows, err := ur.Connection.Query(`
 		SELECT id, name, alias, email, created, updated FROM users
-		WHERE id != ?
+		WHERE id != ? AND deleted IS NULL AND suspended IS NULL
 		AND (alias LIKE ? OR name LIKE ?)
 		AND ? NOT IN (SELECT target FROM bans WHERE source = users.id)`,
 		requesterId, filterPattern, filterPattern, requesterId)
@@ -86,7 +114,8 @@ func (ur *userRepository) GetFollowers(userAlias string) ([]Follower, error) {
 	rows, err := ur.Connection.Query(`
 		SELECT id, alias, name, email, date
 		FROM (SELECT follower, date FROM followers WHERE target = (SELECT id FROM users WHERE users.alias = ?)) as fws
-		JOIN users ON fws.follower = users.id`,
+		JOIN users ON fws.follower = users.id
+		WHERE users.deleted IS NULL`,
 		userAlias,
 	)
 	if err != nil {
@@ -109,9 +138,20 @@ func (ur *userRepository) GetFollowers(userAlias string) ([]Follower, error) {
 	return followers, nil
 }
 
-// GetUserByAlias either returns a user matching the alias, or an error (along with an ignorable empty struct).
+/*
+GetUserByAlias either returns a user matching the alias, or an error (along with an ignorable empty struct).
+
+Aliases released during the cooldown resolve to their former owners, whose current, canonical alias is returned; callers
+can compare it with the requested one to detect the redirection.
+*/
 func (ur *userRepository) GetUserByAlias(alias string) (user User, err error) {
-	return user, ur.Connection.QueryRow("SELECT id, name, alias, created, updated FROM users WHERE alias = ?", alias).Scan(
+	return user, ur.Connection.QueryRow(`
+		SELECT id, name, alias, created, updated FROM users
+		WHERE deleted IS NULL AND (alias = ? OR id = (
+			SELECT user FROM alias_history WHERE alias = ? AND released > ? ORDER BY released DESC LIMIT 1))
+		ORDER BY alias = ? DESC LIMIT 1`,
+		alias, alias, ur.reservedSince(), alias,
+	).Scan(
 		&user.Id,
 		&user.Name,
 		&user.Alias,
@@ -123,7 +163,7 @@ func (ur *userRepository) GetUserByAlias(alias string) (user User, err error) {
 // GetUserById either returns a user matching the id, or an error (along with an ignorable empty struct).
 func (ur *userRepository) GetUserById(id string) (user User, err error) {
 	// if the query selects no rows, `Scan` will return ErrNoRows
-	return user, ur.Connection.QueryRow("SELECT id, name, alias, created, updated FROM users WHERE id = ?", id).Scan(
+	return user, ur.Connection.QueryRow("SELECT id, name, alias, created, updated FROM users WHERE id = ? AND deleted IS NULL", id).Scan(
 		&user.Id,
 		&user.Name,
 		&user.Alias,
@@ -135,16 +175,24 @@ func (ur *userRepository) GetUserById(id string) (user User, err error) {
 func (ur *userRepository) Register(data AddUserData) (*User, error) {
 	var id = rest.MustGetNewUUID()
 	var now = ntime.Now()
-	if _, err := ur.Connection.Exec(`
+	// aliases recently released by others are treated as registered ones
+	result, err := ur.Connection.Exec(`
 		INSERT INTO users(id, name, alias, email, password, created, updated)
-		VALUES(?, ?, ?, ?, ?, ?, ?)`,
-		id, data.Name, data.Alias, data.Email, data.Password, now, now); err != nil {
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM alias_history WHERE alias = ? AND released > ?)`,
+		id, data.Name, data.Alias, data.Email, data.Password, now, now, data.Alias, ur.reservedSince())
+	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
 			return nil, ErrDupUser
 		}
 		return nil, err
 	}
+	if inserted, e := result.RowsAffected(); e != nil {
+		return nil, e
+	} else if inserted == 0 {
+		return nil, ErrDupUser
+	}
 
 	return &User{
 		id,
@@ -162,11 +210,45 @@ func (ur *userRepository) UpdateName(userId string, newName string) error {
 	return err
 }
 
-// UpdateAlias will change the specified user's alias, but won't return errors in case of no changes
+/*
+UpdateAlias will change the specified user's alias, but won't return errors in case of no changes.
+
+The previous alias is recorded in the history, reserving it to the user for the cooldown, while aliases recently
+released by others are reported as taken. Users can reclaim their own former aliases at any time.
+*/
 func (ur *userRepository) UpdateAlias(userId string, newAlias string) error {
-	// idempotent PUT request doesn't require a change detection
-	if _, err := ur.Connection.Exec("UPDATE users SET alias = ? WHERE id = ?",
-		newAlias, time.Now(), userId); err != nil {
+	tx, err := ur.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var currentAlias string
+	if err = tx.QueryRow("SELECT alias FROM users WHERE id = ?", userId).Scan(&currentAlias); err != nil {
+		return err
+	}
+
+	// idempotent PUT request doesn't require further changes
+	if currentAlias == newAlias {
+		return nil
+	}
+
+	var reserved bool
+	if err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM alias_history WHERE alias = ? AND user != ? AND released > ?)",
+		newAlias, userId, ur.reservedSince(),
+	).Scan(&reserved); err != nil {
+		return err
+	} else if reserved {
+		return ErrAliasTaken
+	}
+
+	var now = ntime.Now()
+	if _, err = tx.Exec("UPDATE users SET alias = ?, updated = ? WHERE id = ?", newAlias, now, userId); err != nil {
 		// detect alias uniqueness violations which signal that the alias is taken
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
@@ -174,9 +256,87 @@ func (ur *userRepository) UpdateAlias(userId string, newAlias string) error {
 		}
 		return err
 	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO alias_history (alias, user, released) VALUES (?, ?, ?)
+		ON CONFLICT (alias, user) DO UPDATE SET released = excluded.released`,
+		currentAlias, userId, now,
+	); err != nil {
+		return err
+	}
+
+	// a reclaimed alias must no longer redirect
+	if _, err = tx.Exec("DELETE FROM alias_history WHERE alias = ? AND user = ?", newAlias, userId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEmail changes the user's email, provided the current password matches and the email isn't registered yet.
+func (ur *userRepository) UpdateEmail(userId string, currentPassword string, newEmail string) error {
+	result, err := ur.Connection.Exec("UPDATE users SET email = ?, updated = ? WHERE id = ? AND password = ?",
+		newEmail, ntime.Now(), userId, currentPassword)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	return checkPasswordMatched(result)
+}
+
+// UpdatePassword replaces the user's password, provided the current one matches.
+func (ur *userRepository) UpdatePassword(userId string, currentPassword string, newPassword string) error {
+	result, err := ur.Connection.Exec("UPDATE users SET password = ?, updated = ? WHERE id = ? AND password = ?",
+		newPassword, ntime.Now(), userId, currentPassword)
+	if err != nil {
+		return err
+	}
+	return checkPasswordMatched(result)
+}
+
+// checkPasswordMatched interprets an update constrained by the user's password; no affected rows signal a mismatch.
+func checkPasswordMatched(result sql.Result) error {
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return ErrWrongPass
+	}
 	return nil
 }
 
+// DeleteAccount soft-deletes a user account, along with their artworks, hiding both from other users.
+// Accounts are permanently removed once their grace period expires, thanks to the artworks store's purge.
+func (ur *userRepository) DeleteAccount(userId string, date ntime.NTime) error {
+	tx, err := ur.Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rolling back after a transaction commit will result in a safe NOP
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.Exec("UPDATE users SET deleted = ? WHERE id = ? AND deleted IS NULL", date, userId)
+	if err != nil {
+		return err
+	}
+	if deleted, e := result.RowsAffected(); e != nil {
+		return e
+	} else if deleted == 0 {
+		return ErrNotFound
+	}
+
+	if _, err = tx.Exec("UPDATE artworks SET deleted = TRUE WHERE author_id = ?", userId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (ur *userRepository) GetUserRelations(userId string) ([]RelationData, []RelationData, error) {
 
 	var followers, followed = make([]RelationData, 0), make([]RelationData, 0)
@@ -193,6 +353,7 @@ func (ur *userRepository) GetUserRelations(userId string) ([]RelationData, []Rel
 		    WHERE  follower = ?
 		) as x
 		JOIN users USING (id)
+		WHERE deleted IS NULL
 		ORDER BY date DESC`,
 		userId,
 		userId,
@@ -237,15 +398,22 @@ func (ur *userRepository) GetDetails(alias string, requesterId string) (details
 			(SELECT EXISTS (SELECT TRUE FROM followers WHERE follower = users.id AND target = ?)) as followsUser,
 			(SELECT EXISTS (SELECT TRUE FROM followers WHERE follower = ? AND target = users.id)) as followedByUser,
 			(SELECT EXISTS (SELECT TRUE FROM bans WHERE target = users.id AND source = ?)) as blockedByUser,
+			(SELECT EXISTS (SELECT TRUE FROM mutes WHERE target = users.id AND source = ?
+				AND (expires IS NULL OR expires > ?))) as mutedByUser,
+			private,
+			(SELECT EXISTS (SELECT TRUE FROM follow_requests WHERE requester = ? AND target = users.id)) as requestedByUser,
 			(SELECT count(follower) FROM followers WHERE target = users.id) as followers,
 			(SELECT count(target) FROM followers WHERE follower = users.id) as following,
 			(SELECT count(id) FROM author_artworks) as artworks,
 			(SELECT count(id) FROM artwork_comments WHERE artwork IN author_artworks) as comments,
 			(SELECT count(user) FROM artwork_feedback WHERE artwork IN author_artworks) as reactions
 		FROM users
-		WHERE alias = ? AND ? NOT IN (SELECT target FROM bans WHERE source = users.id)`,
+		WHERE alias = ? AND deleted IS NULL AND ? NOT IN (SELECT target FROM bans WHERE source = users.id)`,
+		requesterId,
+		requesterId,
 		requesterId,
 		requesterId,
+		ntime.Now(),
 		requesterId,
 		alias,
 		requesterId,
@@ -257,6 +425,9 @@ func (ur *userRepository) GetDetails(alias string, requesterId string) (details
 		&details.FollowsUser,
 		&details.FollowedByUser,
 		&details.BlockedByUser,
+		&details.MutedByUser,
+		&details.Private,
+		&details.RequestedByUser,
 		&details.Followers,
 		&details.Following,
 		&details.ArtworksAdded,